@@ -58,6 +58,12 @@ const (
 	ExitStatusServerError
 	// ExitStatusMigrationError is returned in case of an error while attempting to perform DB migrations
 	ExitStatusMigrationError
+	// ExitStatusReplayError is returned in case of an error while attempting to replay consumer errors
+	ExitStatusReplayError
+	// ExitStatusCleanupError is returned in case of an error while attempting to remove stale data
+	ExitStatusCleanupError
+	// ExitStatusEraseError is returned in case of an error while attempting to erase data of organization or cluster
+	ExitStatusEraseError
 	defaultConfigFilename = "config"
 	typeStr               = "type"
 
@@ -170,6 +176,14 @@ func startService() int {
 	errorGroup := new(errgroup.Group)
 
 	brokerConf := conf.GetBrokerConfiguration()
+	// allowlist is used by the consumer and by the ingestion endpoint
+	if brokerConf.OrgAllowlistEnabled {
+		if exitCode := prepareOrgAllowlist(ctx, errorGroup, brokerConf); exitCode != ExitStatusOK {
+			cancel()
+			return exitCode
+		}
+	}
+
 	// if broker is disabled, simply don't start it
 	if brokerConf.Enabled {
 		errorGroup.Go(func() error {
@@ -187,6 +201,16 @@ func startService() int {
 		log.Info().Msg("Broker is disabled, not starting it")
 	}
 
+	retentionCfg := conf.GetRetentionConfiguration()
+	// stale data are removed by the service only when it's configured
+	if isRetentionPolicySet(retentionCfg) && retentionCfg.CleanupIntervalHours > 0 {
+		errorGroup.Go(func() error {
+			return startRetentionWorker(ctx, retentionCfg)
+		})
+	} else {
+		log.Info().Msg("Retention policy is not enforced by the service")
+	}
+
 	errorGroup.Go(func() error {
 		defer cancel()
 
@@ -264,6 +288,11 @@ The commands are:
     print-version-info  prints version info
     migration           prints information about migrations (current, latest)
     migration <version> migrates database to the specified version
+    replay-consumer-errors [-from <time>] [-to <time>] [-topic <topic>] [-error <text>] [-all]
+                        replays stored messages that the consumer was unable to process
+    cleanup [-dry-run]  removes stale data according to the retention policy
+    erase (-org <org_id> | -cluster <cluster>)
+                        removes all data of the organization or the cluster
 
 `
 
@@ -440,6 +469,12 @@ func handleCommand(command string) int {
 		printVersionInfo()
 	case "migrations", "migration", "migrate":
 		return performMigrations()
+	case "replay-consumer-errors":
+		return performConsumerErrorsReplay()
+	case "cleanup":
+		return performCleanup()
+	case "erase":
+		return performErasure()
 	default:
 		fmt.Printf("\nCommand '%v' not found\n", command)
 		return printHelp()
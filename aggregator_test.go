@@ -18,21 +18,29 @@ package main_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	mapset "github.com/deckarep/golang-set"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
 	main "github.com/RedHatInsights/insights-results-aggregator"
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
 	"github.com/RedHatInsights/insights-results-aggregator/migration"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
 const (
@@ -440,3 +448,429 @@ func TestPerformMigrationsTooManyArgs(t *testing.T) {
 
 	os.Args = oldArgs
 }
+
+// TestParseReplayFilter checks that arguments of the command for replaying
+// consumer errors are parsed correctly.
+func TestParseReplayFilter(t *testing.T) {
+	filter, err := main.ParseReplayFilter([]string{
+		"-from", "2021-01-01T00:00:00Z", "-to", "2021-02-01T00:00:00Z", "-topic", "topic", "-error", "EOF",
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, storage.ConsumerErrorFilter{
+		From:          time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+		To:            time.Date(2021, time.February, 1, 0, 0, 0, 0, time.UTC),
+		Topic:         "topic",
+		ErrorContains: "EOF",
+		SkipReplayed:  true,
+	}, filter)
+
+	filter, err = main.ParseReplayFilter([]string{"-all"})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.ConsumerErrorFilter{}, filter)
+}
+
+// TestParseReplayFilterBadTime checks that invalid time is rejected.
+func TestParseReplayFilterBadTime(t *testing.T) {
+	_, err := main.ParseReplayFilter([]string{"-from", "yesterday"})
+	assert.Error(t, err)
+
+	_, err = main.ParseReplayFilter([]string{"-to", "tomorrow"})
+	assert.Error(t, err)
+}
+
+// TestReplayConsumerErrors checks that stored messages are replayed and
+// marked according to the result of processing.
+func TestReplayConsumerErrors(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	for offset, message := range []string{testdata.ConsumerMessage, "not a JSON"} {
+		err := mockStorage.WriteConsumerError(&broker.Message{
+			Topic:     "topic",
+			Offset:    int64(offset),
+			Value:     []byte(message),
+			Timestamp: testdata.LastCheckedAt,
+		}, errors.New("database is locked"))
+		helpers.FailOnError(t, err)
+	}
+
+	replayed, failing, err := main.ReplayConsumerErrors(
+		mockStorage, conf.GetBrokerConfiguration(), storage.ConsumerErrorFilter{SkipReplayed: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, 1, failing)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	// only the failing message is replayed again
+	replayed, failing, err = main.ReplayConsumerErrors(
+		mockStorage, conf.GetBrokerConfiguration(), storage.ConsumerErrorFilter{SkipReplayed: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, replayed)
+	assert.Equal(t, 1, failing)
+}
+
+// TestReplayConsumerErrorsDBError checks that the DB error is returned.
+func TestReplayConsumerErrorsDBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, _, err := main.ReplayConsumerErrors(
+		mockStorage, conf.GetBrokerConfiguration(), storage.ConsumerErrorFilter{},
+	)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+// TestReplayConsumerErrorsOrgAllowlistFromDB checks that the organization
+// allowlist is read from the DB before the messages are replayed
+func TestReplayConsumerErrorsOrgAllowlistFromDB(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__BROKER__ENABLE_ORG_ALLOWLIST":     "true",
+		"INSIGHTS_RESULTS_AGGREGATOR__PROCESSING__ORG_ALLOWLIST_SOURCE": conf.OrgAllowlistSourceDB,
+	})
+	// the source is kept by the configuration loaded by other tests otherwise
+	defer setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__PROCESSING__ORG_ALLOWLIST_SOURCE": conf.OrgAllowlistSourceFile,
+	})
+
+	connection := mockStorage.(*storage.DBStorage).GetConnection()
+	_, err := connection.Exec("INSERT INTO org_allowlist (org_id) VALUES ($1)", testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteConsumerError(&broker.Message{
+		Topic:     "topic",
+		Value:     []byte(testdata.ConsumerMessage),
+		Timestamp: testdata.LastCheckedAt,
+	}, errors.New("database is locked"))
+	helpers.FailOnError(t, err)
+
+	replayed, failing, err := main.ReplayConsumerErrors(
+		mockStorage, conf.GetBrokerConfiguration(), storage.ConsumerErrorFilter{SkipReplayed: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, 0, failing)
+}
+
+// TestPerformConsumerErrorsReplay checks that the command for replaying
+// consumer errors exits with the OK exit code.
+func TestPerformConsumerErrorsReplay(t *testing.T) {
+	// the command won't run on top of an empty DB
+	*main.AutoMigratePtr = true
+
+	os.Clearenv()
+	mustLoadConfiguration("tests/config1")
+
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "replay-consumer-errors", "-topic", "topic"}
+	exitCode := main.PerformConsumerErrorsReplay()
+	assert.Equal(t, main.ExitStatusOK, exitCode)
+
+	os.Args = oldArgs
+	*main.AutoMigratePtr = false
+}
+
+// TestPerformConsumerErrorsReplayBadArgs checks that invalid arguments result
+// in the replay error exit code.
+func TestPerformConsumerErrorsReplayBadArgs(t *testing.T) {
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "replay-consumer-errors", "-from", "yesterday"}
+	exitCode := main.PerformConsumerErrorsReplay()
+	assert.Equal(t, main.ExitStatusReplayError, exitCode)
+
+	os.Args = oldArgs
+}
+
+func TestRetentionCutoffs(t *testing.T) {
+	now := time.Date(2021, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	reportsBefore, consumerErrorsBefore := main.RetentionCutoffs(storage.RetentionConfiguration{
+		ReportMaxAgeDays:        30,
+		ConsumerErrorMaxAgeDays: 7,
+	}, now)
+	assert.Equal(t, time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), reportsBefore)
+	assert.Equal(t, time.Date(2021, 3, 24, 12, 0, 0, 0, time.UTC), consumerErrorsBefore)
+
+	// zero max age means that the data are kept forever
+	reportsBefore, consumerErrorsBefore = main.RetentionCutoffs(storage.RetentionConfiguration{}, now)
+	assert.True(t, reportsBefore.IsZero())
+	assert.True(t, consumerErrorsBefore.IsZero())
+}
+
+func TestParseCleanupArgs(t *testing.T) {
+	dryRun, err := main.ParseCleanupArgs([]string{"-dry-run"})
+	helpers.FailOnError(t, err)
+	assert.True(t, dryRun)
+
+	dryRun, err = main.ParseCleanupArgs([]string{})
+	helpers.FailOnError(t, err)
+	assert.False(t, dryRun)
+
+	_, err = main.ParseCleanupArgs([]string{"-force"})
+	assert.Error(t, err)
+}
+
+func TestApplyRetentionPolicy(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		time.Now().AddDate(0, 0, -10), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	retentionCfg := storage.RetentionConfiguration{ReportMaxAgeDays: 7}
+
+	result, err := main.ApplyRetentionPolicy(mockStorage, retentionCfg, true)
+	helpers.FailOnError(t, err)
+	assert.Len(t, result.StaleClusters, 1)
+
+	// nothing has been removed in dry-run mode
+	result, err = main.ApplyRetentionPolicy(mockStorage, retentionCfg, false)
+	helpers.FailOnError(t, err)
+	assert.Len(t, result.StaleClusters, 1)
+
+	result, err = main.ApplyRetentionPolicy(mockStorage, retentionCfg, false)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, result.StaleClusters)
+}
+
+// TestRunRetentionWorker checks that the worker applies the policy and stops
+// once the context is done.
+func TestRunRetentionWorker(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		time.Now().AddDate(0, 0, -10), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		main.RunRetentionWorker(ctx, mockStorage, storage.RetentionConfiguration{ReportMaxAgeDays: 7}, time.Hour)
+	}, testsTimeout)
+
+	clusters, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, clusters)
+}
+
+// TestPerformCleanupDryRun checks that the cleanup command exits with the OK
+// exit code.
+func TestPerformCleanupDryRun(t *testing.T) {
+	// the command won't run on top of an empty DB
+	*main.AutoMigratePtr = true
+
+	os.Clearenv()
+	mustLoadConfiguration("tests/config1")
+
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "cleanup", "-dry-run"}
+	exitCode := main.PerformCleanup()
+	assert.Equal(t, main.ExitStatusOK, exitCode)
+
+	os.Args = oldArgs
+	*main.AutoMigratePtr = false
+}
+
+// TestPerformCleanupBadArgs checks that invalid arguments result in the
+// cleanup error exit code.
+func TestPerformCleanupBadArgs(t *testing.T) {
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "cleanup", "-force"}
+	exitCode := main.PerformCleanup()
+	assert.Equal(t, main.ExitStatusCleanupError, exitCode)
+
+	os.Args = oldArgs
+}
+
+func TestParseEraseArgs(t *testing.T) {
+	orgID, clusterName, err := main.ParseEraseArgs([]string{"-org", "1"})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.OrgID, orgID)
+	assert.Empty(t, clusterName)
+
+	orgID, clusterName, err = main.ParseEraseArgs([]string{"-cluster", string(testdata.ClusterName)})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.OrgID(0), orgID)
+	assert.Equal(t, testdata.ClusterName, clusterName)
+}
+
+func TestParseEraseArgsErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-org", "1", "-cluster", string(testdata.ClusterName)},
+		{"-org", "first"},
+		{"-cluster", string(testdata.BadClusterName)},
+	} {
+		_, _, err := main.ParseEraseArgs(args)
+		assert.Error(t, err, args)
+	}
+}
+
+func TestEraseData(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	result, err := main.EraseData(mockStorage, 0, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(1), result["report"])
+
+	result, err = main.EraseData(mockStorage, testdata.OrgID, "")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(0), result["report"])
+}
+
+// TestPerformErasure checks that the erase command exits with the OK exit
+// code.
+func TestPerformErasure(t *testing.T) {
+	// the command won't run on top of an empty DB
+	*main.AutoMigratePtr = true
+
+	os.Clearenv()
+	mustLoadConfiguration("tests/config1")
+
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "erase", "-org", "1"}
+	exitCode := main.PerformErasure()
+	assert.Equal(t, main.ExitStatusOK, exitCode)
+
+	os.Args = oldArgs
+	*main.AutoMigratePtr = false
+}
+
+// TestPerformErasureBadArgs checks that invalid arguments result in the
+// erase error exit code.
+func TestPerformErasureBadArgs(t *testing.T) {
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "erase"}
+	exitCode := main.PerformErasure()
+	assert.Equal(t, main.ExitStatusEraseError, exitCode)
+
+	os.Args = oldArgs
+}
+
+// mustWriteAllowlistFile writes CSV file with the allowlist of given
+// organizations and returns its name
+func mustWriteAllowlistFile(t testing.TB, orgIDs ...types.OrgID) string {
+	file, err := ioutil.TempFile("", "org_allowlist_*.csv")
+	helpers.FailOnError(t, err)
+
+	_, err = fmt.Fprintln(file, "OrgID")
+	helpers.FailOnError(t, err)
+	for _, orgID := range orgIDs {
+		_, err = fmt.Fprintln(file, orgID)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.FailOnError(t, file.Close())
+
+	return file.Name()
+}
+
+func TestReplaceOrgAllowlist(t *testing.T) {
+	allowlist := mapset.NewSetWith(types.OrgID(1), types.OrgID(2))
+
+	main.ReplaceOrgAllowlist(allowlist, mapset.NewSetWith(types.OrgID(2), types.OrgID(3)))
+
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(2), types.OrgID(3))))
+}
+
+func TestReadOrgAllowlist(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	connection := mockStorage.(*storage.DBStorage).GetConnection()
+	_, err := connection.Exec("INSERT INTO org_allowlist (org_id) VALUES (1), (2)")
+	helpers.FailOnError(t, err)
+
+	allowlist, err := main.ReadOrgAllowlist(mockStorage)
+	helpers.FailOnError(t, err)
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(1), types.OrgID(2))))
+}
+
+// TestOrgAllowlistReloader checks that the allowlist is updated in place and
+// that the current allowlist is kept when the new one can't be read
+func TestOrgAllowlistReloader(t *testing.T) {
+	allowlistFile := mustWriteAllowlistFile(t, 1, 2)
+	defer func() {
+		helpers.FailOnError(t, os.Remove(allowlistFile))
+	}()
+
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__BROKER__ENABLE_ORG_ALLOWLIST":   "true",
+		"INSIGHTS_RESULTS_AGGREGATOR__PROCESSING__ORG_ALLOWLIST_FILE": allowlistFile,
+	})
+
+	allowlist := conf.GetBrokerConfiguration().OrgAllowlist
+	reloader := main.NewOrgAllowlistReloader(allowlist, conf.GetOrgAllowlistSource())
+
+	helpers.FailOnError(t, ioutil.WriteFile(allowlistFile, []byte("OrgID\n3\n"), 0600))
+
+	count, err := reloader.ReloadOrgAllowlist()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+	assert.True(t, conf.GetBrokerConfiguration().OrgAllowlist.Equal(mapset.NewSetWith(types.OrgID(3))))
+
+	helpers.FailOnError(t, ioutil.WriteFile(allowlistFile, []byte("OrgID\nnot a number\n"), 0600))
+
+	_, err = reloader.ReloadOrgAllowlist()
+	assert.Error(t, err)
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(3))))
+}
+
+// TestRunOrgAllowlistReloadWorker checks that the allowlist is reloaded on
+// signal and that the worker stops once the context is done
+func TestRunOrgAllowlistReloadWorker(t *testing.T) {
+	allowlistFile := mustWriteAllowlistFile(t, 1)
+	defer func() {
+		helpers.FailOnError(t, os.Remove(allowlistFile))
+	}()
+
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__BROKER__ENABLE_ORG_ALLOWLIST":   "true",
+		"INSIGHTS_RESULTS_AGGREGATOR__PROCESSING__ORG_ALLOWLIST_FILE": allowlistFile,
+	})
+
+	allowlist := mapset.NewSet()
+	reloader := main.NewOrgAllowlistReloader(allowlist, conf.OrgAllowlistSourceFile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal)
+
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		go func() {
+			// the signal is received once the worker runs
+			signals <- syscall.SIGHUP
+			cancel()
+		}()
+
+		main.RunOrgAllowlistReloadWorker(ctx, reloader, signals)
+	}, testsTimeout)
+
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(1))))
+}
@@ -24,15 +24,30 @@ import (
 	mapset "github.com/deckarep/golang-set"
 )
 
-// Configuration represents configuration of Kafka broker
+// Types of brokers that can be selected in the configuration
+const (
+	// TypeKafka selects Kafka broker, it's used when no type is configured
+	TypeKafka = "kafka"
+	// TypeFile selects file or directory with messages stored as JSON lines
+	TypeFile = "file"
+	// TypeStdin selects messages read as JSON lines from standard input
+	TypeStdin = "stdin"
+)
+
+// Configuration represents configuration of the broker
 type Configuration struct {
+	Type                string        `mapstructure:"type" toml:"type"`
+	Path                string        `mapstructure:"path" toml:"path"`
 	Address             string        `mapstructure:"address" toml:"address"`
 	Topic               string        `mapstructure:"topic" toml:"topic"`
 	Timeout             time.Duration `mapstructure:"timeout" toml:"timeout"`
 	PayloadTrackerTopic string        `mapstructure:"payload_tracker_topic" toml:"payload_tracker_topic"`
+	NotificationTopic   string        `mapstructure:"notification_topic" toml:"notification_topic"`
 	ServiceName         string        `mapstructure:"service_name" toml:"service_name"`
 	Group               string        `mapstructure:"group" toml:"group"`
 	Enabled             bool          `mapstructure:"enabled" toml:"enabled"`
 	OrgAllowlist        mapset.Set    `mapstructure:"org_allowlist_file" toml:"org_allowlist_file"`
 	OrgAllowlistEnabled bool          `mapstructure:"enable_org_allowlist" toml:"enable_org_allowlist"`
+	Workers             int           `mapstructure:"workers" toml:"workers"`
+	BatchSize           int           `mapstructure:"batch_size" toml:"batch_size"`
 }
@@ -0,0 +1,300 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StdinTopic is the topic of messages read from standard input
+const StdinTopic = "stdin"
+
+// ErrSourceClosed is returned by Next when the source has been closed
+var ErrSourceClosed = errors.New("source of messages has been closed")
+
+// Source represents any source of input messages other than Kafka broker.
+// Sources are used to run the aggregator without any Kafka cluster, for
+// example in development or for offline reprocessing of stored messages.
+type Source interface {
+	// Next returns the next message from the source, io.EOF is returned
+	// when there are no more messages
+	Next() (*Message, error)
+	// Close closes all resources used by the source
+	Close() error
+}
+
+// NewSource constructs source of input messages of the type selected by the
+// configuration. Kafka broker is not a source, messages are consumed from it
+// by the consumer directly.
+func NewSource(brokerCfg Configuration) (Source, error) {
+	switch brokerCfg.Type {
+	case TypeFile:
+		return NewFileSource(brokerCfg.Path)
+	case TypeStdin:
+		return NewReaderSource(StdinTopic, os.Stdin), nil
+	default:
+		return nil, fmt.Errorf("broker of type '%s' is not a source of messages", brokerCfg.Type)
+	}
+}
+
+// readerSource reads messages stored as JSON lines, one message per line.
+// Empty lines are skipped, offset of the message is its line number.
+type readerSource struct {
+	topic  string
+	closer io.Closer
+	offset int64
+	// lines are read by a single goroutine, so reading of the line that is
+	// blocked, for example on standard input, can be interrupted by Close
+	lines <-chan readResult
+	// done is closed by Close
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// readResult is the line read from the reader together with the error
+type readResult struct {
+	line []byte
+	err  error
+}
+
+// NewReaderSource constructs source of messages read as JSON lines from the
+// reader, topic is used to identify the messages
+func NewReaderSource(topic string, reader io.Reader) Source {
+	lines := make(chan readResult)
+	source := &readerSource{
+		topic: topic,
+		lines: lines,
+		done:  make(chan struct{}),
+	}
+
+	go readLines(bufio.NewReader(reader), lines, source.done)
+
+	return source
+}
+
+// readLines sends lines read from the reader to the channel until an error,
+// including io.EOF, is read or until done is closed. The read that is blocked
+// when done is closed finishes once the reader returns.
+func readLines(reader *bufio.Reader, lines chan<- readResult, done <-chan struct{}) {
+	defer close(lines)
+
+	for {
+		// messages can be longer than the maximum line length of bufio.Scanner
+		line, err := reader.ReadBytes('\n')
+
+		select {
+		case lines <- readResult{line: line, err: err}:
+		case <-done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readLine returns the next line read from the reader, ErrSourceClosed is
+// returned once the source is closed even when the reading is blocked
+func (source *readerSource) readLine() ([]byte, error) {
+	select {
+	case <-source.done:
+		return nil, ErrSourceClosed
+	default:
+	}
+
+	select {
+	case read, ok := <-source.lines:
+		if !ok {
+			// the error has been returned already
+			return nil, io.EOF
+		}
+		return read.line, read.err
+	case <-source.done:
+		return nil, ErrSourceClosed
+	}
+}
+
+// Next returns the message from the next non-empty line
+func (source *readerSource) Next() (*Message, error) {
+	for {
+		line, err := source.readLine()
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+
+		source.offset++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		return &Message{
+			Topic:     source.topic,
+			Offset:    source.offset,
+			Value:     line,
+			Timestamp: time.Now(),
+		}, nil
+	}
+}
+
+// Close interrupts reading of messages and closes the underlying reader if
+// it needs to be closed
+func (source *readerSource) Close() error {
+	source.closeOnce.Do(func() {
+		close(source.done)
+	})
+
+	if source.closer == nil {
+		return nil
+	}
+
+	return source.closer.Close()
+}
+
+// fileSource reads messages stored as JSON lines from files, the files are
+// read one by one
+type fileSource struct {
+	paths []string
+	// mutex guards current and closed, because Close can be called while
+	// another goroutine is reading the messages
+	mutex   sync.Mutex
+	current *readerSource
+	closed  bool
+}
+
+// NewFileSource constructs source of messages read from the file or from all
+// files in the directory in alphabetical order. Path of the file is used as
+// topic of its messages.
+func NewFileSource(path string) (Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return &fileSource{paths: []string{path}}, nil
+	}
+
+	// entries are sorted by name already
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &fileSource{}
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			source.paths = append(source.paths, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	return source, nil
+}
+
+// Next returns the next message from the current file, next file is opened
+// once all messages from the current one have been read. ErrSourceClosed is
+// returned once the source is closed.
+func (source *fileSource) Next() (*Message, error) {
+	for {
+		current, err := source.currentReader()
+		if err != nil {
+			return nil, err
+		}
+
+		message, err := current.Next()
+		if err != io.EOF {
+			return message, err
+		}
+
+		if err := source.closeReader(current); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// currentReader returns source of messages of the file that is being read,
+// the next file is opened when there is none
+func (source *fileSource) currentReader() (*readerSource, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	if source.closed {
+		return nil, ErrSourceClosed
+	}
+
+	if source.current != nil {
+		return source.current, nil
+	}
+
+	if len(source.paths) == 0 {
+		return nil, io.EOF
+	}
+
+	file, err := os.Open(source.paths[0])
+	if err != nil {
+		return nil, err
+	}
+
+	source.current = NewReaderSource(source.paths[0], file).(*readerSource)
+	source.current.closer = file
+	source.paths = source.paths[1:]
+
+	return source.current, nil
+}
+
+// closeReader closes the file that has been read completely unless it has
+// been closed by Close already
+func (source *fileSource) closeReader(reader *readerSource) error {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	if source.current != reader {
+		return nil
+	}
+
+	source.current = nil
+
+	return reader.Close()
+}
+
+// Close closes the file that is being read, no more files are read then
+func (source *fileSource) Close() error {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	source.closed = true
+
+	if source.current == nil {
+		return nil
+	}
+
+	err := source.current.Close()
+	source.current = nil
+
+	return err
+}
@@ -0,0 +1,169 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+)
+
+// mustReadAllMessages reads messages from the source until io.EOF is returned
+func mustReadAllMessages(t *testing.T, source broker.Source) []*broker.Message {
+	var messages []*broker.Message
+
+	for {
+		message, err := source.Next()
+		if err == io.EOF {
+			return messages
+		}
+		helpers.FailOnError(t, err)
+
+		messages = append(messages, message)
+	}
+}
+
+func TestReaderSource(t *testing.T) {
+	source := broker.NewReaderSource("topic", strings.NewReader("{\"a\": 1}\n\n  \n{\"b\": 2}"))
+
+	messages := mustReadAllMessages(t, source)
+	assert.Len(t, messages, 2)
+
+	// empty lines are skipped, line number is used as offset
+	assert.Equal(t, "topic", messages[0].Topic)
+	assert.Equal(t, int64(1), messages[0].Offset)
+	assert.Equal(t, `{"a": 1}`, string(messages[0].Value))
+	assert.Equal(t, int64(4), messages[1].Offset)
+	assert.Equal(t, `{"b": 2}`, string(messages[1].Value))
+
+	helpers.FailOnError(t, source.Close())
+}
+
+// TestReaderSourceCloseBlocked checks that Close interrupts reading from the
+// reader that blocks, like standard input with no more input
+func TestReaderSourceCloseBlocked(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer func() {
+		helpers.FailOnError(t, writer.Close())
+	}()
+
+	source := broker.NewReaderSource(broker.StdinTopic, reader)
+
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		go func() {
+			// the reading blocks, because nothing is written into the pipe
+			time.Sleep(10 * time.Millisecond)
+			helpers.FailOnError(t, source.Close())
+		}()
+
+		_, err := source.Next()
+		assert.Equal(t, broker.ErrSourceClosed, err)
+	}, time.Second)
+
+	// closed source returns no more messages
+	_, err := source.Next()
+	assert.Equal(t, broker.ErrSourceClosed, err)
+}
+
+func TestReaderSourceLongLine(t *testing.T) {
+	longMessage := `{"a": "` + strings.Repeat("x", 1024*1024) + `"}`
+	source := broker.NewReaderSource("topic", strings.NewReader(longMessage+"\n"))
+
+	messages := mustReadAllMessages(t, source)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, longMessage, string(messages[0].Value))
+}
+
+func TestFileSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "messages")
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, os.RemoveAll(dir))
+	}()
+
+	helpers.FailOnError(t, ioutil.WriteFile(filepath.Join(dir, "2.jsonl"), []byte("{\"c\": 3}\n"), 0600))
+	helpers.FailOnError(t, ioutil.WriteFile(filepath.Join(dir, "1.jsonl"), []byte("{\"a\": 1}\n{\"b\": 2}\n"), 0600))
+	// nested directories are not read
+	helpers.FailOnError(t, os.Mkdir(filepath.Join(dir, "0"), 0700))
+
+	source, err := broker.NewSource(broker.Configuration{Type: broker.TypeFile, Path: dir})
+	helpers.FailOnError(t, err)
+
+	// files are read in alphabetical order
+	messages := mustReadAllMessages(t, source)
+	assert.Len(t, messages, 3)
+	assert.Equal(t, filepath.Join(dir, "1.jsonl"), messages[0].Topic)
+	assert.Equal(t, int64(2), messages[1].Offset)
+	assert.Equal(t, filepath.Join(dir, "2.jsonl"), messages[2].Topic)
+	assert.Equal(t, int64(1), messages[2].Offset)
+	assert.Equal(t, `{"c": 3}`, string(messages[2].Value))
+
+	helpers.FailOnError(t, source.Close())
+
+	// single file can be read too
+	source, err = broker.NewFileSource(filepath.Join(dir, "2.jsonl"))
+	helpers.FailOnError(t, err)
+	assert.Len(t, mustReadAllMessages(t, source), 1)
+	helpers.FailOnError(t, source.Close())
+}
+
+// TestFileSourceClose checks that no more messages are read once the source
+// is closed
+func TestFileSourceClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "messages")
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, os.RemoveAll(dir))
+	}()
+
+	helpers.FailOnError(t, ioutil.WriteFile(filepath.Join(dir, "1.jsonl"), []byte("{\"a\": 1}\n{\"b\": 2}\n"), 0600))
+	helpers.FailOnError(t, ioutil.WriteFile(filepath.Join(dir, "2.jsonl"), []byte("{\"c\": 3}\n"), 0600))
+
+	source, err := broker.NewFileSource(dir)
+	helpers.FailOnError(t, err)
+
+	_, err = source.Next()
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, source.Close())
+
+	_, err = source.Next()
+	assert.Equal(t, broker.ErrSourceClosed, err)
+}
+
+func TestFileSourceMissingPath(t *testing.T) {
+	_, err := broker.NewFileSource("/non-existing/messages.jsonl")
+	assert.Error(t, err)
+}
+
+func TestNewSource(t *testing.T) {
+	source, err := broker.NewSource(broker.Configuration{Type: broker.TypeStdin})
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, source.Close())
+
+	_, err = broker.NewSource(broker.Configuration{Type: broker.TypeKafka})
+	assert.EqualError(t, err, "broker of type 'kafka' is not a source of messages")
+}
@@ -58,6 +58,11 @@ const (
 	configFileEnvVariableName   = "INSIGHTS_RESULTS_AGGREGATOR_CONFIG_FILE"
 	defaultOrgAllowlistFileName = "org_allowlist.csv"
 	defaultContentPath          = "/rules-content"
+
+	// OrgAllowlistSourceFile selects CSV file as source of the organization allowlist
+	OrgAllowlistSourceFile = "file"
+	// OrgAllowlistSourceDB selects the org_allowlist table as source of the organization allowlist
+	OrgAllowlistSourceDB = "db"
 )
 
 // MetricsConfiguration holds metrics related configuration
@@ -70,9 +75,11 @@ type ConfigStruct struct {
 	Broker     broker.Configuration `mapstructure:"broker" toml:"broker"`
 	Server     server.Configuration `mapstructure:"server" toml:"server"`
 	Processing struct {
-		OrgAllowlistFile string `mapstructure:"org_allowlist_file" toml:"org_allowlist_file"`
+		OrgAllowlistFile   string `mapstructure:"org_allowlist_file" toml:"org_allowlist_file"`
+		OrgAllowlistSource string `mapstructure:"org_allowlist_source" toml:"org_allowlist_source"`
 	} `mapstructure:"processing"`
 	Storage           storage.Configuration             `mapstructure:"storage" toml:"storage"`
+	Retention         storage.RetentionConfiguration    `mapstructure:"retention" toml:"retention"`
 	Logging           logger.LoggingConfiguration       `mapstructure:"logging" toml:"logging"`
 	CloudWatch        logger.CloudWatchConfiguration    `mapstructure:"cloudwatch" toml:"cloudwatch"`
 	Metrics           MetricsConfiguration              `mapstructure:"metrics" toml:"metrics"`
@@ -83,6 +90,10 @@ type ConfigStruct struct {
 // Config has exactly the same structure as *.toml file
 var Config ConfigStruct
 
+// orgAllowlist is shared by all users of the broker configuration, so the
+// allowlist reloaded at runtime is used by all of them
+var orgAllowlist mapset.Set
+
 // LoadConfiguration loads configuration from defaultConfigFile, file set in
 // configFileEnvVariableName or from env or from Clowder.
 func LoadConfiguration(defaultConfigFile string) error {
@@ -137,6 +148,9 @@ func LoadConfiguration(defaultConfigFile string) error {
 		return fmt.Errorf("fatal - can not unmarshal configuration: %s", err)
 	}
 
+	// allowlist needs to be loaded again according to the new configuration
+	orgAllowlist = nil
+
 	if err := updateConfigFromClowder(&Config); err != nil {
 		fmt.Println("Error loading clowder configuration")
 		return err
@@ -153,26 +167,61 @@ func GetBrokerConfiguration() broker.Configuration {
 	return Config.Broker
 }
 
+// getOrganizationAllowlist returns the organization allowlist, the same
+// instance is returned until the configuration is loaded again
 func getOrganizationAllowlist() mapset.Set {
 	if !Config.Broker.OrgAllowlistEnabled {
 		return nil
 	}
 
-	if len(Config.Processing.OrgAllowlistFile) == 0 {
-		Config.Processing.OrgAllowlistFile = defaultOrgAllowlistFileName
+	if orgAllowlist != nil {
+		return orgAllowlist
 	}
 
-	orgAllowlistFileData, err := ioutil.ReadFile(Config.Processing.OrgAllowlistFile)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Organization allowlist file could not be opened")
+	switch GetOrgAllowlistSource() {
+	case OrgAllowlistSourceFile:
+		allowlist, err := LoadOrganizationAllowlistFile()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Organization allowlist could not be loaded")
+		}
+
+		orgAllowlist = allowlist
+	case OrgAllowlistSourceDB:
+		// organizations are read by the commands processing messages once
+		// the storage is available, the allowlist rejects all of them until then
+		orgAllowlist = mapset.NewSet()
+	default:
+		log.Fatal().Msgf("Unknown source of organization allowlist '%s'", Config.Processing.OrgAllowlistSource)
 	}
 
-	allowlist, err := loadAllowlistFromCSV(bytes.NewBuffer(orgAllowlistFileData))
+	return orgAllowlist
+}
+
+// GetOrgAllowlistSource returns the source of organization allowlist, CSV
+// file is used when it's not set
+func GetOrgAllowlistSource() string {
+	if len(Config.Processing.OrgAllowlistSource) == 0 {
+		return OrgAllowlistSourceFile
+	}
+
+	return Config.Processing.OrgAllowlistSource
+}
+
+// LoadOrganizationAllowlistFile reads the organization allowlist from the
+// CSV file set in the configuration. Unlike GetBrokerConfiguration it
+// returns errors, so it can be used to reload the allowlist at runtime.
+func LoadOrganizationAllowlistFile() (mapset.Set, error) {
+	fileName := Config.Processing.OrgAllowlistFile
+	if len(fileName) == 0 {
+		fileName = defaultOrgAllowlistFileName
+	}
+
+	orgAllowlistFileData, err := ioutil.ReadFile(fileName)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Allowlist CSV could not be processed")
+		return nil, fmt.Errorf("organization allowlist file could not be opened: %v", err)
 	}
 
-	return allowlist
+	return loadAllowlistFromCSV(bytes.NewBuffer(orgAllowlistFileData))
 }
 
 // GetStorageConfiguration returns storage configuration
@@ -180,6 +229,11 @@ func GetStorageConfiguration() storage.Configuration {
 	return Config.Storage
 }
 
+// GetRetentionConfiguration returns data retention configuration
+func GetRetentionConfiguration() storage.RetentionConfiguration {
+	return Config.Retention
+}
+
 // GetLoggingConfiguration returns logging configuration
 func GetLoggingConfiguration() logger.LoggingConfiguration {
 	return Config.Logging
@@ -220,6 +220,11 @@ func TestLoadConfigurationFromFile(t *testing.T) {
 		pg_db_name = "aggregator"
 		pg_params = "params"
 		log_sql_queries = true
+
+		[retention]
+		report_max_age_days = 90
+		consumer_error_max_age_days = 30
+		cleanup_interval_hours = 24
 	`
 
 	tmpFilename, err := GetTmpConfigFile(config)
@@ -274,6 +279,12 @@ func TestLoadConfigurationFromFile(t *testing.T) {
 		PGDBName:         "aggregator",
 		PGParams:         "params",
 	}, conf.GetStorageConfiguration())
+
+	assert.Equal(t, storage.RetentionConfiguration{
+		ReportMaxAgeDays:        90,
+		ConsumerErrorMaxAgeDays: 30,
+		CleanupIntervalHours:    24,
+	}, conf.GetRetentionConfiguration())
 }
 
 func GetTmpConfigFile(configData string) (string, error) {
@@ -346,6 +357,12 @@ func TestLoadConfigurationFromEnv(t *testing.T) {
 		PGDBName:         "aggregator",
 		PGParams:         "params",
 	}, conf.GetStorageConfiguration())
+
+	assert.Equal(t, storage.RetentionConfiguration{
+		ReportMaxAgeDays:        90,
+		ConsumerErrorMaxAgeDays: 30,
+		CleanupIntervalHours:    24,
+	}, conf.GetRetentionConfiguration())
 }
 
 func TestGetLoggingConfigurationDefault(t *testing.T) {
@@ -427,3 +444,72 @@ func setEnvVariables(t *testing.T) {
 
 	mustSetEnv(t, "INSIGHTS_RESULTS_AGGREGATOR__CONTENT__PATH", "/rules-content")
 }
+
+// mustLoadAllowlistConfiguration loads configuration with enabled organization
+// allowlist read from the given source
+func mustLoadAllowlistConfiguration(t *testing.T, source, fileName string) {
+	config := `[broker]
+		enable_org_allowlist = true
+
+		[processing]
+		org_allowlist_source = "` + source + `"
+		org_allowlist_file = "` + fileName + `"
+	`
+
+	tmpFilename, err := GetTmpConfigFile(config)
+	helpers.FailOnError(t, err)
+	defer removeFile(t, tmpFilename)
+
+	os.Clearenv()
+	mustSetEnv(t, conf.ConfigFileEnvVariableName, tmpFilename)
+	mustLoadConfiguration("foobar")
+}
+
+// TestOrgAllowlistIsShared tests that the same allowlist is used by all users
+// of the broker configuration until the configuration is loaded again
+func TestOrgAllowlistIsShared(t *testing.T) {
+	allowlistFile, err := GetTmpConfigFile("OrgID\n1\n2\n")
+	helpers.FailOnError(t, err)
+	defer removeFile(t, allowlistFile)
+
+	mustLoadAllowlistConfiguration(t, conf.OrgAllowlistSourceFile, allowlistFile)
+
+	allowlist := conf.GetBrokerConfiguration().OrgAllowlist
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(1), types.OrgID(2))))
+
+	helpers.FailOnError(t, ioutil.WriteFile(allowlistFile, []byte("OrgID\n3\n"), 0600))
+
+	// the file is not read again
+	assert.True(t, allowlist == conf.GetBrokerConfiguration().OrgAllowlist)
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(1), types.OrgID(2))))
+
+	reloaded, err := conf.LoadOrganizationAllowlistFile()
+	helpers.FailOnError(t, err)
+	assert.True(t, reloaded.Equal(mapset.NewSetWith(types.OrgID(3))))
+
+	mustLoadAllowlistConfiguration(t, conf.OrgAllowlistSourceFile, allowlistFile)
+	assert.True(t, conf.GetBrokerConfiguration().OrgAllowlist.Equal(mapset.NewSetWith(types.OrgID(3))))
+}
+
+// TestOrgAllowlistFromDB tests that allowlist read from the DB is empty until
+// it's loaded from the storage
+func TestOrgAllowlistFromDB(t *testing.T) {
+	mustLoadAllowlistConfiguration(t, conf.OrgAllowlistSourceDB, "")
+
+	assert.Equal(t, conf.OrgAllowlistSourceDB, conf.GetOrgAllowlistSource())
+	assert.Equal(t, 0, conf.GetBrokerConfiguration().OrgAllowlist.Cardinality())
+}
+
+// TestLoadOrganizationAllowlistFileNotFound tests that missing allowlist file
+// is reported as an error
+func TestLoadOrganizationAllowlistFileNotFound(t *testing.T) {
+	mustLoadAllowlistConfiguration(t, "", "/non/existing/allowlist.csv")
+
+	assert.Equal(t, conf.OrgAllowlistSourceFile, conf.GetOrgAllowlistSource())
+
+	_, err := conf.LoadOrganizationAllowlistFile()
+	assert.EqualError(
+		t, err,
+		"organization allowlist file could not be opened: open /non/existing/allowlist.csv: no such file or directory",
+	)
+}
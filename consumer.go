@@ -24,7 +24,7 @@ import (
 )
 
 var (
-	consumerInstance                                                 *consumer.KafkaConsumer
+	consumerInstance                                                 consumer.Consumer
 	consumerInstanceIsStarting, finishConsumerInstanceInitialization = context.WithCancel(context.Background())
 )
 
@@ -41,7 +41,7 @@ func startConsumer(brokerConf broker.Configuration) error {
 
 	defer closeStorage(dbStorage)
 
-	consumerInstance, err = consumer.New(brokerConf, dbStorage)
+	consumerInstance, err = consumer.NewFromConfiguration(brokerConf, dbStorage)
 	if err != nil {
 		log.Error().Err(err).Msg("Broker initialization error")
 		return err
@@ -26,6 +26,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/consumer"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
@@ -164,3 +165,43 @@ func BenchmarkKafkaConsumer_ProcessMessage_RealMessages(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkKafkaConsumer_HandleMessages_RealMessages(b *testing.B) {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	messages := getMessagesFromDir(b, "../utils/produce_insights_results/")
+
+	var testCases = []struct {
+		Name            string
+		StorageProducer func(testing.TB, bool) (storage.Storage, func())
+	}{
+		{"NoopStorage", getNoopStorage},
+		{"SQLiteInMemory", ira_helpers.MustGetSQLiteMemoryStorage},
+		{"Postgres", ira_helpers.MustGetPostgresStorage},
+		{"SQLiteFile", ira_helpers.MustGetSQLiteFileStorage},
+	}
+
+	for _, testCase := range testCases {
+		b.Run(testCase.Name, func(b *testing.B) {
+			benchStorage, cleaner := testCase.StorageProducer(b, true)
+			if cleaner != nil {
+				defer cleaner()
+			}
+			defer ira_helpers.MustCloseStorage(b, benchStorage)
+
+			kafkaConsumer := &consumer.KafkaConsumer{
+				Storage: benchStorage,
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// all messages are written in one batch
+				batch := make([]*broker.Message, 0, len(messages))
+				for _, message := range messages {
+					batch = append(batch, &broker.Message{Value: []byte(message)})
+				}
+				kafkaConsumer.HandleMessages(batch)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// reportClusterInfo contains attributes of the cluster that are searched in
+// the system metadata and in details of info items of the report
+type reportClusterInfo struct {
+	Version     string `json:"version"`
+	Platform    string `json:"platform"`
+	Nodes       int    `json:"nodes"`
+	MasterNodes int    `json:"master_nodes"`
+	WorkerNodes int    `json:"worker_nodes"`
+}
+
+// reportSystem is the system section of the report
+type reportSystem struct {
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// reportInfoItem is an item of the info section of the report
+type reportInfoItem struct {
+	Details json.RawMessage `json:"details"`
+}
+
+// extractClusterMetadata extracts metadata of the cluster from the message.
+// Attributes are read from the system metadata first and then from details
+// of info items, so the info section takes precedence. Sections that can't be
+// parsed are skipped, missing attributes are left empty.
+func extractClusterMetadata(message incomingMessage, lastCheckedTime time.Time) storage.ClusterMetadata {
+	var info reportClusterInfo
+
+	if message.Report != nil {
+		report := *message.Report
+
+		var system reportSystem
+		if readReportSection(report, "system", &system) {
+			decodeClusterInfo(system.Metadata, &info)
+		}
+
+		var infoItems []reportInfoItem
+		if readReportSection(report, "info", &infoItems) {
+			for _, item := range infoItems {
+				decodeClusterInfo(item.Details, &info)
+			}
+		}
+	}
+
+	return storage.ClusterMetadata{
+		OrgID:         *message.Organization,
+		ClusterName:   *message.ClusterName,
+		OCPVersion:    info.Version,
+		Platform:      info.Platform,
+		Nodes:         info.Nodes,
+		MasterNodes:   info.MasterNodes,
+		WorkerNodes:   info.WorkerNodes,
+		RequestID:     message.RequestID,
+		SchemaVersion: message.Version,
+		LastCheckedAt: lastCheckedTime,
+	}
+}
+
+// readReportSection decodes the section of the report, false is returned
+// when the section is missing or has unexpected structure
+func readReportSection(report Report, key string, section interface{}) bool {
+	raw, found := report[key]
+	if !found || raw == nil {
+		return false
+	}
+
+	if err := json.Unmarshal(*raw, section); err != nil {
+		log.Debug().Err(err).Msgf("Unable to read cluster metadata from '%v' section of the report", key)
+		return false
+	}
+
+	return true
+}
+
+// decodeClusterInfo overwrites attributes of the cluster by non-empty ones
+// found in the JSON object, the whole object is skipped when any of the
+// attributes has unexpected type
+func decodeClusterInfo(raw json.RawMessage, info *reportClusterInfo) {
+	if len(raw) == 0 {
+		return
+	}
+
+	var decoded reportClusterInfo
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		log.Debug().Err(err).Msg("Unable to read cluster metadata from the report")
+		return
+	}
+
+	if decoded.Version != "" {
+		info.Version = decoded.Version
+	}
+	if decoded.Platform != "" {
+		info.Platform = decoded.Platform
+	}
+	if decoded.Nodes != 0 {
+		info.Nodes = decoded.Nodes
+	}
+	if decoded.MasterNodes != 0 {
+		info.MasterNodes = decoded.MasterNodes
+	}
+	if decoded.WorkerNodes != 0 {
+		info.WorkerNodes = decoded.WorkerNodes
+	}
+}
+
+// storeClusterMetadata writes metadata of the cluster from the message into
+// the storage. Errors are only logged, because the report has been stored
+// already.
+func (consumer *KafkaConsumer) storeClusterMetadata(message incomingMessage, lastCheckedTime time.Time) {
+	err := consumer.Storage.WriteClusterMetadata(extractClusterMetadata(message, lastCheckedTime))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to write cluster metadata")
+	}
+}
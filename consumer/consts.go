@@ -37,4 +37,7 @@ const (
 	versionKey = "version"
 	// CurrentSchemaVersion represents the currently supported data schema version
 	CurrentSchemaVersion = types.SchemaVersion(1)
+	// decisions of organization allow list used as label of the OrgAllowlistMessages metric
+	allowlistAllowed = "allowed"
+	allowlistDenied  = "denied"
 )
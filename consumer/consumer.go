@@ -15,11 +15,13 @@ limitations under the License.
 */
 
 // Package consumer contains interface for any consumer that is able to
-// process messages. It also contains implementation of Kafka consumer.
+// process messages. It also contains implementation of Kafka consumer and
+// consumer of messages read from other sources (see broker.Source).
 package consumer
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/Shopify/sarama"
 	"github.com/rs/zerolog/log"
@@ -34,7 +36,7 @@ import (
 type Consumer interface {
 	Serve()
 	Close() error
-	ProcessMessage(msg *sarama.ConsumerMessage) (types.RequestID, error)
+	ProcessMessage(msg *broker.Message) (types.RequestID, error)
 }
 
 // KafkaConsumer in an implementation of Consumer interface
@@ -59,7 +61,7 @@ type KafkaConsumer struct {
 	numberOfErrorsConsumingMessages      uint64
 	ready                                chan bool
 	cancel                               context.CancelFunc
-	payloadTrackerProducer               *producer.KafkaProducer
+	kafkaProducer                        *producer.KafkaProducer
 }
 
 // DefaultSaramaConfig is a config which will be used by default
@@ -94,7 +96,7 @@ func NewWithSaramaConfig(
 		return nil, err
 	}
 
-	payloadTrackerProducer, err := producer.New(brokerCfg)
+	kafkaProducer, err := producer.New(brokerCfg)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to construct producer")
 		return nil, err
@@ -107,7 +109,7 @@ func NewWithSaramaConfig(
 		numberOfSuccessfullyConsumedMessages: 0,
 		numberOfErrorsConsumingMessages:      0,
 		ready:                                make(chan bool),
-		payloadTrackerProducer:               payloadTrackerProducer,
+		kafkaProducer:                        kafkaProducer,
 	}
 
 	return consumer, nil
@@ -166,6 +168,9 @@ func (consumer *KafkaConsumer) Cleanup(sarama.ConsumerGroupSession) error {
 }
 
 // ConsumeClaim starts a consumer loop of ConsumerGroupClaim's Messages().
+// Messages are processed concurrently by a pool of workers (see
+// broker.Configuration.Workers), messages for the same cluster are processed
+// in order by the same worker.
 func (consumer *KafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	log.Info().
 		Int64(offsetKey, claim.InitialOffset()).
@@ -177,24 +182,49 @@ func (consumer *KafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession,
 		latestMessageOffset = 0
 	}
 
-	for message := range claim.Messages() {
+	tracker := newOffsetTracker()
+	queues, workers := consumer.startWorkers(session, tracker)
+
+	for consumed := range claim.Messages() {
+		message := newMessage(consumed)
+
 		if types.KafkaOffset(message.Offset) <= latestMessageOffset {
 			log.Warn().
 				Int64(offsetKey, message.Offset).
 				Msg("this offset was already processed by aggregator")
 		}
 
-		consumer.HandleMessage(message)
+		// the message has to be tracked before any worker can finish it
+		tracker.add(message)
+		queues[workerIndex(message, len(queues))] <- message
 
-		session.MarkMessage(message, "")
 		if types.KafkaOffset(message.Offset) > latestMessageOffset {
 			latestMessageOffset = types.KafkaOffset(message.Offset)
 		}
 	}
 
+	// wait for all workers to process remaining messages
+	for _, queue := range queues {
+		close(queue)
+	}
+	workers.Wait()
+
 	return nil
 }
 
+// newMessage converts the message consumed from Kafka into broker-neutral
+// message
+func newMessage(msg *sarama.ConsumerMessage) *broker.Message {
+	return &broker.Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Timestamp: msg.Timestamp,
+	}
+}
+
 // Close method closes all resources used by consumer
 func (consumer *KafkaConsumer) Close() error {
 	if consumer.cancel != nil {
@@ -207,8 +237,8 @@ func (consumer *KafkaConsumer) Close() error {
 		}
 	}
 
-	if consumer.payloadTrackerProducer != nil {
-		if err := consumer.payloadTrackerProducer.Close(); err != nil {
+	if consumer.kafkaProducer != nil {
+		if err := consumer.kafkaProducer.Close(); err != nil {
 			log.Error().Err(err).Msg("unable to close payload tracker Kafka producer")
 		}
 	}
@@ -219,11 +249,11 @@ func (consumer *KafkaConsumer) Close() error {
 // GetNumberOfSuccessfullyConsumedMessages returns number of consumed messages
 // since creating KafkaConsumer obj
 func (consumer *KafkaConsumer) GetNumberOfSuccessfullyConsumedMessages() uint64 {
-	return consumer.numberOfSuccessfullyConsumedMessages
+	return atomic.LoadUint64(&consumer.numberOfSuccessfullyConsumedMessages)
 }
 
 // GetNumberOfErrorsConsumingMessages returns number of errors during consuming messages
 // since creating KafkaConsumer obj
 func (consumer *KafkaConsumer) GetNumberOfErrorsConsumingMessages() uint64 {
-	return consumer.numberOfErrorsConsumingMessages
+	return atomic.LoadUint64(&consumer.numberOfErrorsConsumingMessages)
 }
@@ -29,6 +29,7 @@ import (
 	"github.com/RedHatInsights/insights-operator-utils/tests/saramahelpers"
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
 	mapset "github.com/deckarep/golang-set"
 	"github.com/rs/zerolog"
 	zerolog_log "github.com/rs/zerolog/log"
@@ -36,6 +37,7 @@ import (
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -64,8 +66,21 @@ func init() {
 	zerolog.SetGlobalLevel(zerolog.WarnLevel)
 }
 
+// captureLogs redirects the global logger into the returned buffer, the
+// returned function restores the original logger, so the buffer isn't written
+// by goroutines of other tests
+func captureLogs() (*bytes.Buffer, func()) {
+	buf := new(bytes.Buffer)
+	originalLogger := zerolog_log.Logger
+	zerolog_log.Logger = zerolog.New(buf)
+
+	return buf, func() {
+		zerolog_log.Logger = originalLogger
+	}
+}
+
 func consumerProcessMessage(mockConsumer consumer.Consumer, message string) error {
-	saramaMessage := sarama.ConsumerMessage{}
+	saramaMessage := broker.Message{}
 	saramaMessage.Value = []byte(message)
 	_, err := mockConsumer.ProcessMessage(&saramaMessage)
 	return err
@@ -101,7 +116,7 @@ func TestParseMessageWithWrongContent(t *testing.T) {
 	const message = `{"this":"is", "not":"expected content"}`
 	_, err := consumer.ParseMessage([]byte(message))
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "missing required attribute")
+	assert.Contains(t, err.Error(), "message violates schema version 1 at '/OrgID'")
 }
 
 func TestParseMessageWithImproperJSON(t *testing.T) {
@@ -110,7 +125,7 @@ func TestParseMessageWithImproperJSON(t *testing.T) {
 	assert.EqualError(
 		t,
 		err,
-		"json: cannot unmarshal string into Go value of type consumer.incomingMessage",
+		"message violates schema version 1 at '/': Field must be set to object or not be present",
 	)
 }
 
@@ -134,7 +149,7 @@ func TestParseMessageWithImproperReport(t *testing.T) {
 	assert.EqualError(
 		t,
 		err,
-		"json: cannot unmarshal string into Go value of type []types.ReportItem",
+		"message violates schema version 1 at '/Report/reports': Field must be set to array or not be present",
 	)
 }
 
@@ -157,6 +172,7 @@ func TestParseProperMessageWrongClusterName(t *testing.T) {
 	message := `{
 		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
 		"ClusterName": "this is not a UUID",
+		"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
 		"Report": ` + testdata.ConsumerReport + `
 	}`
 	_, err := consumer.ParseMessage([]byte(message))
@@ -169,7 +185,7 @@ func TestParseMessageWithoutOrgID(t *testing.T) {
 		"Report": ` + testdata.ConsumerReport + `
 	}`
 	_, err := consumer.ParseMessage([]byte(message))
-	assert.EqualError(t, err, "missing required attribute 'OrgID'")
+	assert.EqualError(t, err, "message violates schema version 1 at '/OrgID': Property 'OrgID' is missing")
 }
 
 func TestParseMessageWithoutClusterName(t *testing.T) {
@@ -178,7 +194,9 @@ func TestParseMessageWithoutClusterName(t *testing.T) {
 		"Report": ` + testdata.ConsumerReport + `
 	}`
 	_, err := consumer.ParseMessage([]byte(message))
-	assert.EqualError(t, err, "missing required attribute 'ClusterName'")
+	assert.EqualError(
+		t, err, "message violates schema version 1 at '/ClusterName': Property 'ClusterName' is missing",
+	)
 }
 
 func TestParseMessageWithoutReport(t *testing.T) {
@@ -187,7 +205,7 @@ func TestParseMessageWithoutReport(t *testing.T) {
 		"ClusterName": "` + string(testdata.ClusterName) + `"
 	}`
 	_, err := consumer.ParseMessage([]byte(message))
-	assert.EqualError(t, err, "missing required attribute 'Report'")
+	assert.EqualError(t, err, "message violates schema version 1 at '/Report': Property 'Report' is missing")
 }
 
 func TestParseMessageEmptyReport(t *testing.T) {
@@ -198,7 +216,9 @@ func TestParseMessageEmptyReport(t *testing.T) {
 	}`
 
 	_, err := consumer.ParseMessage([]byte(message))
-	assert.EqualError(t, err, "Improper report structure, missing key fingerprints")
+	assert.EqualError(
+		t, err, "message violates schema version 1 at '/Report/fingerprints': Property 'fingerprints' is missing",
+	)
 }
 
 func TestParseMessageNullReport(t *testing.T) {
@@ -209,7 +229,7 @@ func TestParseMessageNullReport(t *testing.T) {
 	}`
 
 	_, err := consumer.ParseMessage([]byte(message))
-	assert.EqualError(t, err, "missing required attribute 'Report'")
+	assert.EqualError(t, err, "message violates schema version 1 at '/Report': Value is not nullable")
 }
 
 func dummyConsumer(s storage.Storage, allowlist bool) consumer.Consumer {
@@ -236,7 +256,7 @@ func TestProcessEmptyMessage(t *testing.T) {
 
 	c := dummyConsumer(mockStorage, true)
 
-	message := sarama.ConsumerMessage{}
+	message := broker.Message{}
 	// message is empty -> nothing should be written into storage
 	_, err := c.ProcessMessage(&message)
 	assert.EqualError(t, err, "unexpected end of JSON input")
@@ -258,7 +278,7 @@ func TestProcessCorrectMessage(t *testing.T) {
 
 	c := dummyConsumer(mockStorage, true)
 
-	message := sarama.ConsumerMessage{}
+	message := broker.Message{}
 	message.Value = []byte(testdata.ConsumerMessage)
 	// message is empty -> nothing should be written into storage
 	_, err := c.ProcessMessage(&message)
@@ -461,8 +481,8 @@ func TestKafkaConsumer_ProcessMessage_OrganizationBadConfigIsNotAllowed(t *testi
 }
 
 func TestKafkaConsumer_ProcessMessage_MessageFromTheFuture(t *testing.T) {
-	buf := new(bytes.Buffer)
-	zerolog_log.Logger = zerolog.New(buf)
+	buf, restoreLogger := captureLogs()
+	defer restoreLogger()
 
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -486,8 +506,8 @@ func TestKafkaConsumer_ProcessMessage_MessageFromTheFuture(t *testing.T) {
 
 func TestKafkaConsumer_ProcessMessage_MoreRecentReportAlreadyExists(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	buf := new(bytes.Buffer)
-	zerolog_log.Logger = zerolog.New(buf)
+	buf, restoreLogger := captureLogs()
+	defer restoreLogger()
 
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -521,8 +541,8 @@ func TestKafkaConsumer_ProcessMessage_MoreRecentReportAlreadyExists(t *testing.T
 }
 
 func TestKafkaConsumer_ProcessMessage_MessageWithUnexpectedSchemaVersion(t *testing.T) {
-	buf := new(bytes.Buffer)
-	zerolog_log.Logger = zerolog.New(buf)
+	buf, restoreLogger := captureLogs()
+	defer restoreLogger()
 
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -539,8 +559,8 @@ func TestKafkaConsumer_ProcessMessage_MessageWithUnexpectedSchemaVersion(t *test
 }
 
 func TestKafkaConsumer_ProcessMessage_MessageWithExpectedSchemaVersion(t *testing.T) {
-	buf := new(bytes.Buffer)
-	zerolog_log.Logger = zerolog.New(buf)
+	buf, restoreLogger := captureLogs()
+	defer restoreLogger()
 
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -564,6 +584,62 @@ func TestKafkaConsumer_ProcessMessage_MessageWithExpectedSchemaVersion(t *testin
 	assert.NotContains(t, buf.String(), "Received data with unexpected version")
 }
 
+func TestKafkaConsumer_ProcessMessage_NotifyNewRuleHits(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.NotificationTopic = "notification-topic"
+
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	kafkaProducer := &producer.KafkaProducer{
+		Configuration: brokerCfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+	mockConsumer.SetKafkaProducer(kafkaProducer)
+
+	lastChecked := time.Now().Add(-time.Hour)
+
+	// report without any rule hit
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report":` + testdata.ConsumerReport + `,
+		"LastChecked": "` + lastChecked.Format(time.RFC3339) + `"
+	}`
+	mustConsumerProcessMessage(t, mockConsumer, message)
+
+	// all three rule hits are new, so three notifications are expected
+	for i := 0; i < 3; i++ {
+		mockProducer.ExpectSendMessageAndSucceed()
+	}
+
+	message = `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report":` + string(testdata.Report3Rules) + `,
+		"LastChecked": "` + lastChecked.Add(time.Minute).Format(time.RFC3339) + `"
+	}`
+	mustConsumerProcessMessage(t, mockConsumer, message)
+
+	// nothing is new in the same report
+	message = `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report":` + string(testdata.Report3Rules) + `,
+		"LastChecked": "` + lastChecked.Add(2*time.Minute).Format(time.RFC3339) + `"
+	}`
+	mustConsumerProcessMessage(t, mockConsumer, message)
+}
+
 func TestKafkaConsumer_ConsumeClaim(t *testing.T) {
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -580,8 +656,8 @@ func TestKafkaConsumer_ConsumeClaim(t *testing.T) {
 }
 
 func TestKafkaConsumer_ConsumeClaim_DBError(t *testing.T) {
-	buf := new(bytes.Buffer)
-	zerolog_log.Logger = zerolog.New(buf)
+	buf, restoreLogger := captureLogs()
+	defer restoreLogger()
 
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	closer()
@@ -641,3 +717,99 @@ func TestKafkaConsumer_SetupCleanup(t *testing.T) {
 	helpers.FailOnError(t, mockConsumer.Setup(nil))
 	helpers.FailOnError(t, mockConsumer.Cleanup(nil))
 }
+
+func TestKafkaConsumer_ProcessMessage_ClusterMetadata(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	lastChecked := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report": {
+			"system": {"metadata": {"version": "4.6.1", "platform": "AWS"}, "hostname": null},
+			"reports": [],
+			"fingerprints": [],
+			"skips": [],
+			"info": [
+				{"key": "NODES", "details": {"nodes": 5, "master_nodes": 3, "worker_nodes": 2}},
+				{"key": "PLATFORM", "details": {"platform": "GCP"}},
+				{"key": "OTHER", "details": {"nodes": ["unexpected"]}}
+			]
+		},
+		"LastChecked": "` + lastChecked.Format(time.RFC3339) + `",
+		"Version": ` + fmt.Sprint(consumer.CurrentSchemaVersion) + `,
+		"RequestId": "request-1"
+	}`
+	mustConsumerProcessMessage(t, mockConsumer, message)
+
+	metadata, err := mockStorage.ReadClusterMetadata(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, "4.6.1", metadata.OCPVersion)
+	// info section takes precedence over system metadata
+	assert.Equal(t, "GCP", metadata.Platform)
+	assert.Equal(t, 5, metadata.Nodes)
+	assert.Equal(t, 3, metadata.MasterNodes)
+	assert.Equal(t, 2, metadata.WorkerNodes)
+	assert.Equal(t, types.RequestID("request-1"), metadata.RequestID)
+	assert.Equal(t, consumer.CurrentSchemaVersion, metadata.SchemaVersion)
+	assert.True(t, lastChecked.Equal(metadata.LastCheckedAt))
+}
+
+func TestKafkaConsumer_ProcessMessage_ClusterMetadataEmpty(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	mustConsumerProcessMessage(t, mockConsumer, testdata.ConsumerMessage)
+
+	metadata, err := mockStorage.ReadClusterMetadata(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, metadata.OCPVersion)
+	assert.Empty(t, metadata.Platform)
+	assert.Equal(t, 0, metadata.Nodes)
+}
+
+func TestKafkaConsumer_ProcessReport(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	_, err := kafkaConsumer.ProcessReport(&broker.Message{Value: []byte(testdata.ConsumerMessage)})
+	helpers.FailOnError(t, err)
+
+	// errors found in the message are distinguished from other errors
+	_, err = kafkaConsumer.ProcessReport(&broker.Message{Value: []byte("not a JSON")})
+	assert.IsType(t, &consumer.InvalidMessageError{}, err)
+
+}
+
+func TestKafkaConsumer_ProcessReport_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	_, err := kafkaConsumer.ProcessReport(&broker.Message{Value: []byte(testdata.ConsumerMessage)})
+	assert.Error(t, err)
+
+	_, isInvalid := err.(*consumer.InvalidMessageError)
+	assert.False(t, isInvalid)
+}
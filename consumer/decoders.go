@@ -0,0 +1,145 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// label of the ConsumedMessagesByVersion metric used for messages with
+// schema version that isn't supported
+const unsupportedVersionLabel = "unsupported"
+
+// messageDecoder decodes incoming message of one schema version and
+// normalizes it into incomingMessage, so the rest of the processing doesn't
+// depend on the layout of the message. The normalized report is in the layout
+// of the current schema version, because it's stored as is and read by other
+// parts of the service later.
+type messageDecoder func(messageValue []byte) (incomingMessage, error)
+
+// messageDecoders is registry of decoders of all supported schema versions,
+// the layout of each version is described by its JSON schema (see
+// messageSchemas)
+var messageDecoders = map[types.SchemaVersion]messageDecoder{
+	1: decodeMessageV1,
+	2: decodeMessageV2,
+}
+
+// reportItemV2 is rule hit in the report of schema version 2
+type reportItemV2 struct {
+	RuleFQDN types.RuleID    `json:"rule_fqdn"`
+	ErrorKey types.ErrorKey  `json:"error_key"`
+	Details  json.RawMessage `json:"details"`
+}
+
+// isSupportedVersion returns true when messages of the given schema version
+// can be decoded
+func isSupportedVersion(version types.SchemaVersion) bool {
+	_, found := messageDecoders[version]
+	return found
+}
+
+// decoderForVersion returns decoder of messages of the given version.
+// Decoder of the current version is used for messages with unknown version,
+// which are only warned about (see checkMessageVersion).
+func decoderForVersion(version types.SchemaVersion) messageDecoder {
+	if decoder, found := messageDecoders[version]; found {
+		return decoder
+	}
+
+	return messageDecoders[CurrentSchemaVersion]
+}
+
+// readMessageVersion reads just the schema version of the incoming message,
+// messages without version attribute are of version 0. Improper messages are
+// of version 0 too, the error is reported by their decoder.
+func readMessageVersion(messageValue []byte) types.SchemaVersion {
+	var versioned struct {
+		Version types.SchemaVersion `json:"Version"`
+	}
+
+	if err := json.Unmarshal(messageValue, &versioned); err != nil {
+		return 0
+	}
+
+	return versioned.Version
+}
+
+// decodeMessageV1 decodes message of schema version 1, which is the layout
+// of incomingMessage
+func decodeMessageV1(messageValue []byte) (incomingMessage, error) {
+	var deserialized incomingMessage
+
+	err := json.Unmarshal(messageValue, &deserialized)
+	return deserialized, err
+}
+
+// decodeMessageV2 decodes message of schema version 2, in which the rule hits
+// are identified by "rule_fqdn" and "error_key" attributes instead of
+// "component" and "key". Rule hits are converted into the layout of version 1.
+func decodeMessageV2(messageValue []byte) (incomingMessage, error) {
+	deserialized, err := decodeMessageV1(messageValue)
+	if err != nil {
+		return deserialized, err
+	}
+
+	// missing attributes are reported by parseMessage
+	if deserialized.Report == nil {
+		return deserialized, nil
+	}
+	reportItems, found := (*deserialized.Report)["reports"]
+	if !found || reportItems == nil {
+		return deserialized, nil
+	}
+
+	var itemsV2 []reportItemV2
+	err = json.Unmarshal(*reportItems, &itemsV2)
+	if err != nil {
+		return deserialized, err
+	}
+
+	items := make([]types.ReportItem, len(itemsV2))
+	for i, item := range itemsV2 {
+		items[i] = types.ReportItem{
+			Module:       item.RuleFQDN,
+			ErrorKey:     item.ErrorKey,
+			TemplateData: item.Details,
+		}
+	}
+
+	itemsV1, err := json.Marshal(items)
+	if err != nil {
+		return deserialized, err
+	}
+
+	converted := json.RawMessage(itemsV1)
+	(*deserialized.Report)["reports"] = &converted
+
+	return deserialized, nil
+}
+
+// countMessageVersion updates the metric of consumed messages by version
+func countMessageVersion(version types.SchemaVersion) {
+	label := unsupportedVersionLabel
+	if isSupportedVersion(version) {
+		label = strconv.Itoa(int(version))
+	}
+
+	metrics.ConsumedMessagesByVersion.WithLabelValues(label).Inc()
+}
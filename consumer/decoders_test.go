@@ -0,0 +1,123 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// messageV2WithReportItems returns message of schema version 2 with the given
+// rule hits
+func messageV2WithReportItems(reports string) string {
+	return `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+		"Version": 2,
+		"Report": {
+			"fingerprints": [],
+			"info": [],
+			"reports": ` + reports + `,
+			"skips": [],
+			"system": {"metadata": {}, "hostname": null}
+		}
+	}`
+}
+
+func TestParseMessageV2(t *testing.T) {
+	message := messageV2WithReportItems(`[
+		{"rule_fqdn": "rule.module", "error_key": "KEY", "details": {"nodes": []}}
+	]`)
+
+	parsed, err := consumer.ParseMessage([]byte(message))
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, types.SchemaVersion(2), parsed.Version)
+	assert.Equal(t, []types.ReportItem{{
+		Module:       "rule.module",
+		ErrorKey:     "KEY",
+		TemplateData: json.RawMessage(`{"nodes":[]}`),
+	}}, parsed.ParsedHits)
+
+	// report is normalized into the layout of the current version
+	var reportItems []map[string]interface{}
+	err = json.Unmarshal(*(*parsed.Report)["reports"], &reportItems)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []map[string]interface{}{{
+		"component": "rule.module",
+		"key":       "KEY",
+		"details":   map[string]interface{}{"nodes": []interface{}{}},
+	}}, reportItems)
+}
+
+func TestParseMessageV2WithReportItemsV1(t *testing.T) {
+	message := messageV2WithReportItems(`[
+		{"component": "rule.module", "key": "KEY", "details": {}}
+	]`)
+
+	_, err := consumer.ParseMessage([]byte(message))
+
+	violation, ok := err.(*consumer.SchemaViolationError)
+	if !assert.True(t, ok, "schema violation is expected, got %v", err) {
+		return
+	}
+
+	assert.Equal(t, types.SchemaVersion(2), violation.Version)
+	assert.True(t, strings.HasPrefix(violation.Path, "/Report/reports/0/"), violation.Path)
+	assert.Equal(t, "required", violation.Keyword)
+}
+
+func TestKafkaConsumer_HandleMessage_MessageV2(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	message := messageV2WithReportItems(`[
+		{"rule_fqdn": "rule.module.report", "error_key": "KEY", "details": {}}
+	]`)
+	kafkaConsumer.HandleMessage(&broker.Message{
+		Topic: testTopicName,
+		Value: []byte(message),
+	})
+
+	assert.Equal(t, uint64(0), kafkaConsumer.GetNumberOfErrorsConsumingMessages())
+
+	rules, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, types.ErrorKey("KEY"), rules[0].ErrorKey)
+	}
+
+	reports, err := mockStorage.ReadReportsForClusters([]types.ClusterName{testdata.ClusterName})
+	helpers.FailOnError(t, err)
+	assert.Contains(t, string(reports[testdata.ClusterName]), `"component":"rule.module.report"`)
+	assert.NotContains(t, string(reports[testdata.ClusterName]), "rule_fqdn")
+}
@@ -16,6 +16,13 @@ limitations under the License.
 
 package consumer
 
+import (
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+)
+
 // Export for testing
 //
 // This source file contains name aliases of all package-private functions
@@ -28,4 +35,24 @@ package consumer
 var (
 	ParseMessage         = parseMessage
 	CheckReportStructure = checkReportStructure
+	NewOffsetTracker     = newOffsetTracker
+	WorkerIndex          = workerIndex
+	NextBatch            = nextBatch
 )
+
+// Add registers the message in offset tracker
+func (tracker *offsetTracker) Add(msg *broker.Message) {
+	tracker.add(msg)
+}
+
+// MarkDone marks the message as processed in offset tracker
+func (tracker *offsetTracker) MarkDone(
+	session sarama.ConsumerGroupSession, msg *broker.Message,
+) *broker.Message {
+	return tracker.markDone(session, msg)
+}
+
+// SetKafkaProducer sets producer used by the consumer to send messages to Kafka
+func (consumer *KafkaConsumer) SetKafkaProducer(kafkaProducer *producer.KafkaProducer) {
+	consumer.kafkaProducer = kafkaProducer
+}
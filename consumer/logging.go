@@ -17,33 +17,34 @@ package consumer
 import (
 	"time"
 
-	"github.com/Shopify/sarama"
 	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 )
 
-func logMessageInfo(consumer *KafkaConsumer, originalMessage *sarama.ConsumerMessage, parsedMessage incomingMessage, event string) {
+func logMessageInfo(consumer *KafkaConsumer, originalMessage *broker.Message, parsedMessage incomingMessage, event string) {
 	log.Info().
 		Int(offsetKey, int(originalMessage.Offset)).
 		Int(partitionKey, int(originalMessage.Partition)).
-		Str(topicKey, consumer.Configuration.Topic).
+		Str(topicKey, originalMessage.Topic).
 		Int(organizationKey, int(*parsedMessage.Organization)).
 		Str(clusterKey, string(*parsedMessage.ClusterName)).
 		Int(versionKey, int(parsedMessage.Version)).
 		Msg(event)
 }
 
-func logUnparsedMessageError(consumer *KafkaConsumer, originalMessage *sarama.ConsumerMessage, event string, err error) {
+func logUnparsedMessageError(consumer *KafkaConsumer, originalMessage *broker.Message, event string, err error) {
 	log.Error().
 		Int(offsetKey, int(originalMessage.Offset)).
-		Str(topicKey, consumer.Configuration.Topic).
+		Str(topicKey, originalMessage.Topic).
 		Err(err).
 		Msg(event)
 }
 
-func logMessageError(consumer *KafkaConsumer, originalMessage *sarama.ConsumerMessage, parsedMessage incomingMessage, event string, err error) {
+func logMessageError(consumer *KafkaConsumer, originalMessage *broker.Message, parsedMessage incomingMessage, event string, err error) {
 	log.Error().
 		Int(offsetKey, int(originalMessage.Offset)).
-		Str(topicKey, consumer.Configuration.Topic).
+		Str(topicKey, originalMessage.Topic).
 		Int(organizationKey, int(*parsedMessage.Organization)).
 		Str(clusterKey, string(*parsedMessage.ClusterName)).
 		Int(versionKey, int(parsedMessage.Version)).
@@ -51,11 +52,11 @@ func logMessageError(consumer *KafkaConsumer, originalMessage *sarama.ConsumerMe
 		Msg(event)
 }
 
-func logMessageWarning(consumer *KafkaConsumer, originalMessage *sarama.ConsumerMessage, parsedMessage incomingMessage, event string) {
+func logMessageWarning(consumer *KafkaConsumer, originalMessage *broker.Message, parsedMessage incomingMessage, event string) {
 	log.Warn().
 		Int(offsetKey, int(originalMessage.Offset)).
 		Int(partitionKey, int(originalMessage.Partition)).
-		Str(topicKey, consumer.Configuration.Topic).
+		Str(topicKey, originalMessage.Topic).
 		Int(organizationKey, int(*parsedMessage.Organization)).
 		Str(clusterKey, string(*parsedMessage.ClusterName)).
 		Int(versionKey, int(parsedMessage.Version)).
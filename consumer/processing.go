@@ -17,14 +17,17 @@ package consumer
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sync/atomic"
 	"time"
 
-	"github.com/Shopify/sarama"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -43,8 +46,108 @@ type incomingMessage struct {
 	ParsedHits  []types.ReportItem
 }
 
+// InvalidMessageError is returned by ProcessReport when the message is
+// malformed or its organization is not allowed, so processing of the message
+// can't succeed even if it's repeated
+type InvalidMessageError struct {
+	Err error
+}
+
+// Error returns error string
+func (err *InvalidMessageError) Error() string {
+	return err.Err.Error()
+}
+
+// preparedMessage is a consumed message that has been parsed and checked,
+// so its report is ready to be written into the storage
+type preparedMessage struct {
+	msg         *broker.Message
+	message     incomingMessage
+	report      types.ClusterReport
+	lastChecked time.Time
+}
+
 // HandleMessage handles the message and does all logging, metrics, etc
-func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
+func (consumer *KafkaConsumer) HandleMessage(msg *broker.Message) {
+	startTime := consumer.startHandlingMessage(msg)
+	requestID, err := consumer.ProcessMessage(msg)
+	consumer.finishHandlingMessage(msg, requestID, startTime, err)
+}
+
+// HandleMessages handles several messages at once and does all logging,
+// metrics, etc. Reports from all valid messages are written into the
+// storage in one batch. If the batch can't be written, the reports are
+// written one by one, so only the failing ones are reported as errors.
+func (consumer *KafkaConsumer) HandleMessages(msgs []*broker.Message) {
+	if len(msgs) == 1 {
+		consumer.HandleMessage(msgs[0])
+		return
+	}
+
+	var (
+		prepared   []preparedMessage
+		startTimes []time.Time
+		reports    []storage.ReportForCluster
+	)
+
+	for _, msg := range msgs {
+		startTime := consumer.startHandlingMessage(msg)
+
+		message, err := consumer.prepareMessage(msg)
+		if err != nil {
+			consumer.finishHandlingMessage(msg, message.message.RequestID, startTime, err)
+			continue
+		}
+
+		prepared = append(prepared, message)
+		startTimes = append(startTimes, startTime)
+		reports = append(reports, message.reportForCluster())
+	}
+
+	if len(reports) == 0 {
+		return
+	}
+
+	tStart := time.Now()
+	written, err := consumer.Storage.WriteReportsForClusters(reports)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Unable to write batch of %d reports, writing them one by one", len(reports))
+
+		for i, message := range prepared {
+			err := consumer.storeMessage(message)
+			if err == types.ErrOldReport {
+				err = nil
+			}
+			consumer.finishHandlingMessage(message.msg, message.message.RequestID, startTimes[i], err)
+		}
+		return
+	}
+	tStored := time.Now()
+
+	newRuleHits := make(map[int][]storage.RuleHitEvent, len(written))
+	for _, report := range written {
+		newRuleHits[report.Index] = report.NewRuleHits
+	}
+
+	for i, message := range prepared {
+		// reports older than the stored ones are skipped by the storage
+		if ruleHits, stored := newRuleHits[i]; stored {
+			logMessageInfo(consumer, message.msg, message.message, "Stored")
+			logDuration(tStart, tStored, message.msg.Offset, "db_store")
+
+			consumer.storeClusterMetadata(message.message, message.lastChecked)
+			consumer.notifyNewRuleHits(ruleHits, message.lastChecked)
+		} else {
+			logMessageInfo(consumer, message.msg, message.message, "Skipping because a more recent report already exists for this cluster")
+		}
+
+		consumer.finishHandlingMessage(message.msg, message.message.RequestID, startTimes[i], nil)
+	}
+}
+
+// startHandlingMessage logs that handling of the message has started and
+// returns the time when it happened
+func (consumer *KafkaConsumer) startHandlingMessage(msg *broker.Message) time.Time {
 	log.Info().
 		Int64(offsetKey, msg.Offset).
 		Int32(partitionKey, msg.Partition).
@@ -54,8 +157,14 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 
 	metrics.ConsumedMessages.Inc()
 
-	startTime := time.Now()
-	requestID, err := consumer.ProcessMessage(msg)
+	return time.Now()
+}
+
+// finishHandlingMessage does all logging, metrics, etc. once the message has
+// been processed, err is the result of processing
+func (consumer *KafkaConsumer) finishHandlingMessage(
+	msg *broker.Message, requestID types.RequestID, startTime time.Time, err error,
+) {
 	timeAfterProcessingMessage := time.Now()
 	messageProcessingDuration := timeAfterProcessingMessage.Sub(startTime).Seconds()
 
@@ -73,8 +182,8 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 		metrics.FailedMessagesProcessingTime.Observe(messageProcessingDuration)
 		metrics.ConsumingErrors.Inc()
 
-		log.Error().Err(err).Msg("Error processing message consumed from Kafka")
-		consumer.numberOfErrorsConsumingMessages++
+		log.Error().Err(err).Msg("Error processing consumed message")
+		atomic.AddUint64(&consumer.numberOfErrorsConsumingMessages, 1)
 
 		if err := consumer.Storage.WriteConsumerError(msg, err); err != nil {
 			log.Error().Err(err).Msg("Unable to write consumer error to storage")
@@ -84,7 +193,7 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 	} else {
 		// The message was processed successfully.
 		metrics.SuccessfulMessagesProcessingTime.Observe(messageProcessingDuration)
-		consumer.numberOfSuccessfullyConsumedMessages++
+		atomic.AddUint64(&consumer.numberOfSuccessfullyConsumedMessages, 1)
 
 		consumer.updatePayloadTracker(requestID, time.Now(), producer.StatusSuccess)
 	}
@@ -94,31 +203,39 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 }
 
 // updatePayloadTracker
-func (consumer KafkaConsumer) updatePayloadTracker(requestID types.RequestID, timestamp time.Time, status string) {
-	err := consumer.payloadTrackerProducer.TrackPayload(requestID, timestamp, status)
+func (consumer *KafkaConsumer) updatePayloadTracker(requestID types.RequestID, timestamp time.Time, status string) {
+	// messages that are not consumed from Kafka are not tracked
+	if consumer.kafkaProducer == nil {
+		return
+	}
+
+	err := consumer.kafkaProducer.TrackPayload(requestID, timestamp, status)
 	if err != nil {
 		log.Warn().Msgf(`Unable to send "%s" update to Payload Tracker service`, status)
 	}
 }
 
-// checkMessageVersion - verifies incoming data's version is the expected one
-func checkMessageVersion(consumer *KafkaConsumer, message *incomingMessage, msg *sarama.ConsumerMessage) {
-	if message.Version != CurrentSchemaVersion {
+// checkMessageVersion - verifies incoming data's version is one of the supported ones
+func checkMessageVersion(consumer *KafkaConsumer, message *incomingMessage, msg *broker.Message) {
+	if !isSupportedVersion(message.Version) {
 		const warning = "Received data with unexpected version."
 		logMessageWarning(consumer, msg, *message, warning)
 	}
 }
 
 // checkMessageOrgInAllowList - checks up incoming data's OrganizationID against allowed orgs list
-func checkMessageOrgInAllowList(consumer *KafkaConsumer, message *incomingMessage, msg *sarama.ConsumerMessage) (bool, string) {
+func checkMessageOrgInAllowList(consumer *KafkaConsumer, message *incomingMessage, msg *broker.Message) (bool, string) {
 	if consumer.Configuration.OrgAllowlistEnabled {
 		logMessageInfo(consumer, msg, *message, "Checking organization ID against allow list")
 
 		if ok := organizationAllowed(consumer, *message.Organization); !ok {
+			countAllowlistDecision(*message.Organization, allowlistDenied)
 			const cause = "organization ID is not in allow list"
 			return false, cause
 		}
 
+		countAllowlistDecision(*message.Organization, allowlistAllowed)
+
 		logMessageInfo(consumer, msg, *message, "Organization is in allow list")
 
 	} else {
@@ -128,14 +245,50 @@ func checkMessageOrgInAllowList(consumer *KafkaConsumer, message *incomingMessag
 }
 
 // ProcessMessage processes an incoming message
-func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (types.RequestID, error) {
+func (consumer *KafkaConsumer) ProcessMessage(msg *broker.Message) (types.RequestID, error) {
+	message, err := consumer.prepareMessage(msg)
+	if err != nil {
+		return message.message.RequestID, err
+	}
+
+	// message has been parsed and stored into storage, older reports are
+	// skipped without an error
+	err = consumer.storeMessage(message)
+	if err == types.ErrOldReport {
+		err = nil
+	}
+
+	return message.message.RequestID, err
+}
+
+// ProcessReport processes a message that has not been consumed from any
+// broker, for example a message received by the REST API. It's processed in
+// the same way as consumed messages, but errors found when the message is
+// checked are returned as InvalidMessageError and types.ErrOldReport is
+// returned when a more recent report is stored for the cluster already.
+func (consumer *KafkaConsumer) ProcessReport(msg *broker.Message) (types.RequestID, error) {
+	message, err := consumer.prepareMessage(msg)
+	if err != nil {
+		return message.message.RequestID, &InvalidMessageError{Err: err}
+	}
+
+	return message.message.RequestID, consumer.storeMessage(message)
+}
+
+// prepareMessage parses an incoming message and checks that its report can
+// be written into the storage
+func (consumer *KafkaConsumer) prepareMessage(msg *broker.Message) (preparedMessage, error) {
 	tStart := time.Now()
 
-	log.Info().Int(offsetKey, int(msg.Offset)).Str(topicKey, consumer.Configuration.Topic).Str(groupKey, consumer.Configuration.Group).Msg("Consumed")
+	prepared := preparedMessage{msg: msg}
+
+	log.Info().Int(offsetKey, int(msg.Offset)).Str(topicKey, msg.Topic).Str(groupKey, consumer.Configuration.Group).Msg("Consumed")
 	message, err := parseMessage(msg.Value)
+	prepared.message = message
 	if err != nil {
-		logUnparsedMessageError(consumer, msg, "Error parsing message from Kafka", err)
-		return message.RequestID, err
+		logUnparsedMessageError(consumer, msg, "Error parsing consumed message", err)
+		countRejectedMessage(rejectionReason(err))
+		return prepared, err
 	}
 
 	logMessageInfo(consumer, msg, message, "Read")
@@ -145,7 +298,8 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 
 	if ok, cause := checkMessageOrgInAllowList(consumer, &message, msg); !ok {
 		logMessageError(consumer, msg, message, cause, err)
-		return message.RequestID, errors.New(cause)
+		countRejectedMessage(rejectedOrganizationNotAllowed)
+		return prepared, errors.New(cause)
 	}
 
 	tAllowlisted := time.Now()
@@ -153,7 +307,7 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	reportAsBytes, err := json.Marshal(*message.Report)
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error marshalling report", err)
-		return message.RequestID, err
+		return prepared, err
 	}
 
 	logMessageInfo(consumer, msg, message, "Marshalled")
@@ -162,7 +316,8 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	lastCheckedTime, err := time.Parse(time.RFC3339Nano, message.LastChecked)
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error parsing date from message", err)
-		return message.RequestID, err
+		countRejectedMessage(rejectedInvalidLastChecked)
+		return prepared, err
 	}
 
 	lastCheckedTimestampLagMinutes := time.Now().Sub(lastCheckedTime).Minutes()
@@ -175,35 +330,78 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	logMessageInfo(consumer, msg, message, "Time ok")
 	tTimeCheck := time.Now()
 
-	err = consumer.Storage.WriteReportForCluster(
-		*message.Organization,
-		*message.ClusterName,
-		types.ClusterReport(reportAsBytes),
-		message.ParsedHits,
-		lastCheckedTime,
-		types.KafkaOffset(msg.Offset),
-	)
-	if err != nil {
-		if err == types.ErrOldReport {
-			logMessageInfo(consumer, msg, message, "Skipping because a more recent report already exists for this cluster")
-			return message.RequestID, nil
-		}
+	// log durations for every message preparation steps
+	logDuration(tStart, tRead, msg.Offset, "read")
+	logDuration(tRead, tAllowlisted, msg.Offset, "org_filtering")
+	logDuration(tAllowlisted, tMarshalled, msg.Offset, "marshalling")
+	logDuration(tMarshalled, tTimeCheck, msg.Offset, "time_check")
+
+	prepared.report = types.ClusterReport(reportAsBytes)
+	prepared.lastChecked = lastCheckedTime
+
+	return prepared, nil
+}
+
+// reportForCluster returns the report from the prepared message in the form
+// expected by the storage
+func (prepared preparedMessage) reportForCluster() storage.ReportForCluster {
+	return storage.ReportForCluster{
+		OrgID:           *prepared.message.Organization,
+		ClusterName:     *prepared.message.ClusterName,
+		Report:          prepared.report,
+		Rules:           prepared.message.ParsedHits,
+		LastCheckedTime: prepared.lastChecked,
+		KafkaOffset:     types.KafkaOffset(prepared.msg.Offset),
+	}
+}
+
+// storeMessage writes report from the prepared message into the storage,
+// types.ErrOldReport is returned when the report is older than the stored one
+func (consumer *KafkaConsumer) storeMessage(prepared preparedMessage) error {
+	tStart := time.Now()
 
+	msg, message := prepared.msg, prepared.message
+
+	// the report is written as a batch of one, so its new rule hits are returned
+	written, err := consumer.Storage.WriteReportsForClusters([]storage.ReportForCluster{prepared.reportForCluster()})
+	if err != nil {
 		logMessageError(consumer, msg, message, "Error writing report to database", err)
-		return message.RequestID, err
+		return err
+	}
+	if len(written) == 0 {
+		logMessageInfo(consumer, msg, message, "Skipping because a more recent report already exists for this cluster")
+		return types.ErrOldReport
 	}
 	logMessageInfo(consumer, msg, message, "Stored")
 	tStored := time.Now()
 
-	// log durations for every message consumption steps
-	logDuration(tStart, tRead, msg.Offset, "read")
-	logDuration(tRead, tAllowlisted, msg.Offset, "org_filtering")
-	logDuration(tAllowlisted, tMarshalled, msg.Offset, "marshalling")
-	logDuration(tMarshalled, tTimeCheck, msg.Offset, "time_check")
-	logDuration(tTimeCheck, tStored, msg.Offset, "db_store")
+	consumer.storeClusterMetadata(message, prepared.lastChecked)
+	consumer.notifyNewRuleHits(written[0].NewRuleHits, prepared.lastChecked)
+
+	logDuration(tStart, tStored, msg.Offset, "db_store")
+
+	return nil
+}
 
-	// message has been parsed and stored into storage
-	return message.RequestID, nil
+// notifyNewRuleHits publishes rule hits that were not present in the previous
+// report for the cluster, as returned by the storage, to the notification
+// topic. Errors are only logged, because the report has been stored already.
+func (consumer *KafkaConsumer) notifyNewRuleHits(newRuleHits []storage.RuleHitEvent, lastCheckedTime time.Time) {
+	if consumer.kafkaProducer == nil || len(consumer.Configuration.NotificationTopic) == 0 {
+		return
+	}
+
+	for _, ruleHit := range newRuleHits {
+		// error has been logged already
+		_ = consumer.kafkaProducer.NotifyNewRuleHit(producer.NotificationMessage{
+			OrgID:        ruleHit.OrgID,
+			ClusterName:  ruleHit.ClusterName,
+			RuleFQDN:     ruleHit.RuleFQDN,
+			ErrorKey:     ruleHit.ErrorKey,
+			TemplateData: ruleHit.TemplateData,
+			Timestamp:    lastCheckedTime.UTC().Format(time.RFC3339Nano),
+		})
+	}
 }
 
 // organizationAllowed checks whether the given organization is on allow list or not
@@ -213,11 +411,23 @@ func organizationAllowed(consumer *KafkaConsumer, orgID types.OrgID) bool {
 		return false
 	}
 
+	// the allowlist read from the DB is empty until it's loaded from storage
+	if allowList.Cardinality() == 0 {
+		log.Error().Msg("Organization allowlist is empty, all messages are rejected")
+		return false
+	}
+
 	orgAllowed := allowList.Contains(orgID)
 
 	return orgAllowed
 }
 
+// countAllowlistDecision updates the metric of messages checked against the
+// organization allow list
+func countAllowlistDecision(orgID types.OrgID, decision string) {
+	metrics.OrgAllowlistMessages.WithLabelValues(fmt.Sprint(orgID), decision).Inc()
+}
+
 // checkReportStructure tests if the report has correct structure
 func checkReportStructure(r Report) error {
 	// the structure is not well defined yet, so all we should do is to check if all keys are there
@@ -233,9 +443,19 @@ func checkReportStructure(r Report) error {
 
 // parseMessage tries to parse incoming message and read all required attributes from it
 func parseMessage(messageValue []byte) (incomingMessage, error) {
-	var deserialized incomingMessage
+	version := readMessageVersion(messageValue)
+	countMessageVersion(version)
+
+	// types of all values, including rule hits, are checked by JSON schema
+	// before the message is decoded, so values of unexpected types are
+	// reported as schema violations
+	err := validateMessageSchema(messageValue, version)
+	if err != nil {
+		return incomingMessage{}, err
+	}
 
-	err := json.Unmarshal(messageValue, &deserialized)
+	// messages of all versions are normalized into the same model
+	deserialized, err := decoderForVersion(version)(messageValue)
 	if err != nil {
 		return deserialized, err
 	}
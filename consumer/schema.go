@@ -0,0 +1,211 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// reportItemSchemaV1 is JSON schema of one rule hit in the report of schema
+// version 1
+const reportItemSchemaV1 = `{
+	"type": "object",
+	"required": ["component", "key", "details"],
+	"properties": {
+		"component": {"type": "string", "minLength": 1},
+		"key": {"type": "string", "minLength": 1},
+		"details": {"type": "object"}
+	}
+}`
+
+// messageSchemaV1 is JSON schema of the whole incoming message of schema
+// version 1
+const messageSchemaV1 = `{
+	"type": "object",
+	"required": ["OrgID", "ClusterName", "Report", "LastChecked"],
+	"properties": {
+		"OrgID": {"type": "integer", "minimum": 0},
+		"ClusterName": {"type": "string"},
+		"LastChecked": {"type": "string", "minLength": 1},
+		"Version": {"type": "integer", "minimum": 0},
+		"RequestId": {"type": "string", "nullable": true},
+		"Report": {
+			"type": "object",
+			"required": ["fingerprints", "info", "reports", "skips", "system"],
+			"properties": {
+				"fingerprints": {"type": "array"},
+				"info": {"type": "array"},
+				"reports": {"type": "array", "items": ` + reportItemSchemaV1 + `},
+				"skips": {"type": "array"},
+				"system": {"type": "object"}
+			}
+		}
+	}
+}`
+
+// reportItemSchemaV2 is JSON schema of one rule hit in the report of schema
+// version 2
+const reportItemSchemaV2 = `{
+	"type": "object",
+	"required": ["rule_fqdn", "error_key", "details"],
+	"properties": {
+		"rule_fqdn": {"type": "string", "minLength": 1},
+		"error_key": {"type": "string", "minLength": 1},
+		"details": {"type": "object"}
+	}
+}`
+
+// messageSchemaV2 is JSON schema of the whole incoming message of schema
+// version 2, which differs from version 1 by the layout of rule hits
+const messageSchemaV2 = `{
+	"type": "object",
+	"required": ["OrgID", "ClusterName", "Report", "LastChecked", "Version"],
+	"properties": {
+		"OrgID": {"type": "integer", "minimum": 0},
+		"ClusterName": {"type": "string"},
+		"LastChecked": {"type": "string", "minLength": 1},
+		"Version": {"type": "integer", "enum": [2]},
+		"RequestId": {"type": "string", "nullable": true},
+		"Report": {
+			"type": "object",
+			"required": ["fingerprints", "info", "reports", "skips", "system"],
+			"properties": {
+				"fingerprints": {"type": "array"},
+				"info": {"type": "array"},
+				"reports": {"type": "array", "items": ` + reportItemSchemaV2 + `},
+				"skips": {"type": "array"},
+				"system": {"type": "object"}
+			}
+		}
+	}
+}`
+
+// messageSchemas contains JSON schemas of incoming messages per schema
+// version, there must be a schema for each version in messageDecoders
+var messageSchemas = map[types.SchemaVersion]*openapi3.Schema{
+	1: mustLoadSchema(messageSchemaV1),
+	2: mustLoadSchema(messageSchemaV2),
+}
+
+// reasons of rejection of messages used as label of the RejectedMessages
+// metric, schema violations are labeled by the violated schema keyword
+const (
+	rejectedInvalidJSON            = "invalid_json"
+	rejectedInvalidMessage         = "invalid_message"
+	rejectedOrganizationNotAllowed = "organization_not_allowed"
+	rejectedInvalidLastChecked     = "invalid_last_checked"
+	rejectedSchemaPrefix           = "schema_"
+)
+
+// SchemaViolationError is returned when the incoming message doesn't conform
+// to the JSON schema of its version
+type SchemaViolationError struct {
+	Version types.SchemaVersion
+	// Path is JSON pointer to the value violating the schema
+	Path string
+	// Keyword is the violated schema keyword, like "required" or "type"
+	Keyword string
+	Reason  string
+}
+
+// Error returns error string
+func (err *SchemaViolationError) Error() string {
+	return fmt.Sprintf(
+		"message violates schema version %d at '%s': %s", err.Version, err.Path, err.Reason,
+	)
+}
+
+// mustLoadSchema parses JSON schema, it panics when the schema is invalid
+func mustLoadSchema(schemaJSON string) *openapi3.Schema {
+	schema := openapi3.NewSchema()
+
+	err := json.Unmarshal([]byte(schemaJSON), schema)
+	if err != nil {
+		panic(err)
+	}
+
+	return schema
+}
+
+// schemaForVersion returns JSON schema of messages of the given version.
+// Schema of the current version is used for messages with unknown version,
+// which are only warned about (see checkMessageVersion).
+func schemaForVersion(version types.SchemaVersion) (*openapi3.Schema, types.SchemaVersion) {
+	if schema, found := messageSchemas[version]; found {
+		return schema, version
+	}
+
+	return messageSchemas[CurrentSchemaVersion], CurrentSchemaVersion
+}
+
+// validateMessageSchema checks the whole incoming message, including all rule
+// hits in its report, against JSON schema of the message version
+func validateMessageSchema(messageValue []byte, version types.SchemaVersion) error {
+	var document interface{}
+
+	err := json.Unmarshal(messageValue, &document)
+	if err != nil {
+		return err
+	}
+
+	schema, schemaVersion := schemaForVersion(version)
+
+	err = schema.VisitJSON(document)
+	if err == nil {
+		return nil
+	}
+
+	schemaErr, ok := err.(*openapi3.SchemaError)
+	if !ok {
+		return err
+	}
+
+	violation := &SchemaViolationError{
+		Version: schemaVersion,
+		Path:    "/" + strings.Join(schemaErr.JSONPointer(), "/"),
+		Keyword: schemaErr.SchemaField,
+		Reason:  schemaErr.Reason,
+	}
+	if violation.Reason == "" {
+		violation.Reason = fmt.Sprintf("value doesn't match schema keyword '%s'", violation.Keyword)
+	}
+
+	return violation
+}
+
+// rejectionReason returns reason of rejection of the message that can't be
+// parsed, it's used as label of the RejectedMessages metric
+func rejectionReason(err error) string {
+	switch err := err.(type) {
+	case *SchemaViolationError:
+		return rejectedSchemaPrefix + err.Keyword
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return rejectedInvalidJSON
+	default:
+		return rejectedInvalidMessage
+	}
+}
+
+// countRejectedMessage updates the metric of rejected messages
+func countRejectedMessage(reason string) {
+	metrics.RejectedMessages.WithLabelValues(reason).Inc()
+}
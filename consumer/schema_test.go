@@ -0,0 +1,156 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// messageWithReportParts returns message with the given rule hits and info
+// parts of the report
+func messageWithReportParts(reports, info string) string {
+	return `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+		"Version": 1,
+		"Report": {
+			"fingerprints": [],
+			"info": ` + info + `,
+			"reports": ` + reports + `,
+			"skips": [],
+			"system": {"metadata": {}, "hostname": null}
+		}
+	}`
+}
+
+func TestParseMessageSchemaViolations(t *testing.T) {
+	type testCase struct {
+		name    string
+		message string
+		path    string
+		keyword string
+	}
+
+	testCases := []testCase{
+		{
+			name:    "missing error key",
+			message: messageWithReportParts(`[{"component": "rule.module", "details": {}}]`, `[]`),
+			path:    "/Report/reports/0/key",
+			keyword: "required",
+		},
+		{
+			name:    "empty component",
+			message: messageWithReportParts(`[{"component": "", "key": "KEY", "details": {}}]`, `[]`),
+			path:    "/Report/reports/0/component",
+			keyword: "minLength",
+		},
+		{
+			name:    "component is not a string",
+			message: messageWithReportParts(`[{"component": 5, "key": "KEY", "details": {}}]`, `[]`),
+			path:    "/Report/reports/0/component",
+			keyword: "type",
+		},
+		{
+			name: "organization ID is not a number",
+			message: strings.Replace(
+				messageWithReportParts(`[]`, `[]`), `"OrgID": `+fmt.Sprint(testdata.OrgID), `"OrgID": "x"`, 1,
+			),
+			path:    "/OrgID",
+			keyword: "type",
+		},
+		{
+			name:    "details are not an object",
+			message: messageWithReportParts(`[{"component": "rule.module", "key": "KEY", "details": "text"}]`, `[]`),
+			path:    "/Report/reports/0/details",
+			keyword: "type",
+		},
+		{
+			name:    "info is not an array",
+			message: messageWithReportParts(`[]`, `{}`),
+			path:    "/Report/info",
+			keyword: "type",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := consumer.ParseMessage([]byte(tc.message))
+			assert.Error(t, err)
+
+			violation, ok := err.(*consumer.SchemaViolationError)
+			if !assert.True(t, ok, "schema violation is expected, got %v", err) {
+				return
+			}
+
+			assert.Equal(t, consumer.CurrentSchemaVersion, violation.Version)
+			assert.Equal(t, tc.path, violation.Path)
+			assert.Equal(t, tc.keyword, violation.Keyword)
+		})
+	}
+}
+
+func TestParseMessageSchemaValid(t *testing.T) {
+	message := messageWithReportParts(
+		`[{"component": "rule.module", "key": "KEY", "details": {"nodes": []}, "type": "rule"}]`, `[{"details": {}}]`,
+	)
+
+	parsed, err := consumer.ParseMessage([]byte(message))
+	helpers.FailOnError(t, err)
+	assert.Len(t, parsed.ParsedHits, 1)
+}
+
+// TestKafkaConsumer_HandleMessage_SchemaViolation checks that the schema
+// violation is recorded as the consumer error
+func TestKafkaConsumer_HandleMessage_SchemaViolation(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	message := messageWithReportParts(`[{"component": "", "key": "KEY", "details": {}}]`, `[]`)
+	kafkaConsumer.HandleMessage(&broker.Message{
+		Topic: testTopicName,
+		Value: []byte(message),
+	})
+
+	assert.Equal(t, uint64(1), kafkaConsumer.GetNumberOfErrorsConsumingMessages())
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+	assert.True(t, strings.HasPrefix(
+		consumerErrors[0].Error, "message violates schema version 1 at '/Report/reports/0/component'",
+	), consumerErrors[0].Error)
+
+	// nothing has been stored
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+}
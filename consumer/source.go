@@ -0,0 +1,112 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"context"
+	"io"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// SourceConsumer is an implementation of Consumer interface that consumes
+// messages from other sources than Kafka (see broker.Source). Messages are
+// processed one by one in the same way as messages consumed from Kafka, but
+// payload tracker is not updated.
+type SourceConsumer struct {
+	*KafkaConsumer
+	Source broker.Source
+	ctx    context.Context
+}
+
+// NewSourceConsumer constructs new consumer of messages from the given source
+func NewSourceConsumer(
+	brokerCfg broker.Configuration, storage storage.Storage, source broker.Source,
+) *SourceConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &SourceConsumer{
+		KafkaConsumer: &KafkaConsumer{
+			Configuration: brokerCfg,
+			Storage:       storage,
+			cancel:        cancel,
+		},
+		Source: source,
+		ctx:    ctx,
+	}
+}
+
+// NewFromConfiguration constructs new implementation of Consumer interface
+// for the broker of the type selected by the configuration
+func NewFromConfiguration(brokerCfg broker.Configuration, storage storage.Storage) (Consumer, error) {
+	if brokerCfg.Type == "" || brokerCfg.Type == broker.TypeKafka {
+		kafkaConsumer, err := New(brokerCfg, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		return kafkaConsumer, nil
+	}
+
+	source, err := broker.NewSource(brokerCfg)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to construct source of messages")
+		return nil, err
+	}
+
+	return NewSourceConsumer(brokerCfg, storage, source), nil
+}
+
+// Serve starts reading messages from the source and processing them. It
+// blocks current thread until all messages have been consumed or until the
+// consumer is closed.
+func (consumer *SourceConsumer) Serve() {
+	log.Info().Str("type", consumer.Configuration.Type).Msg("started serving consumer")
+
+	for consumer.ctx.Err() == nil {
+		message, err := consumer.Source.Next()
+		if err == io.EOF {
+			log.Info().Msg("all messages have been consumed from the source")
+			return
+		}
+		if err == broker.ErrSourceClosed {
+			// the consumer has been closed while waiting for the message
+			break
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("unable to read message from the source")
+			return
+		}
+
+		consumer.HandleMessage(message)
+	}
+
+	log.Info().Msg("context cancelled, exiting")
+}
+
+// Close method closes the source of messages
+func (consumer *SourceConsumer) Close() error {
+	consumer.cancel()
+
+	if err := consumer.Source.Close(); err != nil {
+		log.Error().Err(err).Msg("unable to close source of messages")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// mustCompactMessage returns the message as one line of JSON
+func mustCompactMessage(t *testing.T, message string) string {
+	var buf bytes.Buffer
+	helpers.FailOnError(t, json.Compact(&buf, []byte(message)))
+	return buf.String()
+}
+
+func TestSourceConsumer_Serve(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	input := mustCompactMessage(t, testdata.ConsumerMessage) + "\n\nnot a JSON\n"
+	sourceConsumer := consumer.NewSourceConsumer(
+		broker.Configuration{Type: broker.TypeStdin},
+		mockStorage,
+		broker.NewReaderSource(broker.StdinTopic, strings.NewReader(input)),
+	)
+
+	// returns once all messages have been consumed
+	sourceConsumer.Serve()
+
+	assert.Equal(t, uint64(1), sourceConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	assert.Equal(t, uint64(1), sourceConsumer.GetNumberOfErrorsConsumingMessages())
+
+	_, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	// line number is used as offset of the failing message
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, broker.StdinTopic, consumerErrors[0].Topic)
+	assert.Equal(t, int64(3), consumerErrors[0].Offset)
+
+	helpers.FailOnError(t, sourceConsumer.Close())
+}
+
+func TestSourceConsumer_Close(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	input := mustCompactMessage(t, testdata.ConsumerMessage) + "\n"
+	sourceConsumer := consumer.NewSourceConsumer(
+		broker.Configuration{Type: broker.TypeStdin},
+		mockStorage,
+		broker.NewReaderSource(broker.StdinTopic, strings.NewReader(input)),
+	)
+
+	helpers.FailOnError(t, sourceConsumer.Close())
+
+	// nothing is consumed by closed consumer
+	sourceConsumer.Serve()
+	assert.Equal(t, uint64(0), sourceConsumer.GetNumberOfSuccessfullyConsumedMessages())
+}
+
+// TestSourceConsumer_CloseBlocked checks that Close stops the consumer that
+// is waiting for the next message on the input
+func TestSourceConsumer_CloseBlocked(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	reader, writer := io.Pipe()
+	defer func() {
+		helpers.FailOnError(t, writer.Close())
+	}()
+
+	sourceConsumer := consumer.NewSourceConsumer(
+		broker.Configuration{Type: broker.TypeStdin},
+		mockStorage,
+		broker.NewReaderSource(broker.StdinTopic, reader),
+	)
+
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			helpers.FailOnError(t, sourceConsumer.Close())
+		}()
+
+		// returns once the consumer is closed
+		sourceConsumer.Serve()
+	}, testCaseTimeLimit)
+}
+
+func TestNewFromConfiguration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "messages")
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, os.RemoveAll(dir))
+	}()
+
+	path := filepath.Join(dir, "messages.jsonl")
+	err = ioutil.WriteFile(path, []byte(mustCompactMessage(t, testdata.ConsumerMessage)+"\n"), 0600)
+	helpers.FailOnError(t, err)
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	fileConsumer, err := consumer.NewFromConfiguration(
+		broker.Configuration{Type: broker.TypeFile, Path: path}, mockStorage,
+	)
+	helpers.FailOnError(t, err)
+	assert.IsType(t, &consumer.SourceConsumer{}, fileConsumer)
+
+	fileConsumer.Serve()
+	helpers.FailOnError(t, fileConsumer.Close())
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	// file has to exist
+	_, err = consumer.NewFromConfiguration(
+		broker.Configuration{Type: broker.TypeFile, Path: filepath.Join(dir, "missing")}, mockStorage,
+	)
+	assert.Error(t, err)
+
+	_, err = consumer.NewFromConfiguration(broker.Configuration{Type: "unknown"}, mockStorage)
+	assert.EqualError(t, err, "broker of type 'unknown' is not a source of messages")
+}
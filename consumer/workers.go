@@ -0,0 +1,179 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+)
+
+// workerQueueSize is the number of messages that can wait for one worker
+const workerQueueSize = 16
+
+// offsetTracker keeps track of messages consumed from one partition that are
+// being processed concurrently. Offset of a message can be committed only
+// when the message and all earlier messages from the partition are processed.
+type offsetTracker struct {
+	mutex sync.Mutex
+	// pending contains messages in the order they were consumed
+	pending []*broker.Message
+	// done contains offsets of processed messages that are still pending,
+	// because some earlier message is being processed
+	done map[int64]bool
+}
+
+// newOffsetTracker constructs new offsetTracker instance
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		done: make(map[int64]bool),
+	}
+}
+
+// add registers the message before it is dispatched to a worker
+func (tracker *offsetTracker) add(msg *broker.Message) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.pending = append(tracker.pending, msg)
+}
+
+// markDone marks the message as processed and marks the latest message whose
+// offset can be committed in the session. The latest message that has been
+// marked in the session is returned, nil means that nothing has been marked.
+func (tracker *offsetTracker) markDone(
+	session sarama.ConsumerGroupSession, msg *broker.Message,
+) *broker.Message {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.done[msg.Offset] = true
+
+	var committable *broker.Message
+	for len(tracker.pending) > 0 && tracker.done[tracker.pending[0].Offset] {
+		committable = tracker.pending[0]
+		delete(tracker.done, committable.Offset)
+		tracker.pending = tracker.pending[1:]
+	}
+
+	// session is updated while the lock is held, so offsets are marked in
+	// order, marked offset is the offset of the next message to be consumed
+	if committable != nil {
+		session.MarkOffset(committable.Topic, committable.Partition, committable.Offset+1, "")
+	}
+
+	return committable
+}
+
+// numberOfWorkers returns the number of workers processing messages consumed
+// from one partition
+func (consumer *KafkaConsumer) numberOfWorkers() int {
+	if consumer.Configuration.Workers < 1 {
+		return 1
+	}
+
+	return consumer.Configuration.Workers
+}
+
+// batchSize returns the maximum number of queued messages that are handled
+// by a worker at once
+func (consumer *KafkaConsumer) batchSize() int {
+	if consumer.Configuration.BatchSize < 1 {
+		return 1
+	}
+
+	return consumer.Configuration.BatchSize
+}
+
+// nextBatch returns the message together with messages that are already
+// waiting in the queue, up to the given batch size. It never blocks.
+func nextBatch(
+	message *broker.Message, queue <-chan *broker.Message, batchSize int,
+) []*broker.Message {
+	batch := []*broker.Message{message}
+
+	for len(batch) < batchSize {
+		select {
+		case queued, ok := <-queue:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, queued)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+// workerIndex selects the worker for the message, so all messages for the
+// same cluster are processed by the same worker in the order they were consumed
+func workerIndex(msg *broker.Message, numberOfWorkers int) int {
+	if numberOfWorkers <= 1 {
+		return 0
+	}
+
+	var header struct {
+		ClusterName string `json:"ClusterName"`
+	}
+
+	// messages that can't be parsed are dispatched by empty cluster name,
+	// they will be rejected by the worker anyway
+	_ = json.Unmarshal(msg.Value, &header)
+
+	hash := fnv.New32a()
+	// writing to hash never returns an error
+	_, _ = hash.Write([]byte(header.ClusterName))
+
+	return int(hash.Sum32() % uint32(numberOfWorkers))
+}
+
+// startWorkers starts workers that handle messages consumed from one
+// partition and mark them in the session once they can be committed. Messages
+// that are queued for a worker are handled in batches (see HandleMessages).
+// Messages are dispatched to the workers via returned queues, workers stop
+// when all queues are closed and the returned wait group is done after that.
+func (consumer *KafkaConsumer) startWorkers(
+	session sarama.ConsumerGroupSession, tracker *offsetTracker,
+) ([]chan *broker.Message, *sync.WaitGroup) {
+	queues := make([]chan *broker.Message, consumer.numberOfWorkers())
+	batchSize := consumer.batchSize()
+	waitGroup := &sync.WaitGroup{}
+
+	for i := range queues {
+		queues[i] = make(chan *broker.Message, workerQueueSize)
+
+		waitGroup.Add(1)
+		go func(queue <-chan *broker.Message) {
+			defer waitGroup.Done()
+
+			for message := range queue {
+				batch := nextBatch(message, queue, batchSize)
+				consumer.HandleMessages(batch)
+
+				for _, handled := range batch {
+					tracker.markDone(session, handled)
+				}
+			}
+		}(queues[i])
+	}
+
+	return queues, waitGroup
+}
@@ -0,0 +1,265 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-operator-utils/tests/saramahelpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// recordingConsumerGroupSession remembers offsets of all marked messages
+type recordingConsumerGroupSession struct {
+	saramahelpers.MockConsumerGroupSession
+	mutex   sync.Mutex
+	offsets []int64
+}
+
+func (session *recordingConsumerGroupSession) MarkOffset(_ string, _ int32, offset int64, _ string) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	// marked offset is the offset of the next message to be consumed
+	session.offsets = append(session.offsets, offset-1)
+}
+
+func messageForCluster(clusterName types.ClusterName, offset int64) *broker.Message {
+	return &broker.Message{
+		Topic:  testTopicName,
+		Offset: offset,
+		Value: []byte(`{
+			"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+			"ClusterName": "` + string(clusterName) + `",
+			"Report":` + testdata.ConsumerReport + `,
+			"LastChecked": "` + testdata.LastCheckedAt.Add(time.Duration(offset)*time.Second).Format(time.RFC3339) + `"
+		}`),
+	}
+}
+
+// saramaMessages converts the messages into messages consumed from Kafka
+func saramaMessages(messages []*broker.Message) []*sarama.ConsumerMessage {
+	var consumed []*sarama.ConsumerMessage
+	for _, message := range messages {
+		consumed = append(consumed, &sarama.ConsumerMessage{
+			Topic:  message.Topic,
+			Offset: message.Offset,
+			Value:  message.Value,
+		})
+	}
+
+	return consumed
+}
+
+func TestOffsetTracker(t *testing.T) {
+	session := &recordingConsumerGroupSession{}
+	tracker := consumer.NewOffsetTracker()
+
+	messages := []*broker.Message{{Offset: 10}, {Offset: 11}, {Offset: 12}}
+	for _, message := range messages {
+		tracker.Add(message)
+	}
+
+	// earlier message is still being processed
+	assert.Nil(t, tracker.MarkDone(session, messages[1]))
+	assert.Empty(t, session.offsets)
+
+	// both first messages are done now
+	assert.Equal(t, messages[1], tracker.MarkDone(session, messages[0]))
+	assert.Equal(t, messages[2], tracker.MarkDone(session, messages[2]))
+	assert.Equal(t, []int64{11, 12}, session.offsets)
+}
+
+func TestWorkerIndex(t *testing.T) {
+	message := messageForCluster(testdata.ClusterName, 0)
+
+	assert.Equal(t, 0, consumer.WorkerIndex(message, 1))
+
+	index := consumer.WorkerIndex(message, 8)
+	assert.True(t, index >= 0 && index < 8)
+	// the same cluster is always handled by the same worker
+	assert.Equal(t, index, consumer.WorkerIndex(messageForCluster(testdata.ClusterName, 1), 8))
+
+	// message that can't be parsed is dispatched too
+	index = consumer.WorkerIndex(&broker.Message{Value: []byte("not a JSON")}, 8)
+	assert.True(t, index >= 0 && index < 8)
+}
+
+func TestKafkaConsumer_ConsumeClaim_Workers(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{Workers: 4},
+		Storage:       mockStorage,
+	}
+
+	clusterNames := []types.ClusterName{
+		testdata.GetRandomClusterID(),
+		testdata.GetRandomClusterID(),
+		testdata.GetRandomClusterID(),
+	}
+
+	var messages []*broker.Message
+	for offset := int64(0); offset < 30; offset++ {
+		messages = append(messages, messageForCluster(clusterNames[offset%3], offset))
+	}
+
+	session := &recordingConsumerGroupSession{}
+	err := kafkaConsumer.ConsumeClaim(session, saramahelpers.NewMockConsumerGroupClaim(saramaMessages(messages)))
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, uint64(30), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	assert.Equal(t, uint64(0), kafkaConsumer.GetNumberOfErrorsConsumingMessages())
+
+	// offsets are committed in order and the last one is committed at the end
+	for i := 1; i < len(session.offsets); i++ {
+		assert.Greater(t, session.offsets[i], session.offsets[i-1])
+	}
+	assert.Equal(t, int64(29), session.offsets[len(session.offsets)-1])
+
+	// the latest report is stored for each cluster
+	for i, clusterName := range clusterNames {
+		_, lastChecked, err := mockStorage.ReadReportForCluster(testdata.OrgID, clusterName)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.Add(time.Duration(27+i)*time.Second).Format(time.RFC3339)), lastChecked)
+	}
+}
+
+func TestNextBatch(t *testing.T) {
+	queue := make(chan *broker.Message, 3)
+	first := &broker.Message{Offset: 0}
+
+	// nothing else is waiting
+	assert.Equal(t, []*broker.Message{first}, consumer.NextBatch(first, queue, 10))
+
+	queue <- &broker.Message{Offset: 1}
+	queue <- &broker.Message{Offset: 2}
+	queue <- &broker.Message{Offset: 3}
+
+	// batch size is respected
+	batch := consumer.NextBatch(first, queue, 3)
+	assert.Len(t, batch, 3)
+	assert.Equal(t, int64(2), batch[2].Offset)
+
+	// closed queue ends the batch
+	close(queue)
+	assert.Len(t, consumer.NextBatch(first, queue, 10), 2)
+}
+
+// failingBatchStorage is unable to write reports in batches of more than one
+// report
+type failingBatchStorage struct {
+	storage.Storage
+}
+
+func (s failingBatchStorage) WriteReportsForClusters(reports []storage.ReportForCluster) ([]storage.WrittenReport, error) {
+	if len(reports) > 1 {
+		return nil, errors.New("batch write failed")
+	}
+
+	return s.Storage.WriteReportsForClusters(reports)
+}
+
+func TestKafkaConsumer_HandleMessages(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	clusterName := testdata.GetRandomClusterID()
+
+	for _, testStorage := range []storage.Storage{mockStorage, failingBatchStorage{mockStorage}} {
+		kafkaConsumer := consumer.KafkaConsumer{
+			Storage: testStorage,
+		}
+
+		kafkaConsumer.HandleMessages([]*broker.Message{
+			messageForCluster(testdata.ClusterName, 0),
+			{Topic: testTopicName, Offset: 1, Value: []byte("not a JSON")},
+			messageForCluster(clusterName, 2),
+		})
+
+		assert.Equal(t, uint64(2), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+		assert.Equal(t, uint64(1), kafkaConsumer.GetNumberOfErrorsConsumingMessages())
+	}
+
+	for _, clusterName := range []types.ClusterName{testdata.ClusterName, clusterName} {
+		_, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, clusterName)
+		helpers.FailOnError(t, err)
+	}
+}
+
+func TestKafkaConsumer_HandleMessagesOldReport(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	buf, restoreLogger := captureLogs()
+	defer restoreLogger()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	// the second report is older than the first one, so it is skipped
+	kafkaConsumer.HandleMessages([]*broker.Message{
+		messageForCluster(testdata.ClusterName, 5),
+		messageForCluster(testdata.ClusterName, 2),
+	})
+
+	assert.Equal(t, uint64(2), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	assert.Equal(t, 1, strings.Count(buf.String(), `"message":"Stored"`))
+	assert.Contains(t, buf.String(), "Skipping because a more recent report already exists for this cluster")
+}
+
+func TestKafkaConsumer_ConsumeClaim_Batches(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{Workers: 2, BatchSize: 5},
+		Storage:       mockStorage,
+	}
+
+	var messages []*broker.Message
+	for offset := int64(0); offset < 20; offset++ {
+		messages = append(messages, messageForCluster(testdata.GetRandomClusterID(), offset))
+	}
+
+	session := &recordingConsumerGroupSession{}
+	err := kafkaConsumer.ConsumeClaim(session, saramahelpers.NewMockConsumerGroupClaim(saramaMessages(messages)))
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, uint64(20), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	assert.Equal(t, int64(19), session.offsets[len(session.offsets)-1])
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 20, count)
+}
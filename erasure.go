@@ -0,0 +1,116 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// parseEraseArgs parses arguments of erase subcommand, exactly one of
+// organization ID and cluster name is returned
+func parseEraseArgs(args []string) (orgID types.OrgID, clusterName types.ClusterName, err error) {
+	var (
+		org     uint64
+		cluster string
+	)
+
+	flags := flag.NewFlagSet("erase", flag.ContinueOnError)
+	flags.Uint64Var(&org, "org", 0, "erase all data of this organization and its clusters")
+	flags.StringVar(&cluster, "cluster", "", "erase all data of this cluster")
+
+	err = flags.Parse(args)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if (org == 0) == (cluster == "") {
+		return 0, "", errors.New("exactly one of -org and -cluster flags is expected")
+	}
+
+	if cluster != "" {
+		if _, err := uuid.Parse(cluster); err != nil {
+			return 0, "", fmt.Errorf("invalid cluster name '%v': %v", cluster, err)
+		}
+	}
+
+	return types.OrgID(org), types.ClusterName(cluster), nil
+}
+
+// eraseData removes all data of the organization or the cluster, only one of
+// them is expected to be set
+func eraseData(
+	dbStorage storage.Storage, orgID types.OrgID, clusterName types.ClusterName,
+) (storage.ErasureResult, error) {
+	if clusterName != "" {
+		return dbStorage.EraseClusterData(clusterName)
+	}
+
+	return dbStorage.EraseOrgData(orgID)
+}
+
+// printErasureResult prints numbers of removed rows per table
+func printErasureResult(result storage.ErasureResult) {
+	tables := make([]string, 0, len(result))
+	for table := range result {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		fmt.Printf("%v\t%d\n", table, result[table])
+	}
+}
+
+// performErasure handles erase subcommand. It removes all data of the
+// organization or the cluster from all tables and prints numbers of removed
+// rows.
+func performErasure() int {
+	orgID, clusterName, err := parseEraseArgs(os.Args[2:])
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to parse arguments of erase command")
+		return ExitStatusEraseError
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
+	// Ensure that the DB is at the latest migration version.
+	if exitCode := prepareDBMigrations(dbStorage); exitCode != ExitStatusOK {
+		return exitCode
+	}
+
+	result, err := eraseData(dbStorage, orgID, clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to erase data")
+		return ExitStatusEraseError
+	}
+
+	printErasureResult(result)
+
+	return ExitStatusOK
+}
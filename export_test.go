@@ -43,4 +43,23 @@ var (
 	PerformMigrations   = performMigrations
 	AutoMigratePtr      = &autoMigrate
 	Main                = main
+
+	ParseReplayFilter           = parseReplayFilter
+	ReplayConsumerErrors        = replayConsumerErrors
+	PerformConsumerErrorsReplay = performConsumerErrorsReplay
+
+	RetentionCutoffs     = retentionCutoffs
+	ApplyRetentionPolicy = applyRetentionPolicy
+	RunRetentionWorker   = runRetentionWorker
+	ParseCleanupArgs     = parseCleanupArgs
+	PerformCleanup       = performCleanup
+
+	ParseEraseArgs = parseEraseArgs
+	EraseData      = eraseData
+	PerformErasure = performErasure
+
+	NewOrgAllowlistReloader     = newOrgAllowlistReloader
+	ReadOrgAllowlist            = readOrgAllowlist
+	ReplaceOrgAllowlist         = replaceOrgAllowlist
+	RunOrgAllowlistReloadWorker = runOrgAllowlistReloadWorker
 )
@@ -36,6 +36,16 @@ limitations under the License.
 // sql_queries_counter - total number of SQL queries
 //
 // sql_queries_durations - SQL queries durations
+//
+// clusters_last_checked_cache_hits - total number of cluster timestamps found in the cache
+//
+// clusters_last_checked_cache_misses - total number of cluster timestamps not found in the cache
+//
+// rejected_messages - total number of messages rejected by the consumer, labeled by reason of rejection
+//
+// consumed_messages_by_version - total number of consumed messages, labeled by their schema version
+//
+// org_allowlist_messages - total number of messages checked against organization allowlist, labeled by organization and decision
 package metrics
 
 import (
@@ -105,6 +115,42 @@ var SQLQueriesDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Help: "SQL queries durations",
 }, []string{"query"})
 
+// ClustersLastCheckedCacheHits shows how many times the timestamp of the
+// latest report for a cluster was found in the cache
+var ClustersLastCheckedCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "clusters_last_checked_cache_hits",
+	Help: "The total number of cluster timestamps found in the cache",
+})
+
+// ClustersLastCheckedCacheMisses shows how many times the timestamp of the
+// latest report for a cluster was not found in the cache
+var ClustersLastCheckedCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "clusters_last_checked_cache_misses",
+	Help: "The total number of cluster timestamps not found in the cache",
+})
+
+// RejectedMessages shows number of messages that were rejected because they
+// are malformed or not allowed, labeled by reason of the rejection
+var RejectedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rejected_messages",
+	Help: "The total number of messages rejected by the consumer",
+}, []string{"reason"})
+
+// ConsumedMessagesByVersion shows number of consumed messages labeled by
+// their schema version
+var ConsumedMessagesByVersion = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "consumed_messages_by_version",
+	Help: "The total number of consumed messages by their schema version",
+}, []string{"version"})
+
+// OrgAllowlistMessages shows number of messages checked against the
+// organization allowlist, labeled by organization and by the decision
+// (allowed or denied)
+var OrgAllowlistMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "org_allowlist_messages",
+	Help: "The total number of messages checked against the organization allowlist",
+}, []string{"organization", "decision"})
+
 // AddMetricsWithNamespace register the desired metrics using a given namespace
 func AddMetricsWithNamespace(namespace string) {
 	metrics.AddAPIMetricsWithNamespace(namespace)
@@ -119,6 +165,11 @@ func AddMetricsWithNamespace(namespace string) {
 	prometheus.Unregister(FeedbackOnRules)
 	prometheus.Unregister(SQLQueriesCounter)
 	prometheus.Unregister(SQLQueriesDurations)
+	prometheus.Unregister(ClustersLastCheckedCacheHits)
+	prometheus.Unregister(ClustersLastCheckedCacheMisses)
+	prometheus.Unregister(RejectedMessages)
+	prometheus.Unregister(ConsumedMessagesByVersion)
+	prometheus.Unregister(OrgAllowlistMessages)
 
 	ConsumedMessages = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: namespace,
@@ -170,4 +221,29 @@ func AddMetricsWithNamespace(namespace string) {
 		Name:      "sql_queries_durations",
 		Help:      "SQL queries durations",
 	}, []string{"query"})
+	ClustersLastCheckedCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clusters_last_checked_cache_hits",
+		Help:      "The total number of cluster timestamps found in the cache",
+	})
+	ClustersLastCheckedCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clusters_last_checked_cache_misses",
+		Help:      "The total number of cluster timestamps not found in the cache",
+	})
+	RejectedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rejected_messages",
+		Help:      "The total number of messages rejected by the consumer",
+	}, []string{"reason"})
+	ConsumedMessagesByVersion = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consumed_messages_by_version",
+		Help:      "The total number of consumed messages by their schema version",
+	}, []string{"version"})
+	OrgAllowlistMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "org_allowlist_messages",
+		Help:      "The total number of messages checked against the organization allowlist",
+	}, []string{"organization", "decision"})
 }
@@ -29,6 +29,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
@@ -145,3 +146,111 @@ func TestWrittenReportsMetric(t *testing.T) {
 // TODO: write tests for sql queries metrics
 // - SQLQueriesCounter
 // - SQLQueriesDurations
+
+// TestRejectedMessagesMetric tests that rejected messages are counted by the
+// reason of their rejection
+func TestRejectedMessagesMetric(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	invalidJSONLabels := map[string]string{"reason": "invalid_json"}
+	schemaLabels := map[string]string{"reason": "schema_required"}
+
+	// other tests may run at the same process
+	initInvalidJSON := getCounterVecValue(metrics.RejectedMessages, invalidJSONLabels)
+	initSchema := getCounterVecValue(metrics.RejectedMessages, schemaLabels)
+
+	kafkaConsumer.HandleMessage(&broker.Message{
+		Topic: testTopicName,
+		Value: []byte("this is not a JSON"),
+	})
+	kafkaConsumer.HandleMessage(&broker.Message{
+		Topic: testTopicName,
+		Value: []byte(`{
+			"OrgID": 1,
+			"ClusterName": "` + string(testdata.ClusterName) + `",
+			"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+			"Report": {
+				"fingerprints": [], "info": [], "skips": [], "system": {},
+				"reports": [{"component": "rule.module", "details": {}}]
+			}
+		}`),
+	})
+
+	assert.Equal(t, initInvalidJSON+1, getCounterVecValue(metrics.RejectedMessages, invalidJSONLabels))
+	assert.Equal(t, initSchema+1, getCounterVecValue(metrics.RejectedMessages, schemaLabels))
+}
+
+// TestConsumedMessagesByVersionMetric tests that consumed messages are counted
+// by their schema version
+func TestConsumedMessagesByVersionMetric(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	versionLabels := map[string]string{"version": "1"}
+	unsupportedLabels := map[string]string{"version": "unsupported"}
+
+	// other tests may run at the same process
+	initVersion := getCounterVecValue(metrics.ConsumedMessagesByVersion, versionLabels)
+	initUnsupported := getCounterVecValue(metrics.ConsumedMessagesByVersion, unsupportedLabels)
+
+	// message without version
+	kafkaConsumer.HandleMessage(&broker.Message{
+		Topic: testTopicName,
+		Value: []byte(testdata.ConsumerMessage),
+	})
+	kafkaConsumer.HandleMessage(&broker.Message{
+		Topic: testTopicName,
+		Value: []byte(`{"Version": 1}`),
+	})
+
+	assert.Equal(t, initVersion+1, getCounterVecValue(metrics.ConsumedMessagesByVersion, versionLabels))
+	assert.Equal(t, initUnsupported+1, getCounterVecValue(metrics.ConsumedMessagesByVersion, unsupportedLabels))
+}
+
+// TestOrgAllowlistMessagesMetric tests that messages checked against the
+// organization allowlist are counted per organization and decision
+func TestOrgAllowlistMessagesMetric(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{
+			OrgAllowlistEnabled: true,
+			OrgAllowlist:        mapset.NewSetWith(testdata.OrgID),
+		},
+		Storage: mockStorage,
+	}
+
+	allowedLabels := map[string]string{"organization": fmt.Sprint(testdata.OrgID), "decision": "allowed"}
+	deniedLabels := map[string]string{"organization": fmt.Sprint(testdata.Org2ID), "decision": "denied"}
+
+	// other tests may run at the same process
+	initAllowed := getCounterVecValue(metrics.OrgAllowlistMessages, allowedLabels)
+	initDenied := getCounterVecValue(metrics.OrgAllowlistMessages, deniedLabels)
+
+	kafkaConsumer.HandleMessage(&broker.Message{
+		Topic: testTopicName,
+		Value: []byte(testdata.ConsumerMessage),
+	})
+	kafkaConsumer.HandleMessage(&broker.Message{
+		Topic: testTopicName,
+		Value: []byte(`{
+			"OrgID": ` + fmt.Sprint(testdata.Org2ID) + `,
+			"ClusterName": "` + string(testdata.ClusterName) + `",
+			"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+			"Report": ` + testdata.ConsumerReport + `
+		}`),
+	})
+
+	assert.Equal(t, initAllowed+1, getCounterVecValue(metrics.OrgAllowlistMessages, allowedLabels))
+	assert.Equal(t, initDenied+1, getCounterVecValue(metrics.OrgAllowlistMessages, deniedLabels))
+}
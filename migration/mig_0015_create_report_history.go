@@ -0,0 +1,64 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0015CreateReportHistory adds tables that keep all reports (and their
+// rule hits) ever written for a cluster, not only the latest one
+var mig0015CreateReportHistory = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE report_history (
+				org_id          INTEGER NOT NULL,
+				cluster         VARCHAR NOT NULL,
+				report          VARCHAR NOT NULL,
+				reported_at     TIMESTAMP,
+				last_checked_at TIMESTAMP NOT NULL,
+				kafka_offset    BIGINT NOT NULL DEFAULT 0,
+				PRIMARY KEY(org_id, cluster, last_checked_at)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE TABLE rule_hit_history (
+				org_id          INTEGER NOT NULL,
+				cluster_id      VARCHAR NOT NULL,
+				rule_fqdn       VARCHAR NOT NULL,
+				error_key       VARCHAR NOT NULL,
+				template_data   VARCHAR NOT NULL,
+				last_checked_at TIMESTAMP NOT NULL,
+				PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key, last_checked_at)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE rule_hit_history`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DROP TABLE report_history`)
+		return err
+	},
+}
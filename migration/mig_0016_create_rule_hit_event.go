@@ -0,0 +1,51 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0016CreateRuleHitEvent adds a table with rule hits that appeared,
+// disappeared or changed between two consecutive reports for a cluster
+var mig0016CreateRuleHitEvent = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE rule_hit_event (
+				org_id          INTEGER NOT NULL,
+				cluster_id      VARCHAR NOT NULL,
+				rule_fqdn       VARCHAR NOT NULL,
+				error_key       VARCHAR NOT NULL,
+				event_type      VARCHAR NOT NULL,
+				template_data   VARCHAR NOT NULL,
+				last_checked_at TIMESTAMP NOT NULL,
+				PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key, last_checked_at)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`CREATE INDEX rule_hit_event_org_id_idx ON rule_hit_event (org_id, last_checked_at)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE rule_hit_event`)
+		return err
+	},
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0017AddReplayFieldsToConsumerError adds columns that keep the result of
+// the last attempt to replay the message that the consumer was unable to process
+var mig0017AddReplayFieldsToConsumerError = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			ALTER TABLE consumer_error ADD COLUMN replay_status VARCHAR NOT NULL DEFAULT ''
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			ALTER TABLE consumer_error ADD COLUMN replayed_at TIMESTAMP
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			ALTER TABLE consumer_error ADD COLUMN replay_error VARCHAR NOT NULL DEFAULT ''
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverSQLite3 {
+			return downgradeTable(tx, consumerErrorTable, `
+				CREATE TABLE consumer_error (
+					topic           VARCHAR NOT NULL,
+					partition       INTEGER NOT NULL,
+					topic_offset    INTEGER NOT NULL,
+					key             VARCHAR,
+					produced_at     TIMESTAMP NOT NULL,
+					consumed_at     TIMESTAMP NOT NULL,
+					message         VARCHAR,
+					error           VARCHAR NOT NULL,
+
+					PRIMARY KEY(topic, partition, topic_offset)
+				)
+			`, []string{
+				"topic", "partition", "topic_offset", "key", "produced_at", "consumed_at", "message", "error",
+			})
+		}
+
+		_, err := tx.Exec(`
+			ALTER TABLE consumer_error
+				DROP COLUMN replay_status,
+				DROP COLUMN replayed_at,
+				DROP COLUMN replay_error
+		`)
+		return err
+	},
+}
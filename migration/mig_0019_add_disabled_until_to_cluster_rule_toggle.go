@@ -0,0 +1,57 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0019AddDisabledUntilToClusterRuleToggle adds a column with time limit of
+// rule disabling, the rule is treated as enabled again after that time
+var mig0019AddDisabledUntilToClusterRuleToggle = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			ALTER TABLE cluster_rule_toggle ADD COLUMN disabled_until TIMESTAMP NULL
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverSQLite3 {
+			return downgradeTable(tx, clusterRuleToggleTable, `
+				CREATE TABLE cluster_rule_toggle (
+					cluster_id VARCHAR NOT NULL,
+					rule_id VARCHAR NOT NULL,
+					user_id VARCHAR NULL,
+					disabled SMALLINT NOT NULL,
+					disabled_at TIMESTAMP NULL,
+					enabled_at TIMESTAMP NULL,
+					updated_at TIMESTAMP NOT NULL,
+
+					CHECK (disabled >= 0 AND disabled <= 1),
+					PRIMARY KEY(cluster_id, rule_id)
+				)
+			`, []string{
+				"cluster_id", "rule_id", "user_id", "disabled", "disabled_at", "enabled_at", "updated_at",
+			})
+		}
+
+		_, err := tx.Exec(`ALTER TABLE cluster_rule_toggle DROP COLUMN disabled_until`)
+		return err
+	},
+}
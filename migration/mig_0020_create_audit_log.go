@@ -0,0 +1,51 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0020CreateAuditLog adds an append-only table recording changes made by
+// users together with the values before and after the change
+var mig0020CreateAuditLog = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE audit_log (
+				created_at   TIMESTAMP NOT NULL,
+				user_id      VARCHAR NOT NULL,
+				org_id       INTEGER NOT NULL,
+				request_id   VARCHAR NOT NULL,
+				action       VARCHAR NOT NULL,
+				target       VARCHAR NOT NULL,
+				before_value VARCHAR,
+				after_value  VARCHAR
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`CREATE INDEX audit_log_org_id_idx ON audit_log (org_id, created_at)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE audit_log`)
+		return err
+	},
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0021CreateClusterMetadata adds a table with attributes of clusters
+// extracted from the latest report, like the OCP version or the platform
+var mig0021CreateClusterMetadata = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE cluster_metadata (
+				org_id          INTEGER NOT NULL,
+				cluster         VARCHAR NOT NULL,
+				ocp_version     VARCHAR NOT NULL DEFAULT '',
+				platform        VARCHAR NOT NULL DEFAULT '',
+				nodes           INTEGER NOT NULL DEFAULT 0,
+				master_nodes    INTEGER NOT NULL DEFAULT 0,
+				worker_nodes    INTEGER NOT NULL DEFAULT 0,
+				request_id      VARCHAR NOT NULL DEFAULT '',
+				schema_version  INTEGER NOT NULL DEFAULT 0,
+				last_checked_at TIMESTAMP NOT NULL,
+
+				PRIMARY KEY(cluster)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`CREATE INDEX cluster_metadata_org_id_idx ON cluster_metadata (org_id)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE cluster_metadata`)
+		return err
+	},
+}
@@ -47,6 +47,7 @@ const (
 	clusterRuleUserFeedbackTable = "cluster_rule_user_feedback"
 	clusterReportTable           = "report"
 	clusterRuleToggleTable       = "cluster_rule_toggle"
+	consumerErrorTable           = "consumer_error"
 )
 
 // GetMaxVersion returns the highest available migration version.
@@ -31,4 +31,12 @@ var migrations = []Migration{
 	mig0012CreateClusterUserRuleDisableFeedback,
 	mig0013AddRuleHitTable,
 	mig0014ModifyClusterRuleToggle,
+	mig0015CreateReportHistory,
+	mig0016CreateRuleHitEvent,
+	mig0017AddReplayFieldsToConsumerError,
+	mig0018CreateRuleAck,
+	mig0019AddDisabledUntilToClusterRuleToggle,
+	mig0020CreateAuditLog,
+	mig0021CreateClusterMetadata,
+	mig0022CreateOrgAllowlist,
 }
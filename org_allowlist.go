@@ -0,0 +1,195 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// orgAllowlistReloaderInstance reloads the organization allowlist of the
+// running service, it's nil when the allowlist is disabled
+var orgAllowlistReloaderInstance *orgAllowlistReloader
+
+// orgAllowlistReloader reloads the organization allowlist shared by the
+// consumer and the ingestion endpoint from its source
+type orgAllowlistReloader struct {
+	allowlist mapset.Set
+	load      func() (mapset.Set, error)
+	// mutex serializes reloads requested by the signal and by the admin endpoint
+	mutex sync.Mutex
+}
+
+// newOrgAllowlistReloader constructs reloader that updates the given
+// allowlist from the source selected in the configuration
+func newOrgAllowlistReloader(allowlist mapset.Set, source string) *orgAllowlistReloader {
+	load := conf.LoadOrganizationAllowlistFile
+	if source == conf.OrgAllowlistSourceDB {
+		load = loadOrgAllowlistFromDB
+	}
+
+	return &orgAllowlistReloader{
+		allowlist: allowlist,
+		load:      load,
+	}
+}
+
+// readOrgAllowlist reads the organization allowlist from the storage
+func readOrgAllowlist(dbStorage storage.Storage) (mapset.Set, error) {
+	orgIDs, err := dbStorage.ReadOrgAllowlist()
+	if err != nil {
+		return nil, err
+	}
+
+	allowlist := mapset.NewSet()
+	for _, orgID := range orgIDs {
+		allowlist.Add(orgID)
+	}
+
+	return allowlist, nil
+}
+
+// loadOrgAllowlistFromDB opens the storage and reads the organization
+// allowlist from it
+func loadOrgAllowlistFromDB() (mapset.Set, error) {
+	dbStorage, err := createStorage()
+	if err != nil {
+		return nil, err
+	}
+	defer closeStorage(dbStorage)
+
+	return readOrgAllowlist(dbStorage)
+}
+
+// loadOrgAllowlist fills the allowlist of the broker configuration from the
+// storage when it's read from the DB. It has to be called before messages are
+// processed by consumers constructed outside of the running service,
+// otherwise all organizations are rejected.
+func loadOrgAllowlist(dbStorage storage.Storage, brokerConf broker.Configuration) error {
+	if !brokerConf.OrgAllowlistEnabled || conf.GetOrgAllowlistSource() != conf.OrgAllowlistSourceDB {
+		return nil
+	}
+
+	allowlist, err := readOrgAllowlist(dbStorage)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read organization allowlist")
+		return err
+	}
+
+	replaceOrgAllowlist(brokerConf.OrgAllowlist, allowlist)
+	log.Info().Msgf("Organization allowlist loaded, %d organization(s) allowed", allowlist.Cardinality())
+
+	return nil
+}
+
+// replaceOrgAllowlist updates the allowlist in place, so the change is seen
+// by all its users. New organizations are added before the removed ones are
+// removed, so organizations present in both allowlists are allowed all the
+// time.
+func replaceOrgAllowlist(allowlist, newAllowlist mapset.Set) {
+	for _, orgID := range newAllowlist.ToSlice() {
+		allowlist.Add(orgID)
+	}
+
+	for _, orgID := range allowlist.ToSlice() {
+		if !newAllowlist.Contains(orgID) {
+			allowlist.Remove(orgID)
+		}
+	}
+}
+
+// ReloadOrgAllowlist reads the allowlist from its source again and returns
+// the number of allowed organizations. The current allowlist is kept when
+// the source can't be read.
+func (reloader *orgAllowlistReloader) ReloadOrgAllowlist() (int, error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	newAllowlist, err := reloader.load()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to reload organization allowlist, the current one is kept")
+		return 0, err
+	}
+
+	replaceOrgAllowlist(reloader.allowlist, newAllowlist)
+
+	count := reloader.allowlist.Cardinality()
+	log.Info().Msgf("Organization allowlist reloaded, %d organization(s) allowed", count)
+
+	return count, nil
+}
+
+// runOrgAllowlistReloadWorker reloads the allowlist whenever a signal is
+// received until the context is done. Errors are logged only, the current
+// allowlist is kept.
+func runOrgAllowlistReloadWorker(
+	ctx context.Context, reloader *orgAllowlistReloader, signals <-chan os.Signal,
+) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Info().Msg("Reloading organization allowlist on signal")
+			_, _ = reloader.ReloadOrgAllowlist()
+		}
+	}
+}
+
+// startOrgAllowlistReloadWorker reloads the allowlist on SIGHUP until the
+// context is done
+func startOrgAllowlistReloadWorker(ctx context.Context, reloader *orgAllowlistReloader) {
+	signals := make(chan os.Signal, 1)
+
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	runOrgAllowlistReloadWorker(ctx, reloader, signals)
+}
+
+// prepareOrgAllowlist constructs reloader of the allowlist, which is read
+// from the DB for the first time, and starts the worker reloading it on
+// SIGHUP until the context is done
+func prepareOrgAllowlist(
+	ctx context.Context, errorGroup *errgroup.Group, brokerConf broker.Configuration,
+) int {
+	source := conf.GetOrgAllowlistSource()
+	orgAllowlistReloaderInstance = newOrgAllowlistReloader(brokerConf.OrgAllowlist, source)
+
+	// allowlist read from the CSV file is loaded with the configuration already
+	if source == conf.OrgAllowlistSourceDB {
+		if _, err := orgAllowlistReloaderInstance.ReloadOrgAllowlist(); err != nil {
+			return ExitStatusPrepareDbError
+		}
+	}
+
+	errorGroup.Go(func() error {
+		startOrgAllowlistReloadWorker(ctx, orgAllowlistReloaderInstance)
+		return nil
+	})
+
+	return ExitStatusOK
+}
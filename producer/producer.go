@@ -75,17 +75,29 @@ type PayloadTrackerMessage struct {
 	Date      string `json:"date"`
 }
 
+// NotificationMessage represents content of messages sent to the
+// notification topic in Kafka when a rule hit not present in the previous
+// report is found for a cluster.
+type NotificationMessage struct {
+	OrgID        types.OrgID       `json:"org_id"`
+	ClusterName  types.ClusterName `json:"cluster_id"`
+	RuleFQDN     types.RuleID      `json:"rule_fqdn"`
+	ErrorKey     types.ErrorKey    `json:"error_key"`
+	TemplateData interface{}       `json:"template_data"`
+	Timestamp    string            `json:"timestamp"`
+}
+
 // produceMessage produces message to selected topic. That function returns
 // partition ID and offset of new message or an error value in case of any
 // problem on broker side.
-func (producer *KafkaProducer) produceMessage(trackerMsg PayloadTrackerMessage) (int32, int64, error) {
-	jsonBytes, err := json.Marshal(trackerMsg)
+func (producer *KafkaProducer) produceMessage(topic string, payload interface{}) (int32, int64, error) {
+	jsonBytes, err := json.Marshal(payload)
 	if err != nil {
 		return 0, 0, err
 	}
 
 	producerMsg := &sarama.ProducerMessage{
-		Topic: producer.Configuration.PayloadTrackerTopic,
+		Topic: topic,
 		Value: sarama.ByteEncoder(jsonBytes),
 	}
 
@@ -110,7 +122,7 @@ func (producer *KafkaProducer) TrackPayload(reqID types.RequestID, timestamp tim
 		return nil
 	}
 
-	_, _, err := producer.produceMessage(PayloadTrackerMessage{
+	_, _, err := producer.produceMessage(producer.Configuration.PayloadTrackerTopic, PayloadTrackerMessage{
 		Service:   producer.Configuration.ServiceName,
 		RequestID: string(reqID),
 		Status:    status,
@@ -127,6 +139,26 @@ func (producer *KafkaProducer) TrackPayload(reqID types.RequestID, timestamp tim
 	return nil
 }
 
+// NotifyNewRuleHit publishes information about a new rule hit found for a
+// cluster to the notification Kafka topic. Nothing is sent when the
+// notification topic is not configured.
+func (producer *KafkaProducer) NotifyNewRuleHit(notification NotificationMessage) error {
+	if len(producer.Configuration.NotificationTopic) == 0 {
+		return nil
+	}
+
+	_, _, err := producer.produceMessage(producer.Configuration.NotificationTopic, notification)
+	if err != nil {
+		log.Error().Err(err).Msgf(
+			"unable to produce notification message (org: %v, cluster: '%s', rule: '%s', error key: '%s')",
+			notification.OrgID, notification.ClusterName, notification.RuleFQDN, notification.ErrorKey)
+
+		return err
+	}
+
+	return nil
+}
+
 // Close allow the Sarama producer to be gracefully closed
 func (producer *KafkaProducer) Close() error {
 	if err := producer.Producer.Close(); err != nil {
@@ -17,6 +17,7 @@ limitations under the License.
 package producer_test
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
@@ -112,6 +113,84 @@ func TestProducerTrackPayloadWithError(t *testing.T) {
 	assert.EqualError(t, err, producerErrorMessage)
 }
 
+// TestProducerNotifyNewRuleHit calls the NotifyNewRuleHit function using a mock Sarama producer.
+func TestProducerNotifyNewRuleHit(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(val []byte) error {
+		var notification producer.NotificationMessage
+
+		err := json.Unmarshal(val, &notification)
+		if err != nil {
+			return err
+		}
+
+		assert.Equal(t, testdata.OrgID, notification.OrgID)
+		assert.Equal(t, testdata.ClusterName, notification.ClusterName)
+		assert.Equal(t, testdata.Rule1ID, notification.RuleFQDN)
+		return nil
+	})
+
+	cfg := brokerCfg
+	cfg.NotificationTopic = "notification-topic"
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: cfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	err := kafkaProducer.NotifyNewRuleHit(producer.NotificationMessage{
+		OrgID:       testdata.OrgID,
+		ClusterName: testdata.ClusterName,
+		RuleFQDN:    testdata.Rule1ID,
+		ErrorKey:    testdata.ErrorKey1,
+		Timestamp:   testTimestamp.UTC().Format(time.RFC3339Nano),
+	})
+	assert.NoError(t, err, "notification failed")
+}
+
+// TestProducerNotifyNewRuleHitNoTopic checks that nothing is sent when
+// the notification topic is not configured.
+func TestProducerNotifyNewRuleHitNoTopic(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: brokerCfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	err := kafkaProducer.NotifyNewRuleHit(producer.NotificationMessage{})
+	assert.NoError(t, err, "notification failed")
+}
+
+// TestProducerNotifyNewRuleHitWithError checks that errors
+// from the underlying producer are correctly returned.
+func TestProducerNotifyNewRuleHitWithError(t *testing.T) {
+	const producerErrorMessage = "unable to send the message"
+
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(errors.New(producerErrorMessage))
+
+	cfg := brokerCfg
+	cfg.NotificationTopic = "notification-topic"
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: cfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	err := kafkaProducer.NotifyNewRuleHit(producer.NotificationMessage{})
+	assert.EqualError(t, err, producerErrorMessage)
+}
+
 // TestProducerClose makes sure it's possible to close the producer.
 func TestProducerClose(t *testing.T) {
 	mockProducer := mocks.NewSyncProducer(t, nil)
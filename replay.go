@@ -0,0 +1,154 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// parseReplayFilter parses arguments of replay-consumer-errors subcommand
+// into filter selecting messages to be replayed
+func parseReplayFilter(args []string) (storage.ConsumerErrorFilter, error) {
+	var (
+		filter          storage.ConsumerErrorFilter
+		from, to        string
+		includeReplayed bool
+	)
+
+	flags := flag.NewFlagSet("replay-consumer-errors", flag.ContinueOnError)
+	flags.StringVar(&from, "from", "", "replay messages consumed at this time (RFC 3339) or later")
+	flags.StringVar(&to, "to", "", "replay messages consumed before this time (RFC 3339)")
+	flags.StringVar(&filter.Topic, "topic", "", "replay messages consumed from this topic")
+	flags.StringVar(&filter.ErrorContains, "error", "", "replay messages with error containing this text")
+	flags.BoolVar(&includeReplayed, "all", false, "replay also messages that were replayed successfully already")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return filter, err
+	}
+
+	if from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	if to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	filter.SkipReplayed = !includeReplayed
+
+	return filter, nil
+}
+
+// replayConsumerErrors feeds messages that the consumer was unable to process
+// back to the consumer and stores the result of processing for each of them.
+// Numbers of successfully replayed and still failing messages are returned.
+func replayConsumerErrors(
+	dbStorage storage.Storage, brokerConf broker.Configuration, filter storage.ConsumerErrorFilter,
+) (replayed, failing int, err error) {
+	consumerErrors, err := dbStorage.ReadConsumerErrors(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read consumer errors")
+		return 0, 0, err
+	}
+
+	log.Info().Msgf("%d message(s) to be replayed", len(consumerErrors))
+
+	err = loadOrgAllowlist(dbStorage, brokerConf)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// messages are not consumed from any broker, so consumer group is not needed
+	kafkaConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerConf,
+		Storage:       dbStorage,
+	}
+
+	for _, consumerError := range consumerErrors {
+		msg := &broker.Message{
+			Topic:     consumerError.Topic,
+			Partition: consumerError.Partition,
+			Offset:    consumerError.Offset,
+			Key:       []byte(consumerError.Key),
+			Value:     []byte(consumerError.Message),
+			Timestamp: consumerError.ProducedAt,
+		}
+
+		_, processingErr := kafkaConsumer.ProcessMessage(msg)
+		if processingErr != nil {
+			log.Warn().Err(processingErr).
+				Str("topic", msg.Topic).
+				Int32("partition", msg.Partition).
+				Int64("offset", msg.Offset).
+				Msg("Replayed message is still failing")
+			failing++
+		} else {
+			replayed++
+		}
+
+		err = dbStorage.MarkConsumerErrorReplayed(msg.Topic, msg.Partition, msg.Offset, processingErr)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to store result of replaying the message")
+			return replayed, failing, err
+		}
+	}
+
+	return replayed, failing, nil
+}
+
+// performConsumerErrorsReplay handles replay-consumer-errors subcommand. It
+// replays stored messages that the consumer was unable to process.
+func performConsumerErrorsReplay() int {
+	filter, err := parseReplayFilter(os.Args[2:])
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to parse arguments of replay-consumer-errors command")
+		return ExitStatusReplayError
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
+	// Ensure that the DB is at the latest migration version.
+	if exitCode := prepareDBMigrations(dbStorage); exitCode != ExitStatusOK {
+		return exitCode
+	}
+
+	replayed, failing, err := replayConsumerErrors(dbStorage, conf.GetBrokerConfiguration(), filter)
+	if err != nil {
+		return ExitStatusReplayError
+	}
+
+	log.Info().Msgf("%d message(s) replayed successfully, %d message(s) still failing", replayed, failing)
+	return ExitStatusOK
+}
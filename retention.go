@@ -0,0 +1,170 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// retentionCutoffs returns times before which the data are removed according
+// to the retention policy, zero time is returned for data kept forever
+func retentionCutoffs(
+	retentionCfg storage.RetentionConfiguration, now time.Time,
+) (reportsBefore, consumerErrorsBefore time.Time) {
+	if retentionCfg.ReportMaxAgeDays > 0 {
+		reportsBefore = now.AddDate(0, 0, -retentionCfg.ReportMaxAgeDays)
+	}
+
+	if retentionCfg.ConsumerErrorMaxAgeDays > 0 {
+		consumerErrorsBefore = now.AddDate(0, 0, -retentionCfg.ConsumerErrorMaxAgeDays)
+	}
+
+	return reportsBefore, consumerErrorsBefore
+}
+
+// isRetentionPolicySet checks whether the policy removes any data
+func isRetentionPolicySet(retentionCfg storage.RetentionConfiguration) bool {
+	return retentionCfg.ReportMaxAgeDays > 0 || retentionCfg.ConsumerErrorMaxAgeDays > 0
+}
+
+// applyRetentionPolicy removes stale data from the storage according to the
+// retention policy. Nothing is removed in dry-run mode, the data that would
+// be removed are returned only.
+func applyRetentionPolicy(
+	dbStorage storage.Storage, retentionCfg storage.RetentionConfiguration, dryRun bool,
+) (storage.RetentionResult, error) {
+	reportsBefore, consumerErrorsBefore := retentionCutoffs(retentionCfg, time.Now().UTC())
+
+	result, err := dbStorage.ApplyRetentionPolicy(reportsBefore, consumerErrorsBefore, dryRun)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to apply retention policy")
+		return result, err
+	}
+
+	log.Info().
+		Bool("dry_run", dryRun).
+		Int("clusters", len(result.StaleClusters)).
+		Int64("consumer_errors", result.ConsumerErrors).
+		Msg("Retention policy applied")
+
+	return result, nil
+}
+
+// runRetentionWorker applies the retention policy immediately and then in
+// the given interval until the context is done. Errors are logged only, the
+// policy is applied again in the next interval.
+func runRetentionWorker(
+	ctx context.Context, dbStorage storage.Storage, retentionCfg storage.RetentionConfiguration, interval time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = applyRetentionPolicy(dbStorage, retentionCfg, false)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startRetentionWorker opens the storage and enforces the retention policy
+// by the background worker until the context is done
+func startRetentionWorker(ctx context.Context, retentionCfg storage.RetentionConfiguration) error {
+	dbStorage, err := createStorage()
+	if err != nil {
+		return err
+	}
+	defer closeStorage(dbStorage)
+
+	log.Info().Msgf("Retention policy is enforced every %d hour(s)", retentionCfg.CleanupIntervalHours)
+
+	runRetentionWorker(ctx, dbStorage, retentionCfg, time.Duration(retentionCfg.CleanupIntervalHours)*time.Hour)
+
+	return nil
+}
+
+// parseCleanupArgs parses arguments of cleanup subcommand
+func parseCleanupArgs(args []string) (dryRun bool, err error) {
+	flags := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	flags.BoolVar(&dryRun, "dry-run", false, "only print data that would be removed")
+
+	err = flags.Parse(args)
+
+	return dryRun, err
+}
+
+// printRetentionResult prints clusters and number of consumer errors that
+// would be removed by the retention policy
+func printRetentionResult(result storage.RetentionResult) {
+	for _, cluster := range result.StaleClusters {
+		fmt.Printf(
+			"%v\t%v\t%v\n", cluster.OrgID, cluster.ClusterName, cluster.LastCheckedAt.UTC().Format(time.RFC3339),
+		)
+	}
+
+	fmt.Printf(
+		"%d cluster(s) and %d consumer error(s) would be removed\n",
+		len(result.StaleClusters), result.ConsumerErrors,
+	)
+}
+
+// performCleanup handles cleanup subcommand. It removes stale data according
+// to the retention policy or prints them only in dry-run mode.
+func performCleanup() int {
+	dryRun, err := parseCleanupArgs(os.Args[2:])
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to parse arguments of cleanup command")
+		return ExitStatusCleanupError
+	}
+
+	retentionCfg := conf.GetRetentionConfiguration()
+	if !isRetentionPolicySet(retentionCfg) {
+		log.Warn().Msg("Retention policy is not configured, nothing to remove")
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
+	// Ensure that the DB is at the latest migration version.
+	if exitCode := prepareDBMigrations(dbStorage); exitCode != ExitStatusOK {
+		return exitCode
+	}
+
+	result, err := applyRetentionPolicy(dbStorage, retentionCfg, dryRun)
+	if err != nil {
+		return ExitStatusCleanupError
+	}
+
+	if dryRun {
+		printRetentionResult(result)
+	}
+
+	return ExitStatusOK
+}
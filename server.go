@@ -20,6 +20,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
 )
 
@@ -44,6 +45,20 @@ func startServer() error {
 
 	serverInstance = server.New(serverCfg, dbStorage)
 
+	// reports pushed directly are processed in the same way as consumed ones,
+	// messages are not consumed by this instance, so consumer group is not needed
+	if serverCfg.EnableIngestion {
+		serverInstance.ReportProcessor = &consumer.KafkaConsumer{
+			Configuration: conf.GetBrokerConfiguration(),
+			Storage:       dbStorage,
+		}
+	}
+
+	// the allowlist can be reloaded by the admin endpoint only when it's enabled
+	if orgAllowlistReloaderInstance != nil {
+		serverInstance.OrgAllowlistReloader = orgAllowlistReloaderInstance
+	}
+
 	err = serverInstance.Start(finishServerInstanceInitialization)
 	if err != nil {
 		log.Error().Err(err).Msg("HTTP(s) start error")
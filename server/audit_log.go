@@ -0,0 +1,97 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// requestIDHeader is the header containing ID of the request that is
+// recorded into the audit log
+const requestIDHeader = "x-rh-insights-request-id"
+
+// auditedStorage returns storage that records changes made on behalf of the
+// caller into the audit log. User is taken from the identity of the caller,
+// it's empty when authentication is disabled.
+func (server *HTTPServer) auditedStorage(request *http.Request) storage.Storage {
+	info := storage.AuditInfo{
+		RequestID: types.RequestID(request.Header.Get(requestIDHeader)),
+	}
+
+	if identity, ok := request.Context().Value(types.ContextKeyUser).(Identity); ok {
+		info.UserID = identity.AccountNumber
+	}
+
+	return server.Storage.WithAuditInfo(info)
+}
+
+// readAuditLogForOrg returns changes made by users of the organization,
+// the most recent changes come first. Results can be filtered by `action`,
+// `user_id`, `since` and `until` query parameters and paginated by `limit`
+// and `offset` query parameters.
+func (server *HTTPServer) readAuditLogForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	pagination, successful := readListPagination(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	since, until, successful := readTimeRangeQueryParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	query := request.URL.Query()
+	filter := storage.AuditLogFilter{
+		OrgID:  orgID,
+		UserID: types.UserID(query.Get("user_id")),
+		Action: storage.AuditAction(query.Get("action")),
+		Since:  since,
+		Until:  until,
+		Limit:  pagination.Limit,
+		Offset: pagination.Offset,
+	}
+
+	entries, err := server.Storage.ReadAuditLog(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read audit log")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("audit_log", entries))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
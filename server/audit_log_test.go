@@ -0,0 +1,124 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestHTTPServer_AuditLogForOrg_Empty(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AuditLogForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"audit_log":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_AuditLogForOrg_Vote(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.LikeRuleEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.UserID},
+		UserID:       testdata.UserID,
+		OrgID:        testdata.OrgID,
+		ExtraHeaders: http.Header{"X-Rh-Insights-Request-Id": []string{"request-1"}},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AuditLogForOrgEndpoint + "?action=vote",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				AuditLog []storage.AuditLogEntry `json:"audit_log"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Len(t, response.AuditLog, 1)
+			assert.Equal(t, testdata.UserID, response.AuditLog[0].UserID)
+			assert.Equal(t, types.RequestID("request-1"), response.AuditLog[0].RequestID)
+			assert.Equal(t, storage.AuditActionVote, response.AuditLog[0].Action)
+			assert.JSONEq(t, `null`, string(response.AuditLog[0].Before))
+		},
+	})
+}
+
+// TestHTTPServer_AuditLogForOrg_WithoutIdentity checks that changes made
+// without identity of the caller are recorded for the changed organization
+func TestHTTPServer_AuditLogForOrg_WithoutIdentity(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.RuleAckForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID, testdata.Rule1ID},
+		Body:         `{"justification": "not relevant for our fleet"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AuditLogForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				AuditLog []storage.AuditLogEntry `json:"audit_log"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Len(t, response.AuditLog, 1)
+			assert.Equal(t, testdata.OrgID, response.AuditLog[0].OrgID)
+			assert.Equal(t, types.UserID(""), response.AuditLog[0].UserID)
+			assert.Equal(t, storage.AuditActionRuleAck, response.AuditLog[0].Action)
+		},
+	})
+}
+
+func TestHTTPServer_AuditLogForOrg_BadSince(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AuditLogForOrgEndpoint + "?since=yesterday",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
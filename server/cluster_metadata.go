@@ -0,0 +1,56 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// readClusterDetails returns metadata of the cluster extracted from its
+// latest report, like the OCP version, platform or node counts
+func (server *HTTPServer) readClusterDetails(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	clusterName, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	metadata, err := server.Storage.ReadClusterMetadata(orgID, clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read cluster metadata")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("cluster", metadata))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustWriteClusterWithMetadata writes report and metadata of the cluster
+func mustWriteClusterWithMetadata(
+	t testing.TB, mockStorage storage.Storage, clusterName types.ClusterName, platform string, lastChecked time.Time,
+) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, clusterName, testdata.Report3Rules,
+		testdata.Report3RulesParsed, lastChecked, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteClusterMetadata(storage.ClusterMetadata{
+		OrgID:         testdata.OrgID,
+		ClusterName:   clusterName,
+		OCPVersion:    "4.6.1",
+		Platform:      platform,
+		Nodes:         5,
+		MasterNodes:   3,
+		WorkerNodes:   2,
+		RequestID:     "request-1",
+		SchemaVersion: 1,
+		LastCheckedAt: lastChecked,
+	})
+	helpers.FailOnError(t, err)
+}
+
+func TestHTTPServer_ClusterDetails(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteClusterWithMetadata(t, mockStorage, testdata.ClusterName, "AWS", testdata.LastCheckedAt)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterDetailsEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"cluster": {
+				"org_id": ` + helpers.ToJSONString(testdata.OrgID) + `,
+				"cluster": "` + string(testdata.ClusterName) + `",
+				"ocp_version": "4.6.1",
+				"platform": "AWS",
+				"nodes": 5,
+				"master_nodes": 3,
+				"worker_nodes": 2,
+				"request_id": "request-1",
+				"schema_version": 1,
+				"last_checked_at": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `"
+			},
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestHTTPServer_ClusterDetails_NotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterDetailsEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+func TestHTTPServer_ClusterDetails_BadClusterName(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterDetailsEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.BadClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+func TestListOfClustersForOrganizationByMetadata(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteClusterWithMetadata(t, mockStorage, firstListedCluster, "AWS", testdata.LastCheckedAt)
+	mustWriteClusterWithMetadata(t, mockStorage, secondListedCluster, "GCP", testdata.LastCheckedAt)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?ocp_version=4.6&platform=GCP",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(secondListedCluster) + `"],
+			"meta": {"total": 1, "limit": 0, "offset": 0},
+			"status": "ok"
+		}`,
+	})
+}
@@ -25,6 +25,14 @@ type Configuration struct {
 	Auth                         bool   `mapstructure:"auth" toml:"auth"`
 	AuthType                     string `mapstructure:"auth_type" toml:"auth_type"`
 	MaximumFeedbackMessageLength int    `mapstructure:"maximum_feedback_message_length" toml:"maximum_feedback_message_length"`
-	// OrgOverviewLimitHours is temporary until request param parsing, but lets make it atleast configurable
+	// OrgOverviewLimitHours is the default age limit of reports of clusters listed for an organization,
+	// it is used when the `since` query parameter is not provided, 0 means no limit
 	OrgOverviewLimitHours int64 `mapstructure:"org_overview_limit_hours" toml:"org_overview_limit_hours"`
+	// EnableIngestion enables the endpoint that accepts reports pushed directly instead of consuming them from the broker
+	EnableIngestion bool `mapstructure:"enable_ingestion" toml:"enable_ingestion"`
+	// MaximumIngestedReportSize is the maximum size of message pushed to the ingestion endpoint in bytes,
+	// default size is used when it's not set
+	MaximumIngestedReportSize int64 `mapstructure:"maximum_ingested_report_size" toml:"maximum_ingested_report_size"`
+	// AdminUsers are IDs of users allowed to use admin endpoints, like the erasure of organization data
+	AdminUsers []string `mapstructure:"admin_users" toml:"admin_users"`
 }
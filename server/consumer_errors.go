@@ -0,0 +1,225 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// defaultConsumerErrorsLimit is the number of consumer errors returned when
+// `limit` query parameter is not provided
+const defaultConsumerErrorsLimit = 100
+
+// readConsumerErrorFilter retrieves filter for consumer errors from
+// request's query parameters `topic`, `partition`, `from`, `to` and `error`
+// if it's not possible, it writes http error to the writer and returns false
+func readConsumerErrorFilter(writer http.ResponseWriter, request *http.Request) (storage.ConsumerErrorFilter, bool) {
+	var (
+		filter     storage.ConsumerErrorFilter
+		successful bool
+	)
+
+	query := request.URL.Query()
+	filter.Topic = query.Get("topic")
+	filter.ErrorContains = query.Get("error")
+
+	if value := query.Get("partition"); value != "" {
+		partition, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			handleServerError(writer, &RouterParsingError{
+				ParamName:  "partition",
+				ParamValue: value,
+				ErrString:  "integer expected",
+			})
+			return filter, false
+		}
+
+		partition32 := int32(partition)
+		filter.Partition = &partition32
+	}
+
+	filter.From, successful = readTimeQueryParam(writer, request, "from")
+	if !successful {
+		return filter, false
+	}
+
+	filter.To, successful = readTimeQueryParam(writer, request, "to")
+	if !successful {
+		return filter, false
+	}
+
+	return filter, true
+}
+
+// readConsumerErrorID retrieves topic, partition and offset identifying the
+// consumer error from request's path
+// if it's not possible, it writes http error to the writer and returns false
+func readConsumerErrorID(writer http.ResponseWriter, request *http.Request) (string, int32, int64, bool) {
+	topic, err := getRouterParam(request, "topic")
+	if err != nil {
+		handleServerError(writer, err)
+		return "", 0, 0, false
+	}
+
+	rawPartition, err := getRouterParam(request, "partition")
+	if err != nil {
+		handleServerError(writer, err)
+		return "", 0, 0, false
+	}
+
+	partition, err := strconv.ParseInt(rawPartition, 10, 32)
+	if err != nil {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  "partition",
+			ParamValue: rawPartition,
+			ErrString:  "integer expected",
+		})
+		return "", 0, 0, false
+	}
+
+	rawOffset, err := getRouterParam(request, "offset")
+	if err != nil {
+		handleServerError(writer, err)
+		return "", 0, 0, false
+	}
+
+	offset, err := strconv.ParseInt(rawOffset, 10, 64)
+	if err != nil {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  "offset",
+			ParamValue: rawOffset,
+			ErrString:  "integer expected",
+		})
+		return "", 0, 0, false
+	}
+
+	return topic, int32(partition), offset, true
+}
+
+// listConsumerErrors returns messages that the consumer was unable to
+// process, without the message bodies. Results can be filtered by query
+// parameters and paginated by `limit` and `offset` query parameters.
+func (server *HTTPServer) listConsumerErrors(writer http.ResponseWriter, request *http.Request) {
+	filter, successful := readConsumerErrorFilter(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	filter.Limit, successful = readUintQueryParam(writer, request, "limit", defaultConsumerErrorsLimit)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	filter.Skip, successful = readUintQueryParam(writer, request, "offset", 0)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	consumerErrors, err := server.Storage.ReadConsumerErrors(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read consumer errors")
+		handleServerError(writer, err)
+		return
+	}
+
+	// message bodies can be large, they are returned for single consumer error only
+	for i := range consumerErrors {
+		consumerErrors[i].Message = ""
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("consumer_errors", consumerErrors))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getConsumerError returns one message that the consumer was unable to
+// process including the raw message body
+func (server *HTTPServer) getConsumerError(writer http.ResponseWriter, request *http.Request) {
+	topic, partition, offset, successful := readConsumerErrorID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	consumerError, err := server.Storage.ReadConsumerError(topic, partition, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read consumer error")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("consumer_error", consumerError))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// deleteConsumerErrors deletes messages that the consumer was unable to
+// process selected by query parameters and returns number of deleted records.
+// At least one filter or `all=true` query parameter is required, so all
+// records are not deleted by mistake.
+func (server *HTTPServer) deleteConsumerErrors(writer http.ResponseWriter, request *http.Request) {
+	filter, successful := readConsumerErrorFilter(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	deleteAll := false
+	if value := request.URL.Query().Get("all"); value != "" {
+		var err error
+		deleteAll, err = strconv.ParseBool(value)
+		if err != nil {
+			handleServerError(writer, &RouterParsingError{
+				ParamName:  "all",
+				ParamValue: value,
+				ErrString:  "boolean expected",
+			})
+			return
+		}
+	}
+
+	emptyFilter := filter.Topic == "" && filter.ErrorContains == "" && filter.Partition == nil &&
+		filter.From.IsZero() && filter.To.IsZero()
+	if emptyFilter && !deleteAll {
+		err := responses.SendBadRequest(writer, "at least one filter or all=true is expected")
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+
+	deleted, err := server.Storage.DeleteConsumerErrors(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to delete consumer errors")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("deleted", deleted))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+const consumerErrorsTopic = "ccx.ocp.results"
+
+func mustWriteConsumerErrors(t testing.TB, mockStorage storage.Storage, errorMessages ...string) {
+	for i, errorMessage := range errorMessages {
+		err := mockStorage.WriteConsumerError(&broker.Message{
+			Topic:     consumerErrorsTopic,
+			Partition: 0,
+			Offset:    int64(i),
+			Value:     []byte(testdata.ConsumerMessage),
+			Timestamp: testdata.LastCheckedAt,
+		}, errors.New(errorMessage))
+		helpers.FailOnError(t, err)
+	}
+}
+
+// checkConsumerErrorOffsets returns body checker that checks offsets of
+// returned consumer errors, because consumed_at timestamp is not known in advance
+func checkConsumerErrorOffsets(expected ...int64) func(t testing.TB, _, got []byte) {
+	return func(t testing.TB, _, got []byte) {
+		var response struct {
+			Status         string                  `json:"status"`
+			ConsumerErrors []storage.ConsumerError `json:"consumer_errors"`
+		}
+
+		helpers.FailOnError(t, json.Unmarshal(got, &response))
+		assert.Equal(t, "ok", response.Status)
+
+		offsets := make([]int64, 0)
+		for _, consumerError := range response.ConsumerErrors {
+			// message bodies are not listed
+			assert.Empty(t, consumerError.Message)
+			offsets = append(offsets, consumerError.Offset)
+		}
+		assert.Equal(t, expected, offsets)
+	}
+}
+
+func TestHTTPServer_ListConsumerErrors_Empty(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"consumer_errors":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_ListConsumerErrors(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerErrors(t, mockStorage, "unexpected EOF", "database is locked", "database is locked")
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode:  http.StatusOK,
+		BodyChecker: checkConsumerErrorOffsets(0, 1, 2),
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint + "?topic=" + consumerErrorsTopic + "&partition=0&error=locked&limit=1&offset=1",
+	}, &helpers.APIResponse{
+		StatusCode:  http.StatusOK,
+		BodyChecker: checkConsumerErrorOffsets(2),
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint + "?partition=1",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"consumer_errors":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_ListConsumerErrors_BadParams(t *testing.T) {
+	for param, errString := range map[string]string{
+		"partition": "integer expected",
+		"limit":     "unsigned integer expected",
+		"offset":    "unsigned integer expected",
+		"from":      "timestamp in RFC 3339 format or relative time like 7d expected",
+	} {
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:   http.MethodGet,
+			Endpoint: server.ConsumerErrorsEndpoint + "?" + param + "=x",
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: `{
+				"status": "Error during parsing param '` + param + `' with value 'x'. Error: '` + errString + `'"
+			}`,
+		})
+	}
+}
+
+func TestHTTPServer_ListConsumerErrors_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_GetConsumerError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerErrors(t, mockStorage, "unexpected EOF")
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ConsumerErrorEndpoint,
+		EndpointArgs: []interface{}{consumerErrorsTopic, 0, 0},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Status        string                `json:"status"`
+				ConsumerError storage.ConsumerError `json:"consumer_error"`
+			}
+
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Equal(t, "ok", response.Status)
+			assert.Equal(t, testdata.ConsumerMessage, response.ConsumerError.Message)
+			assert.Equal(t, "unexpected EOF", response.ConsumerError.Error)
+		},
+	})
+}
+
+func TestHTTPServer_GetConsumerError_NotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ConsumerErrorEndpoint,
+		EndpointArgs: []interface{}{consumerErrorsTopic, 0, 42},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID ` + consumerErrorsTopic + `/0/42 was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_GetConsumerError_BadParams(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ConsumerErrorEndpoint,
+		EndpointArgs: []interface{}{consumerErrorsTopic, "x", 0},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'partition' with value 'x'. Error: 'integer expected'"}`,
+	})
+
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ConsumerErrorEndpoint,
+		EndpointArgs: []interface{}{consumerErrorsTopic, 0, "x"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'offset' with value 'x'. Error: 'integer expected'"}`,
+	})
+}
+
+func TestHTTPServer_DeleteConsumerErrors(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerErrors(t, mockStorage, "unexpected EOF", "database is locked", "database is locked")
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodDelete,
+		Endpoint: server.ConsumerErrorsEndpoint + "?error=locked",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"deleted":2,"status":"ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode:  http.StatusOK,
+		BodyChecker: checkConsumerErrorOffsets(0),
+	})
+}
+
+// TestHTTPServer_DeleteConsumerErrors_NoFilter checks that all consumer
+// errors are deleted only when requested explicitly
+func TestHTTPServer_DeleteConsumerErrors_NoFilter(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerErrors(t, mockStorage, "unexpected EOF", "database is locked")
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodDelete,
+		Endpoint: server.ConsumerErrorsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "at least one filter or all=true is expected"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodDelete,
+		Endpoint: server.ConsumerErrorsEndpoint + "?all=false",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "at least one filter or all=true is expected"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodDelete,
+		Endpoint: server.ConsumerErrorsEndpoint + "?all=maybe",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'all' with value 'maybe'. Error: 'boolean expected'"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodDelete,
+		Endpoint: server.ConsumerErrorsEndpoint + "?all=true",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"deleted":2,"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_DeleteConsumerErrors_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodDelete,
+		Endpoint: server.ConsumerErrorsEndpoint + "?all=true",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
@@ -33,6 +33,8 @@ const (
 	DeleteClustersEndpoint = "clusters/{clusters}"
 	// OrganizationsEndpoint returns all organizations
 	OrganizationsEndpoint = "organizations"
+	// ClusterDetailsEndpoint returns metadata of {cluster} extracted from its latest report
+	ClusterDetailsEndpoint = "organizations/{org_id}/clusters/{cluster}"
 	// ReportEndpoint returns report for provided {organization}, {cluster}, and {user_id}
 	ReportEndpoint = "organizations/{org_id}/clusters/{cluster}/users/{user_id}/report"
 	// RuleEndpoint returns rule report for provided {organization} {cluster} and {rule_id}
@@ -43,6 +45,26 @@ const (
 	// ReportForListOfClustersPayloadEndpoint returns the latest reports for the given list of clusters
 	// Reports that are going to be returned are specified by list of cluster IDs that is part of request body
 	ReportForListOfClustersPayloadEndpoint = "organizations/{org_id}/clusters/reports"
+	// ReportHistoryEndpoint returns all previous reports for provided {org_id} and {cluster}
+	ReportHistoryEndpoint = "organizations/{org_id}/clusters/{cluster}/reports/history"
+	// ClusterRuleHitEventsEndpoint returns rule hits that appeared, disappeared or changed for {cluster}
+	ClusterRuleHitEventsEndpoint = "organizations/{org_id}/clusters/{cluster}/events"
+	// OrgRuleHitEventsEndpoint returns rule hits that appeared, disappeared or changed for all clusters in {org_id}
+	OrgRuleHitEventsEndpoint = "organizations/{org_id}/events"
+	// OrgRuleHitsEndpoint returns rules hitting clusters in {org_id} with the clusters they hit
+	OrgRuleHitsEndpoint = "organizations/{org_id}/rules"
+	// ClustersHitByRuleEndpoint returns clusters in {org_id} hit by {rule_id} in the rule|error_key format
+	ClustersHitByRuleEndpoint = "organizations/{org_id}/rules/{rule_id}/clusters"
+	// DisableRuleForClustersEndpoint disables a rule for listed or all clusters in {org_id}
+	DisableRuleForClustersEndpoint = "organizations/{org_id}/rules/{rule_id}/disable"
+	// EnableRuleForClustersEndpoint re-enables a rule for listed or all clusters in {org_id}
+	EnableRuleForClustersEndpoint = "organizations/{org_id}/rules/{rule_id}/enable"
+	// RuleAcksForOrgEndpoint returns rules acknowledged for all clusters in {org_id}
+	RuleAcksForOrgEndpoint = "organizations/{org_id}/acks"
+	// RuleAckForOrgEndpoint acknowledges (PUT) or deletes acknowledgement (DELETE) of {rule_id} for all clusters in {org_id}
+	RuleAckForOrgEndpoint = "organizations/{org_id}/users/{user_id}/acks/{rule_id}"
+	// AuditLogForOrgEndpoint returns changes made by users of {org_id}
+	AuditLogForOrgEndpoint = "organizations/{org_id}/audit_log"
 	// LikeRuleEndpoint likes rule with {rule_id} for {cluster} using current user(from auth header)
 	LikeRuleEndpoint = "clusters/{cluster}/rules/{rule_id}/users/{user_id}/like"
 	// DislikeRuleEndpoint dislikes rule with {rule_id} for {cluster} using current user(from auth header)
@@ -59,6 +81,22 @@ const (
 	EnableRuleForClusterEndpoint = "clusters/{cluster}/rules/{rule_id}/enable"
 	// DisableRuleFeedbackEndpoint accepts a feedback from user when (s)he disables a rule
 	DisableRuleFeedbackEndpoint = "clusters/{cluster}/rules/{rule_id}/users/{user_id}/disable_feedback"
+	// RuleFeedbackStatsEndpoint returns votes, disables and feedback messages of all users aggregated
+	// per rule. Internal endpoint for rule authors, admin users only.
+	RuleFeedbackStatsEndpoint = "rules/feedback"
+	// ConsumerErrorsEndpoint lists or deletes messages that the consumer was unable to process. DEBUG only
+	ConsumerErrorsEndpoint = "consumer_errors"
+	// ConsumerErrorEndpoint returns message that the consumer was unable to process
+	// identified by {topic}, {partition} and {offset}. DEBUG only
+	ConsumerErrorEndpoint = "consumer_errors/{topic}/{partition}/{offset}"
+	// EraseOrgDataEndpoint removes all data of {org_id} and its clusters from all tables. Admin only
+	EraseOrgDataEndpoint = "admin/organizations/{org_id}"
+	// EraseClusterDataEndpoint removes all data of {cluster} from all tables. Admin only
+	EraseClusterDataEndpoint = "admin/clusters/{cluster}"
+	// ReloadOrgAllowlistEndpoint reloads organization allowlist from its source. Admin only
+	ReloadOrgAllowlistEndpoint = "admin/org_allowlist/reload"
+	// IngestReportEndpoint processes report pushed directly instead of consuming it from the broker
+	IngestReportEndpoint = "reports"
 	// MetricsEndpoint returns prometheus metrics
 	MetricsEndpoint = "metrics"
 )
@@ -70,6 +108,9 @@ func (server *HTTPServer) addDebugEndpointsToRouter(router *mux.Router) {
 	router.HandleFunc(apiPrefix+DeleteOrganizationsEndpoint, server.deleteOrganizations).Methods(http.MethodDelete)
 	router.HandleFunc(apiPrefix+DeleteClustersEndpoint, server.deleteClusters).Methods(http.MethodDelete)
 	router.HandleFunc(apiPrefix+GetVoteOnRuleEndpoint, server.getVoteOnRule).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ConsumerErrorsEndpoint, server.listConsumerErrors).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ConsumerErrorsEndpoint, server.deleteConsumerErrors).Methods(http.MethodDelete)
+	router.HandleFunc(apiPrefix+ConsumerErrorEndpoint, server.getConsumerError).Methods(http.MethodGet)
 
 	// endpoints for pprof - needed for profiling, ie. usually in debug mode
 	router.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)
@@ -94,9 +135,30 @@ func (server *HTTPServer) addEndpointsToRouter(router *mux.Router) {
 	router.HandleFunc(apiPrefix+ClustersForOrganizationEndpoint, server.listOfClustersForOrganization).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+DisableRuleForClusterEndpoint, server.disableRuleForCluster).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+EnableRuleForClusterEndpoint, server.enableRuleForCluster).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+DisableRuleForClustersEndpoint, server.disableRuleForClusters).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+EnableRuleForClustersEndpoint, server.enableRuleForClusters).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+RuleAcksForOrgEndpoint, server.readRuleAcksForOrg).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+RuleAckForOrgEndpoint, server.ackRuleForOrg).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+RuleAckForOrgEndpoint, server.deleteRuleAckForOrg).Methods(http.MethodDelete)
+	router.HandleFunc(apiPrefix+AuditLogForOrgEndpoint, server.readAuditLogForOrg).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+RuleFeedbackStatsEndpoint, server.readRuleFeedbackStats).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+EraseOrgDataEndpoint, server.eraseOrgData).Methods(http.MethodDelete)
+	router.HandleFunc(apiPrefix+EraseClusterDataEndpoint, server.eraseClusterData).Methods(http.MethodDelete)
+	router.HandleFunc(apiPrefix+ReloadOrgAllowlistEndpoint, server.reloadOrgAllowlist).Methods(http.MethodPost)
 	router.HandleFunc(apiPrefix+DisableRuleFeedbackEndpoint, server.saveDisableFeedback).Methods(http.MethodPost)
 	router.HandleFunc(apiPrefix+ReportForListOfClustersEndpoint, server.reportForListOfClusters).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+ReportForListOfClustersPayloadEndpoint, server.reportForListOfClustersPayload).Methods(http.MethodPost)
+	router.HandleFunc(apiPrefix+ClusterDetailsEndpoint, server.readClusterDetails).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ReportHistoryEndpoint, server.readReportHistoryForCluster).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ClusterRuleHitEventsEndpoint, server.readRuleHitEventsForCluster).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+OrgRuleHitEventsEndpoint, server.readRuleHitEventsForOrg).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+OrgRuleHitsEndpoint, server.readRuleHitsForOrg).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ClustersHitByRuleEndpoint, server.readClustersHitByRule).Methods(http.MethodGet)
+
+	// reports can be pushed directly only when it's enabled
+	if server.Config.EnableIngestion {
+		router.HandleFunc(apiPrefix+IngestReportEndpoint, server.ingestReport).Methods(http.MethodPost)
+	}
 
 	// Prometheus metrics
 	router.Handle(apiPrefix+MetricsEndpoint, promhttp.Handler()).Methods(http.MethodGet)
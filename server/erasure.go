@@ -0,0 +1,105 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/collections"
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// checkAdminPermissions checks that the caller is one of the configured
+// admin users. Everybody is allowed when authentication is disabled, in the
+// same way as for other permission checks.
+func (server *HTTPServer) checkAdminPermissions(writer http.ResponseWriter, request *http.Request) bool {
+	if !server.Config.Auth {
+		return true
+	}
+
+	userID, err := server.GetCurrentUserID(request)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get user id")
+		handleServerError(writer, err)
+		return false
+	}
+
+	if !collections.StringInSlice(string(userID), server.Config.AdminUsers) {
+		log.Warn().Msgf("User %v is not allowed to use admin endpoints", userID)
+		handleServerError(writer, &ForbiddenError{ErrString: "you have no admin permissions"})
+		return false
+	}
+
+	return true
+}
+
+// eraseOrgData removes all data of the organization and its clusters and
+// returns numbers of removed rows per table. Admin only.
+func (server *HTTPServer) eraseOrgData(writer http.ResponseWriter, request *http.Request) {
+	if !server.checkAdminPermissions(writer, request) {
+		// everything has been handled already
+		return
+	}
+
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	result, err := server.auditedStorage(request).EraseOrgData(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to erase organization data")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().Msgf("Data of organization %v erased: %v", orgID, result)
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("deleted", result))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// eraseClusterData removes all data of the cluster and returns numbers of
+// removed rows per table. Admin only.
+func (server *HTTPServer) eraseClusterData(writer http.ResponseWriter, request *http.Request) {
+	if !server.checkAdminPermissions(writer, request) {
+		// everything has been handled already
+		return
+	}
+
+	clusterName, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	result, err := server.auditedStorage(request).EraseClusterData(clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to erase cluster data")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().Msgf("Data of cluster %v erased: %v", clusterName, result)
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("deleted", result))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
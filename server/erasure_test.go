@@ -0,0 +1,150 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/types"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// checkErasedReports returns checker of the erasure response which expects
+// the given number of removed reports
+func checkErasedReports(expected int64) func(t testing.TB, _, got []byte) {
+	return func(t testing.TB, _, got []byte) {
+		var response struct {
+			Deleted storage.ErasureResult `json:"deleted"`
+		}
+		helpers.FailOnError(t, json.Unmarshal(got, &response))
+		assert.Equal(t, expected, response.Deleted["report"])
+		assert.Contains(t, response.Deleted, "consumer_error")
+	}
+}
+
+// makeIdentityToken returns x-rh-identity token of the test user
+func makeIdentityToken(t testing.TB) string {
+	return helpers.MakeXRHTokenString(t, &types.Token{
+		Identity: types.Identity{
+			AccountNumber: testdata.UserID,
+			Internal:      types.Internal{OrgID: testdata.OrgID},
+		},
+	})
+}
+
+func TestHTTPServer_EraseOrgData(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.EraseOrgDataEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode:  http.StatusOK,
+		BodyChecker: checkErasedReports(1),
+	})
+
+	exists, err := mockStorage.DoesClusterExist(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.False(t, exists)
+}
+
+func TestHTTPServer_EraseClusterData(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.EraseClusterDataEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode:  http.StatusOK,
+		BodyChecker: checkErasedReports(1),
+	})
+
+	exists, err := mockStorage.DoesClusterExist(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.False(t, exists)
+}
+
+func TestHTTPServer_EraseClusterData_BadClusterName(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.EraseClusterDataEndpoint,
+		EndpointArgs: []interface{}{testdata.BadClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+func TestHTTPServer_EraseOrgData_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.EraseOrgDataEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+	})
+}
+
+// TestHTTPServer_EraseOrgData_NotAdmin checks that users who are not admins
+// can't erase data even of their own organization.
+func TestHTTPServer_EraseOrgData_NotAdmin(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &helpers.DefaultServerConfigAuth, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.EraseOrgDataEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		XRHIdentity:  makeIdentityToken(t),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status":"you have no admin permissions"}`,
+	})
+}
+
+func TestHTTPServer_EraseClusterData_Admin(t *testing.T) {
+	serverConfig := helpers.DefaultServerConfigAuth
+	serverConfig.AdminUsers = []string{string(testdata.UserID)}
+
+	helpers.AssertAPIRequest(t, nil, &serverConfig, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.EraseClusterDataEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+		XRHIdentity:  makeIdentityToken(t),
+	}, &helpers.APIResponse{
+		StatusCode:  http.StatusOK,
+		BodyChecker: checkErasedReports(0),
+	})
+}
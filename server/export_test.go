@@ -39,4 +39,5 @@ var (
 	SendDBErrorResponse           = sendDBErrorResponse
 	SendMarshallErrorResponse     = sendMarshallErrorResponse
 	FillInGeneratedReports        = fillInGeneratedReports
+	ParseTime                     = parseTime
 )
@@ -0,0 +1,137 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ingestionTopic is used as topic of messages pushed by the ingestion endpoint
+const ingestionTopic = "http"
+
+// defaultMaximumIngestedReportSize is used when the maximum size of pushed
+// message is not configured
+const defaultMaximumIngestedReportSize = 10 * 1024 * 1024
+
+// ReportProcessor processes messages the same way as messages consumed from
+// the broker, it's implemented by consumer.KafkaConsumer
+type ReportProcessor interface {
+	ProcessReport(msg *broker.Message) (types.RequestID, error)
+}
+
+// ingestReport processes report pushed directly in the same message format
+// that is consumed from the broker. The message is checked, filtered by the
+// organization allow list and stored synchronously, so errors found in the
+// message are returned to the client. Conflict is returned when a more recent
+// report of the cluster is stored already. Messages larger than the configured
+// maximum size are rejected.
+func (server *HTTPServer) ingestReport(writer http.ResponseWriter, request *http.Request) {
+	if server.ReportProcessor == nil {
+		log.Error().Msg("Ingestion endpoint is enabled, but no report processor is set")
+		handleServerError(writer, errors.New("report processor is not set"))
+		return
+	}
+
+	maximumSize := server.Config.MaximumIngestedReportSize
+	if maximumSize <= 0 {
+		maximumSize = defaultMaximumIngestedReportSize
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maximumSize))
+	if err != nil && int64(len(body)) >= maximumSize {
+		// the reader fails after the maximum size is read
+		err = responses.Send(http.StatusRequestEntityTooLarge, writer, responses.BuildResponse(
+			fmt.Sprintf("message is larger than %v bytes", maximumSize),
+		))
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	if len(body) == 0 {
+		handleServerError(writer, &NoBodyError{})
+		return
+	}
+
+	var header struct {
+		OrgID *types.OrgID `json:"OrgID"`
+	}
+
+	err = json.Unmarshal(body, &header)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	// message without organization is rejected by the processor
+	if header.OrgID != nil {
+		successful := checkPermissions(writer, request, *header.OrgID, server.Config.Auth)
+		if !successful {
+			// everything has been handled already
+			return
+		}
+	}
+
+	requestID, err := server.ReportProcessor.ProcessReport(&broker.Message{
+		Topic:     ingestionTopic,
+		Value:     body,
+		Timestamp: time.Now(),
+	})
+	if _, ok := err.(*consumer.InvalidMessageError); ok {
+		err = responses.SendBadRequest(writer, err.Error())
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+	if err == types.ErrOldReport {
+		// the message is valid, but its report has not been stored
+		response := responses.BuildResponse(err.Error())
+		response["request_id"] = requestID
+
+		err = responses.Send(http.StatusConflict, writer, response)
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to process ingested report")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("request_id", requestID))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
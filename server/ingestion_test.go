@@ -0,0 +1,238 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	mapset "github.com/deckarep/golang-set"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// newIngestionServer constructs server with enabled ingestion endpoint
+func newIngestionServer(
+	serverConfig server.Configuration, brokerConfig broker.Configuration, mockStorage storage.Storage,
+) *server.HTTPServer {
+	serverConfig.EnableIngestion = true
+
+	testServer := server.New(serverConfig, mockStorage)
+	testServer.ReportProcessor = &consumer.KafkaConsumer{
+		Configuration: brokerConfig,
+		Storage:       mockStorage,
+	}
+
+	return testServer
+}
+
+func TestHTTPServer_IngestReport(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	testServer := newIngestionServer(helpers.DefaultServerConfig, broker.Configuration{}, mockStorage)
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     testdata.ConsumerMessage,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"request_id": "", "status": "ok"}`,
+	})
+
+	_, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+}
+
+// TestHTTPServer_IngestReport_TooLarge checks that messages larger than the
+// configured maximum size are rejected
+func TestHTTPServer_IngestReport_TooLarge(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	serverConfig := helpers.DefaultServerConfig
+	serverConfig.MaximumIngestedReportSize = int64(len(testdata.ConsumerMessage) - 1)
+
+	testServer := newIngestionServer(serverConfig, broker.Configuration{}, mockStorage)
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     testdata.ConsumerMessage,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Body:       fmt.Sprintf(`{"status": "message is larger than %v bytes"}`, serverConfig.MaximumIngestedReportSize),
+	})
+
+	_, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	// message of the maximum size is accepted
+	serverConfig.MaximumIngestedReportSize = int64(len(testdata.ConsumerMessage))
+	testServer = newIngestionServer(serverConfig, broker.Configuration{}, mockStorage)
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     testdata.ConsumerMessage,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+}
+
+// TestHTTPServer_IngestReport_OldReport checks that conflict is returned
+// when a more recent report of the cluster is stored already
+func TestHTTPServer_IngestReport_OldReport(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules,
+		testdata.Report3RulesParsed, testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	testServer := newIngestionServer(helpers.DefaultServerConfig, broker.Configuration{}, mockStorage)
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     testdata.ConsumerMessage,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusConflict,
+		Body:       `{"request_id": "", "status": "More recent report already exists in storage"}`,
+	})
+
+	// the stored report is kept
+	report, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, report, 3)
+}
+
+func TestHTTPServer_IngestReport_Invalid(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	testServer := newIngestionServer(helpers.DefaultServerConfig, broker.Configuration{}, mockStorage)
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     `{"OrgID": 1, "Report": {}}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "message violates schema version 1 at '/Report/fingerprints': Property 'fingerprints' is missing"}`,
+	})
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     "not a JSON",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+
+	// nothing has been stored
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestHTTPServer_IngestReport_OrganizationNotAllowed(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	testServer := newIngestionServer(helpers.DefaultServerConfig, broker.Configuration{
+		OrgAllowlist:        mapset.NewSetWith(types.OrgID(2)),
+		OrgAllowlistEnabled: true,
+	}, mockStorage)
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     testdata.ConsumerMessage,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "organization ID is not in allow list"}`,
+	})
+}
+
+// TestHTTPServer_IngestReport_OtherOrganization checks that users can't push
+// reports of other organizations
+func TestHTTPServer_IngestReport_OtherOrganization(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	testServer := newIngestionServer(helpers.DefaultServerConfigAuth, broker.Configuration{}, mockStorage)
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     `{"OrgID": 2, "ClusterName": "` + string(testdata.ClusterName) + `"}`,
+		XRHIdentity: helpers.MakeXRHTokenString(t, &types.Token{
+			Identity: types.Identity{
+				AccountNumber: testdata.UserID,
+				Internal:      types.Internal{OrgID: testdata.OrgID},
+			},
+		}),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+	})
+}
+
+func TestHTTPServer_IngestReport_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	testServer := newIngestionServer(helpers.DefaultServerConfig, broker.Configuration{}, mockStorage)
+
+	helpers.AssertAPIRequestWithServer(t, testServer, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     testdata.ConsumerMessage,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+// TestHTTPServer_IngestReport_Disabled checks that the endpoint is not
+// available unless it's enabled in the configuration
+func TestHTTPServer_IngestReport_Disabled(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.IngestReportEndpoint,
+		Body:     testdata.ConsumerMessage,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
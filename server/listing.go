@@ -0,0 +1,160 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	sortOrderAsc  = "asc"
+	sortOrderDesc = "desc"
+)
+
+// listMeta contains pagination metadata of a list response
+type listMeta struct {
+	// Total is the number of all items matching the filter
+	Total  int    `json:"total"`
+	Limit  uint64 `json:"limit"`
+	Offset uint64 `json:"offset"`
+}
+
+// buildListResponse builds response with the part of a list and its
+// pagination metadata
+func buildListResponse(
+	dataName string, data interface{}, total int, pagination storage.ListPagination,
+) map[string]interface{} {
+	return map[string]interface{}{
+		"status": "ok",
+		dataName: data,
+		"meta": listMeta{
+			Total:  total,
+			Limit:  pagination.Limit,
+			Offset: pagination.Offset,
+		},
+	}
+}
+
+// readClusterListFilter retrieves filter for cluster listing from request's
+// query, all the parameters are optional. Only the time range of the last
+// check is read, see listOfClustersForOrganization for the time range of
+// receiving the report.
+// if it's not possible to parse them, it writes http error to the writer and returns false
+func readClusterListFilter(writer http.ResponseWriter, request *http.Request) (storage.ClusterListFilter, bool) {
+	var (
+		filter     storage.ClusterListFilter
+		successful bool
+	)
+
+	filter.LastCheckedFrom, successful = readTimeQueryParam(writer, request, "last_checked_from")
+	if !successful {
+		return filter, false
+	}
+
+	filter.LastCheckedTo, successful = readTimeQueryParam(writer, request, "last_checked_to")
+	if !successful {
+		return filter, false
+	}
+
+	if value := request.URL.Query().Get("has_hits"); value != "" {
+		hasHits, err := strconv.ParseBool(value)
+		if err != nil {
+			handleServerError(writer, &RouterParsingError{
+				ParamName:  "has_hits",
+				ParamValue: value,
+				ErrString:  "boolean expected",
+			})
+			return filter, false
+		}
+		filter.HasHits = &hasHits
+	}
+
+	filter.RuleID = types.RuleID(request.URL.Query().Get("rule_id"))
+	filter.ErrorKey = types.ErrorKey(request.URL.Query().Get("error_key"))
+
+	if filter.ErrorKey != "" && filter.RuleID == "" {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  "error_key",
+			ParamValue: string(filter.ErrorKey),
+			ErrString:  "rule_id must be provided together with error key",
+		})
+		return filter, false
+	}
+
+	filter.OCPVersion = request.URL.Query().Get("ocp_version")
+	filter.Platform = request.URL.Query().Get("platform")
+
+	return filter, true
+}
+
+// readListPagination retrieves pagination parameters from request's query,
+// sort_by must be one of the given columns. All the parameters are optional,
+// the whole list is returned when limit is not provided.
+// if it's not possible to parse them, it writes http error to the writer and returns false
+func readListPagination(
+	writer http.ResponseWriter, request *http.Request, sortColumns ...string,
+) (storage.ListPagination, bool) {
+	var (
+		pagination storage.ListPagination
+		successful bool
+	)
+
+	pagination.Limit, successful = readUintQueryParam(writer, request, "limit", 0)
+	if !successful {
+		return pagination, false
+	}
+
+	pagination.Offset, successful = readUintQueryParam(writer, request, "offset", 0)
+	if !successful {
+		return pagination, false
+	}
+
+	if sortBy := request.URL.Query().Get("sort_by"); sortBy != "" {
+		for _, column := range sortColumns {
+			if sortBy == column {
+				pagination.SortBy = sortBy
+			}
+		}
+
+		if pagination.SortBy == "" {
+			handleServerError(writer, &RouterParsingError{
+				ParamName:  "sort_by",
+				ParamValue: sortBy,
+				ErrString:  "one of " + strings.Join(sortColumns, ", ") + " expected",
+			})
+			return pagination, false
+		}
+	}
+
+	switch sortOrder := request.URL.Query().Get("sort_order"); sortOrder {
+	case "", sortOrderAsc:
+	case sortOrderDesc:
+		pagination.Descending = true
+	default:
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  "sort_order",
+			ParamValue: sortOrder,
+			ErrString:  sortOrderAsc + " or " + sortOrderDesc + " expected",
+		})
+		return pagination, false
+	}
+
+	return pagination, true
+}
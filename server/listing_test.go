@@ -0,0 +1,153 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	firstListedCluster  = types.ClusterName("11111111-1111-1111-1111-111111111111")
+	secondListedCluster = types.ClusterName("22222222-2222-2222-2222-222222222222")
+)
+
+func TestListOfClustersForOrganizationPagination(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	for _, clusterName := range []types.ClusterName{firstListedCluster, secondListedCluster} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, clusterName, testdata.Report3Rules,
+			testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?sort_order=desc&limit=1&has_hits=true",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(secondListedCluster) + `"],
+			"meta": {"total": 2, "limit": 1, "offset": 0},
+			"status": "ok"
+		}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?sort_by=last_checked_at&offset=1&rule_id=" + string(testdata.Rule1ID),
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(secondListedCluster) + `"],
+			"meta": {"total": 2, "limit": 0, "offset": 1},
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestListOfOrganizationsPagination(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	for _, orgID := range []types.OrgID{1, 5} {
+		err := mockStorage.WriteReportForCluster(
+			orgID, testdata.GetRandomClusterID(), testdata.Report3Rules,
+			testdata.ReportEmptyRulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.OrganizationsEndpoint + "?sort_by=org_id&sort_order=desc&limit=1&offset=1",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"organizations":[1],"meta":{"total":2,"limit":1,"offset":1},"status":"ok"}`,
+	})
+}
+
+func TestListOfClustersForOrganizationBadListParams(t *testing.T) {
+	for _, testCase := range []struct {
+		query   string
+		message string
+	}{
+		{"limit=x", "Error during parsing param 'limit' with value 'x'. Error: 'unsigned integer expected'"},
+		{"offset=-1", "Error during parsing param 'offset' with value '-1'. Error: 'unsigned integer expected'"},
+		{"limit=9223372036854775808", "Error during parsing param 'limit' with value '9223372036854775808'. Error: 'value up to 9223372036854775807 expected'"},
+		{"offset=18446744073709551615", "Error during parsing param 'offset' with value '18446744073709551615'. Error: 'value up to 9223372036854775807 expected'"},
+		{"sort_by=org_id", "Error during parsing param 'sort_by' with value 'org_id'. Error: 'one of cluster, last_checked_at expected'"},
+		{"sort_order=up", "Error during parsing param 'sort_order' with value 'up'. Error: 'asc or desc expected'"},
+		{"has_hits=maybe", "Error during parsing param 'has_hits' with value 'maybe'. Error: 'boolean expected'"},
+		{"last_checked_from=yesterday", "Error during parsing param 'last_checked_from' with value 'yesterday'. Error: 'timestamp in RFC 3339 format or relative time like 7d expected'"},
+		{"error_key=ek1", "Error during parsing param 'error_key' with value 'ek1'. Error: 'rule_id must be provided together with error key'"},
+	} {
+		t.Run(testCase.query, func(t *testing.T) {
+			helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+				Method:       http.MethodGet,
+				Endpoint:     server.ClustersForOrganizationEndpoint + "?" + testCase.query,
+				EndpointArgs: []interface{}{testdata.OrgID},
+			}, &helpers.APIResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       `{"status": "` + testCase.message + `"}`,
+			})
+		})
+	}
+}
+
+func TestListOfClustersForOrganizationTimeRange(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// reported_at is set to the time of writing
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, firstListedCluster, testdata.Report3Rules,
+		testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?since=1h",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(firstListedCluster) + `"],
+			"meta": {"total": 1, "limit": 0, "offset": 0},
+			"status": "ok"
+		}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?since=2w&until=1h",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"clusters": [], "meta": {"total": 0, "limit": 0, "offset": 0}, "status": "ok"}`,
+	})
+}
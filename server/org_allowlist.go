@@ -0,0 +1,60 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// OrgAllowlistReloader reloads the organization allowlist from its source
+// and returns the number of allowed organizations
+type OrgAllowlistReloader interface {
+	ReloadOrgAllowlist() (int, error)
+}
+
+// reloadOrgAllowlist reloads the organization allowlist used by the consumer
+// and by the ingestion endpoint, so newly allowed organizations are accepted
+// without restart of the service. Admin only.
+func (server *HTTPServer) reloadOrgAllowlist(writer http.ResponseWriter, request *http.Request) {
+	if !server.checkAdminPermissions(writer, request) {
+		// everything has been handled already
+		return
+	}
+
+	if server.OrgAllowlistReloader == nil {
+		err := responses.SendBadRequest(writer, "organization allowlist is not enabled")
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+
+	count, err := server.OrgAllowlistReloader.ReloadOrgAllowlist()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to reload organization allowlist")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().Msgf("Organization allowlist reloaded by admin, %d organization(s) allowed", count)
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("organizations", count))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
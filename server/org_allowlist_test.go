@@ -0,0 +1,101 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// mockOrgAllowlistReloader counts reloads of the allowlist
+type mockOrgAllowlistReloader struct {
+	reloads       int
+	organizations int
+	err           error
+}
+
+// ReloadOrgAllowlist returns the configured number of organizations or error
+func (reloader *mockOrgAllowlistReloader) ReloadOrgAllowlist() (int, error) {
+	reloader.reloads++
+	return reloader.organizations, reloader.err
+}
+
+// newAllowlistServer constructs server with the given allowlist reloader
+func newAllowlistServer(
+	serverConfig server.Configuration, reloader server.OrgAllowlistReloader,
+) *server.HTTPServer {
+	testServer := server.New(serverConfig, nil)
+	testServer.OrgAllowlistReloader = reloader
+
+	return testServer
+}
+
+func TestHTTPServer_ReloadOrgAllowlist(t *testing.T) {
+	reloader := &mockOrgAllowlistReloader{organizations: 3}
+
+	helpers.AssertAPIRequestWithServer(t, newAllowlistServer(helpers.DefaultServerConfig, reloader), &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.ReloadOrgAllowlistEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"organizations": 3, "status": "ok"}`,
+	})
+
+	assert.Equal(t, 1, reloader.reloads)
+}
+
+func TestHTTPServer_ReloadOrgAllowlist_Disabled(t *testing.T) {
+	helpers.AssertAPIRequestWithServer(t, newAllowlistServer(helpers.DefaultServerConfig, nil), &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.ReloadOrgAllowlistEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "organization allowlist is not enabled"}`,
+	})
+}
+
+func TestHTTPServer_ReloadOrgAllowlist_Error(t *testing.T) {
+	reloader := &mockOrgAllowlistReloader{err: errors.New("allowlist CSV could not be read")}
+
+	helpers.AssertAPIRequestWithServer(t, newAllowlistServer(helpers.DefaultServerConfig, reloader), &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.ReloadOrgAllowlistEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+	})
+}
+
+// TestHTTPServer_ReloadOrgAllowlist_NotAdmin checks that users who are not
+// admins can't reload the allowlist
+func TestHTTPServer_ReloadOrgAllowlist_NotAdmin(t *testing.T) {
+	reloader := &mockOrgAllowlistReloader{}
+
+	helpers.AssertAPIRequestWithServer(t, newAllowlistServer(helpers.DefaultServerConfigAuth, reloader), &helpers.APIRequest{
+		Method:      http.MethodPost,
+		Endpoint:    server.ReloadOrgAllowlistEndpoint,
+		XRHIdentity: makeIdentityToken(t),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status":"you have no admin permissions"}`,
+	})
+
+	assert.Equal(t, 0, reloader.reloads)
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// readRuleHitsForOrg returns rules hitting clusters in selected organization
+// together with the number and the list of clusters hit by each rule
+func (server *HTTPServer) readRuleHitsForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	ruleHits, err := server.Storage.ReadRuleHitsForOrg(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule hits for organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("rules", ruleHits))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// readClustersHitByRule returns clusters in selected organization hit by the
+// rule with error key specified in the rule|error_key format
+func (server *HTTPServer) readClustersHitByRule(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	ruleID, errorKey, successful := readRuleIDWithErrorKey(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	clusters, err := server.Storage.ReadClustersHitByRule(orgID, ruleID, errorKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read clusters hit by rule")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("clusters", clusters))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
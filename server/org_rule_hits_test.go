@@ -0,0 +1,141 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+func TestHTTPServer_OrgRuleHitsEndpoint_Empty(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrgRuleHitsEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"rules":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_OrgRuleHitsEndpoint(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.ToggleRuleForCluster(testdata.ClusterName, testdata.Rule1ID, storage.RuleToggleDisable)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrgRuleHitsEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"rules": [{
+				"rule_fqdn": "` + string(testdata.Rule2ID) + `",
+				"error_key": "` + string(testdata.ErrorKey2) + `",
+				"clusters_count": 1,
+				"clusters": ["` + string(testdata.ClusterName) + `"]
+			}],
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestHTTPServer_OrgRuleHitsEndpoint_BadOrgID(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrgRuleHitsEndpoint,
+		EndpointArgs: []interface{}{"not-a-number"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'org_id' with value 'not-a-number'. Error: 'unsigned integer expected'"
+		}`,
+	})
+}
+
+func TestHTTPServer_ClustersHitByRuleEndpoint(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersHitByRuleEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, fmt.Sprintf("%v|%v", testdata.Rule2ID, testdata.ErrorKey2)},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": [{
+				"cluster": "` + string(testdata.ClusterName) + `",
+				"last_checked_at": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `"
+			}],
+			"status": "ok"
+		}`,
+	})
+
+	// the rule is not hit with another error key
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersHitByRuleEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, fmt.Sprintf("%v|%v", testdata.Rule2ID, testdata.ErrorKey1)},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"clusters":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_ClustersHitByRuleEndpoint_BadRuleID(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersHitByRuleEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule2ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'rule_id' with value '` + string(testdata.Rule2ID) + `'. ` +
+			`Error: 'invalid rule ID, it must contain only rule ID and error key separated by |'"
+		}`,
+	})
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// checkReportHistoryLength returns body checker that checks only the number of
+// reports in history, because reported_at timestamp is not known in advance
+func checkReportHistoryLength(expected int) func(t testing.TB, _, got []byte) {
+	return func(t testing.TB, _, got []byte) {
+		var response struct {
+			Status  string                      `json:"status"`
+			History []storage.ReportHistoryItem `json:"history"`
+		}
+
+		helpers.FailOnError(t, json.Unmarshal(got, &response))
+		assert.Equal(t, "ok", response.Status)
+		assert.Len(t, response.History, expected)
+	}
+}
+
+func TestHTTPServer_ReportHistoryEndpoint_Empty(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"history":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_ReportHistoryEndpoint(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastCheckedAt := time.Date(2020, time.January, 23, 16, 15, 59, 0, time.UTC)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.ReportEmptyRulesParsed,
+		lastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode:  http.StatusOK,
+		BodyChecker: checkReportHistoryLength(1),
+	})
+
+	// the report was checked before the requested time
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint + "?since=2020-01-24T00:00:00Z",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"history":[],"status":"ok"}`,
+	})
+
+	// the report was checked after the requested time
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint + "?since=2020-01-20T00:00:00Z&until=2020-01-23T00:00:00Z",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"history":[],"status":"ok"}`,
+	})
+
+	// relative time is measured from now
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint + "?since=1d",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"history":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_ReportHistoryEndpoint_BadTimeRange(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint + "?since=1d&until=2d",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'until' with value '2d'. Error: 'time after since expected'"
+		}`,
+	})
+}
+
+func TestHTTPServer_ReportHistoryEndpoint_BadSince(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint + "?since=yesterday",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'since' with value 'yesterday'. Error: 'timestamp in RFC 3339 format or relative time like 7d expected'"
+		}`,
+	})
+}
+
+func TestHTTPServer_ReportHistoryEndpoint_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
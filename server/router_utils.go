@@ -18,11 +18,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	httputils "github.com/RedHatInsights/insights-operator-utils/http"
+	"github.com/RedHatInsights/insights-operator-utils/responses"
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -78,6 +83,152 @@ func readOrgID(writer http.ResponseWriter, request *http.Request) (types.OrgID,
 	return types.OrgID(orgID), true
 }
 
+// relativeTimeUnits contains durations of units that can be used in
+// relative time in addition to the units supported by time.ParseDuration
+var relativeTimeUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// relativeTimeRegex matches relative time in days or weeks, like 7d or 2w
+var relativeTimeRegex = regexp.MustCompile(`^([0-9]+)([dw])$`)
+
+// parseTime parses timestamp in RFC 3339 (ISO 8601) format or relative time
+// in the past, like 7d, 2w, 12h or 1h30m, measured from now
+func parseTime(value string, now time.Time) (time.Time, error) {
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return timestamp, nil
+	}
+
+	var duration time.Duration
+
+	if match := relativeTimeRegex.FindStringSubmatch(value); match != nil {
+		count, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		duration = time.Duration(count) * relativeTimeUnits[match[2]]
+	} else {
+		duration, err = time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if duration < 0 {
+		return time.Time{}, errors.New("negative relative time")
+	}
+
+	return now.Add(-duration), nil
+}
+
+// readTimeQueryParam retrieves optional timestamp in RFC 3339 format or
+// relative time in the past (like 7d, 2w or 12h) from request's query, zero
+// time is returned when the parameter is not provided
+// if it's not possible to parse it, it writes http error to the writer and returns false
+func readTimeQueryParam(writer http.ResponseWriter, request *http.Request, paramName string) (time.Time, bool) {
+	value := request.URL.Query().Get(paramName)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	timestamp, err := parseTime(value, time.Now())
+	if err != nil {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  paramName,
+			ParamValue: value,
+			ErrString:  "timestamp in RFC 3339 format or relative time like 7d expected",
+		})
+		return time.Time{}, false
+	}
+
+	return timestamp, true
+}
+
+// readDisabledUntilQueryParam retrieves optional `until` timestamp in RFC 3339
+// format from request's query, zero time is returned when the parameter is not
+// provided
+// if it's not possible to parse it or it's not in the future, it writes http
+// error to the writer and returns false
+func readDisabledUntilQueryParam(writer http.ResponseWriter, request *http.Request) (time.Time, bool) {
+	const paramName = "until"
+
+	value := request.URL.Query().Get(paramName)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil || !until.After(time.Now()) {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  paramName,
+			ParamValue: value,
+			ErrString:  "timestamp in the future in RFC 3339 format expected",
+		})
+		return time.Time{}, false
+	}
+
+	return until, true
+}
+
+// readTimeRangeQueryParams retrieves optional `since` and `until` timestamps
+// from request's query, see readTimeQueryParam for supported formats
+// if it's not possible to parse them or since is not before until, it writes
+// http error to the writer and returns false
+func readTimeRangeQueryParams(writer http.ResponseWriter, request *http.Request) (since, until time.Time, successful bool) {
+	since, successful = readTimeQueryParam(writer, request, "since")
+	if !successful {
+		return
+	}
+
+	until, successful = readTimeQueryParam(writer, request, "until")
+	if !successful {
+		return
+	}
+
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  "until",
+			ParamValue: request.URL.Query().Get("until"),
+			ErrString:  "time after since expected",
+		})
+		return since, until, false
+	}
+
+	return since, until, true
+}
+
+// readUintQueryParam retrieves optional unsigned integer from request's query,
+// defaultValue is returned when the parameter is not provided
+// if it's not possible to parse it, it writes http error to the writer and returns false
+// values are passed to the database as signed integers, so they can't exceed MaxInt64
+func readUintQueryParam(
+	writer http.ResponseWriter, request *http.Request, paramName string, defaultValue uint64,
+) (uint64, bool) {
+	value := request.URL.Query().Get(paramName)
+	if value == "" {
+		return defaultValue, true
+	}
+
+	number, err := strconv.ParseUint(value, 10, 63)
+	if err != nil {
+		errString := "unsigned integer expected"
+		if errors.Is(err, strconv.ErrRange) {
+			errString = fmt.Sprintf("value up to %d expected", math.MaxInt64)
+		}
+
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  paramName,
+			ParamValue: value,
+			ErrString:  errString,
+		})
+		return 0, false
+	}
+
+	return number, true
+}
+
 // readClusterListFromPath retrieves list of clusters from request's path
 // if it's not possible, it writes http error to the writer and returns false
 func readClusterListFromPath(writer http.ResponseWriter, request *http.Request) ([]string, bool) {
@@ -123,6 +274,67 @@ func readClusterListFromBody(writer http.ResponseWriter, request *http.Request)
 	return clusterList.Clusters, true
 }
 
+// clusterSelection is the request's body selecting clusters of the
+// organization, either the listed ones or all of them
+type clusterSelection struct {
+	Clusters    []string `json:"clusters"`
+	AllClusters bool     `json:"all_clusters"`
+}
+
+// readClusterSelectionFromBody retrieves validated list of clusters from
+// request's body, or whether all clusters of the organization are selected
+// by `all_clusters` flag. Exactly one of them is expected, empty list of
+// clusters is rejected. If it's not possible, it writes http error to the
+// writer and returns false
+func readClusterSelectionFromBody(
+	writer http.ResponseWriter, request *http.Request,
+) (clusters []types.ClusterName, allClusters bool, successful bool) {
+	// the body is read whole, chunked requests have no content length
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		handleServerError(writer, err)
+		return nil, false, false
+	}
+
+	if len(body) == 0 {
+		handleServerError(writer, &NoBodyError{})
+		return nil, false, false
+	}
+
+	var selection clusterSelection
+
+	err = json.Unmarshal(body, &selection)
+	if err != nil {
+		handleServerError(writer, err)
+		return nil, false, false
+	}
+
+	if selection.AllClusters == (len(selection.Clusters) > 0) {
+		err = responses.SendBadRequest(writer, "either non-empty list of clusters or all_clusters flag is expected")
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return nil, false, false
+	}
+
+	if selection.AllClusters {
+		return nil, true, true
+	}
+
+	clusters = make([]types.ClusterName, 0, len(selection.Clusters))
+	for _, cluster := range selection.Clusters {
+		clusterName, err := validateClusterName(cluster)
+		if err != nil {
+			handleServerError(writer, err)
+			return nil, false, false
+		}
+
+		clusters = append(clusters, clusterName)
+	}
+
+	return clusters, false, true
+}
+
 func readRuleIDWithErrorKey(writer http.ResponseWriter, request *http.Request) (types.RuleID, types.ErrorKey, bool) {
 	ruleIDWithErrorKey, err := getRouterParam(request, "rule_id")
 	if err != nil {
@@ -22,6 +22,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
 
@@ -270,3 +271,25 @@ func TestReadClusterListFromBodyWrongJSON(t *testing.T) {
 	// the read should fail because of broken JSON
 	assert.False(t, successful)
 }
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2021, time.February, 10, 12, 0, 0, 0, time.UTC)
+
+	for value, expected := range map[string]time.Time{
+		"2021-01-23T16:15:59Z":      time.Date(2021, time.January, 23, 16, 15, 59, 0, time.UTC),
+		"2021-01-23T16:15:59+01:00": time.Date(2021, time.January, 23, 15, 15, 59, 0, time.UTC),
+		"7d":                        now.Add(-7 * 24 * time.Hour),
+		"2w":                        now.Add(-14 * 24 * time.Hour),
+		"12h":                       now.Add(-12 * time.Hour),
+		"1h30m":                     now.Add(-90 * time.Minute),
+	} {
+		timestamp, err := server.ParseTime(value, now)
+		helpers.FailOnError(t, err)
+		assert.True(t, expected.Equal(timestamp), value)
+	}
+
+	for _, value := range []string{"yesterday", "7", "-1h", "1y", "2021-01-23"} {
+		_, err := server.ParseTime(value, now)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// readRuleAcksForOrg returns rules acknowledged for the whole organization
+func (server *HTTPServer) readRuleAcksForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	acks, err := server.Storage.ReadRuleAcksForOrg(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule acknowledgements for organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("acks", acks))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// ackRuleForOrg acknowledges rule for the whole organization, so it's
+// treated as disabled for all current and future clusters of the organization
+func (server *HTTPServer) ackRuleForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	userID, successful := readUserID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	ruleID, successful := readRuleID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	justification, err := server.getAckJustificationFromBody(request)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	err = server.auditedStorage(request).AckRuleForOrg(orgID, ruleID, userID, justification)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to acknowledge rule for organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	ack, err := server.Storage.GetRuleAckForOrg(orgID, ruleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule acknowledgement")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("ack", ack))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// deleteRuleAckForOrg deletes acknowledgement of the rule for the organization
+func (server *HTTPServer) deleteRuleAckForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	ruleID, successful := readRuleID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	err := server.auditedStorage(request).DeleteRuleAckForOrg(orgID, ruleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to delete rule acknowledgement")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getAckJustificationFromBody retrieves justification of the acknowledgement
+// from body of the request, empty justification is used when there's no body
+func (server *HTTPServer) getAckJustificationFromBody(request *http.Request) (string, error) {
+	var ackRequest types.RuleAckRequest
+
+	err := json.NewDecoder(request.Body).Decode(&ackRequest)
+	if err == io.EOF {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(ackRequest.Justification) > server.Config.MaximumFeedbackMessageLength {
+		return "", &types.ValidationError{
+			ParamName:  "justification",
+			ParamValue: ackRequest.Justification[0:server.Config.MaximumFeedbackMessageLength] + "...",
+			ErrString: fmt.Sprintf(
+				"justification is longer than %v bytes", server.Config.MaximumFeedbackMessageLength,
+			),
+		}
+	}
+
+	return ackRequest.Justification, nil
+}
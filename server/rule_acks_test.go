@@ -0,0 +1,224 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	operator_utils_types "github.com/RedHatInsights/insights-operator-utils/types"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+func TestHTTPServer_RuleAcksForOrg_Empty(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleAcksForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"acks":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_RuleAckForOrg_CreateListDelete(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.RuleAckForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID, testdata.Rule1ID},
+		Body:         `{"justification": "not relevant for our fleet"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Ack storage.RuleAck `json:"ack"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Equal(t, testdata.OrgID, response.Ack.OrgID)
+			assert.Equal(t, testdata.Rule1ID, response.Ack.RuleID)
+			assert.Equal(t, testdata.UserID, response.Ack.CreatedBy)
+			assert.Equal(t, "not relevant for our fleet", response.Ack.Justification)
+		},
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleAcksForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Acks []storage.RuleAck `json:"acks"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Len(t, response.Acks, 1)
+			assert.Equal(t, testdata.Rule1ID, response.Acks[0].RuleID)
+		},
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.RuleAckForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID, testdata.Rule1ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status":"ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.RuleAckForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID, testdata.Rule1ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       fmt.Sprintf(`{"status":"Item with ID %v was not found in the storage"}`, testdata.Rule1ID),
+	})
+}
+
+func TestHTTPServer_RuleAckForOrg_TooLongJustification(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.RuleAckForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID, testdata.Rule1ID},
+		Body:         `{"justification": "` + strings.Repeat("a", 300) + `"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{"status":"Error during validating param 'justification' with value '` +
+			strings.Repeat("a", 255) + `...'. Error: 'justification is longer than 255 bytes'"}`,
+	})
+}
+
+func TestHTTPServer_RuleAcksForOrg_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleAcksForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status":"Internal Server Error"}`,
+	})
+}
+
+// TestReadReport_RuleAckedForOrg checks that a rule acknowledged for the
+// organization is disabled in report of a cluster with the justification
+// used as disable feedback
+func TestReadReport_RuleAckedForOrg(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, "test")
+	helpers.FailOnError(t, err)
+
+	// report is written after the acknowledgement, like for a new cluster
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       testdata.Report2RulesDisabledRule1WithFeedbackExpectedResponse,
+		BodyChecker: func(t testing.TB, expected, got []byte) {
+			helpers.AssertReportResponsesEqualCustomElementsChecker(
+				t, expected, got,
+				func(
+					t testing.TB,
+					expectedRules []operator_utils_types.RuleOnReport,
+					gotRules []operator_utils_types.RuleOnReport,
+				) {
+					assert.ElementsMatch(t, disabledStatus(expectedRules), disabledStatus(gotRules))
+				},
+			)
+		},
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID, fmt.Sprintf("%v|%v", testdata.Rule1ID, testdata.ErrorKey1)},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Report operator_utils_types.RuleOnReport `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.True(t, response.Report.Disabled)
+		},
+	})
+}
+
+// TestHTTPServer_RuleAckForOrg_SingleRuleDBError checks that the error of
+// reading the acknowledgement isn't treated as a not acknowledged rule
+func TestHTTPServer_RuleAckForOrg_SingleRuleDBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	_, err = mockStorage.(*storage.DBStorage).GetConnection().Exec("DROP TABLE rule_ack")
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID, fmt.Sprintf("%v|%v", testdata.Rule1ID, testdata.ErrorKey1)},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+// disabledStatus returns rule modules with their disabled status and disable feedback
+func disabledStatus(rules []operator_utils_types.RuleOnReport) []string {
+	result := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, fmt.Sprintf("%v %v %v", rule.Module, rule.Disabled, rule.DisableFeedback))
+	}
+
+	return result
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+const (
+	// defaultFeedbackMessagesLimit is the number of the most recent messages
+	// returned for each rule when `messages` query parameter is not provided
+	defaultFeedbackMessagesLimit = 10
+
+	// formatJSON and formatCSV are accepted values of `format` query parameter
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// readRuleFeedbackStats returns likes, dislikes, disables and the most
+// recent feedback messages for all rules. The time window is limited by
+// `since` and `until` query parameters, `messages` query parameter limits
+// the number of messages per rule and `format` selects JSON (default) or
+// CSV output. Admin only, feedback of all organizations is returned.
+func (server *HTTPServer) readRuleFeedbackStats(writer http.ResponseWriter, request *http.Request) {
+	if !server.checkAdminPermissions(writer, request) {
+		// everything has been handled already
+		return
+	}
+
+	since, until, successful := readTimeRangeQueryParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	messagesLimit, successful := readUintQueryParam(writer, request, "messages", defaultFeedbackMessagesLimit)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	format := request.URL.Query().Get("format")
+	if format != "" && format != formatJSON && format != formatCSV {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  "format",
+			ParamValue: format,
+			ErrString:  "json or csv expected",
+		})
+		return
+	}
+
+	stats, err := server.Storage.GetRuleFeedbackStats(since, until, int(messagesLimit))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule feedback stats")
+		handleServerError(writer, err)
+		return
+	}
+
+	if format == formatCSV {
+		err = sendRuleFeedbackStatsCSV(writer, stats)
+	} else {
+		err = responses.SendOK(writer, responses.BuildOkResponseWithData("rules", stats))
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// sendRuleFeedbackStatsCSV writes rule feedback stats in CSV format, one
+// rule per line. Messages of each kind are separated by new lines.
+func sendRuleFeedbackStatsCSV(writer http.ResponseWriter, stats []storage.RuleFeedbackStats) error {
+	writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+
+	csvWriter := csv.NewWriter(writer)
+
+	err := csvWriter.Write([]string{"rule_id", "likes", "dislikes", "disables", "feedback", "disable_feedback"})
+	if err != nil {
+		return err
+	}
+
+	for i := range stats {
+		ruleStats := &stats[i]
+
+		err = csvWriter.Write([]string{
+			escapeCSVFormula(string(ruleStats.RuleID)),
+			strconv.Itoa(ruleStats.Likes),
+			strconv.Itoa(ruleStats.Dislikes),
+			strconv.Itoa(ruleStats.Disables),
+			escapeCSVFormula(joinFeedbackMessages(ruleStats.Feedback)),
+			escapeCSVFormula(joinFeedbackMessages(ruleStats.DisableFeedback)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+
+	return csvWriter.Error()
+}
+
+// escapeCSVFormula prefixes the cell that would be interpreted as a formula
+// by spreadsheet applications with an apostrophe, so user feedback is always
+// shown as text
+func escapeCSVFormula(cell string) string {
+	if cell != "" && strings.ContainsAny(cell[:1], "=+-@") {
+		return "'" + cell
+	}
+
+	return cell
+}
+
+// joinFeedbackMessages joins texts of the messages by new lines
+func joinFeedbackMessages(messages []storage.RuleFeedbackMessage) string {
+	texts := make([]string, 0, len(messages))
+	for _, message := range messages {
+		texts = append(texts, message.Message)
+	}
+
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func mustWriteVotesForFeedbackStats(t *testing.T, mockStorage storage.Storage) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteDislike, "too noisy, \"really\"")
+	helpers.FailOnError(t, err)
+}
+
+func TestHTTPServer_RuleFeedbackStats_Empty(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleFeedbackStatsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"rules":[],"status":"ok"}`,
+	})
+}
+
+func TestHTTPServer_RuleFeedbackStats_JSON(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteVotesForFeedbackStats(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleFeedbackStatsEndpoint + "?format=json&since=7d",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Rules []storage.RuleFeedbackStats `json:"rules"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Len(t, response.Rules, 1)
+			assert.Equal(t, testdata.Rule1ID, response.Rules[0].RuleID)
+			assert.Equal(t, 1, response.Rules[0].Dislikes)
+			assert.Len(t, response.Rules[0].Feedback, 1)
+		},
+	})
+}
+
+func TestHTTPServer_RuleFeedbackStats_CSV(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteVotesForFeedbackStats(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleFeedbackStatsEndpoint + "?format=csv",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			assert.Equal(
+				t,
+				"rule_id,likes,dislikes,disables,feedback,disable_feedback\n"+
+					string(testdata.Rule1ID)+`,0,1,0,"too noisy, ""really""",`+"\n",
+				string(got),
+			)
+		},
+	})
+}
+
+// TestHTTPServer_RuleFeedbackStats_CSVFormula checks that feedback looking
+// like a spreadsheet formula is exported as text
+func TestHTTPServer_RuleFeedbackStats_CSVFormula(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteVotesForFeedbackStats(t, mockStorage)
+
+	err := mockStorage.AddOrUpdateFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, `=HYPERLINK("http://example.com")`)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.AddFeedbackOnRuleDisable(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, "@SUM(1+1)")
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleFeedbackStatsEndpoint + "?format=csv",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			assert.Equal(
+				t,
+				"rule_id,likes,dislikes,disables,feedback,disable_feedback\n"+
+					string(testdata.Rule1ID)+`,0,1,0,"'=HYPERLINK(""http://example.com"")",'@SUM(1+1)`+"\n",
+				string(got),
+			)
+		},
+	})
+}
+
+func TestHTTPServer_RuleFeedbackStats_BadFormat(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleFeedbackStatsEndpoint + "?format=xml",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status":"Error during parsing param 'format' with value 'xml'. Error: 'json or csv expected'"}`,
+	})
+}
+
+// TestHTTPServer_RuleFeedbackStats_NotAdmin checks that feedback of all
+// organizations is not returned to users who are not admins.
+func TestHTTPServer_RuleFeedbackStats_NotAdmin(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &helpers.DefaultServerConfigAuth, &helpers.APIRequest{
+		Method:      http.MethodGet,
+		Endpoint:    server.RuleFeedbackStatsEndpoint,
+		XRHIdentity: makeIdentityToken(t),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status":"you have no admin permissions"}`,
+	})
+}
+
+func TestHTTPServer_RuleFeedbackStats_Admin(t *testing.T) {
+	serverConfig := helpers.DefaultServerConfigAuth
+	serverConfig.AdminUsers = []string{string(testdata.UserID)}
+
+	helpers.AssertAPIRequest(t, nil, &serverConfig, &helpers.APIRequest{
+		Method:      http.MethodGet,
+		Endpoint:    server.RuleFeedbackStatsEndpoint,
+		XRHIdentity: makeIdentityToken(t),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"rules":[],"status":"ok"}`,
+	})
+}
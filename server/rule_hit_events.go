@@ -0,0 +1,98 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// readRuleHitEventsForCluster returns new, resolved and changed rule hits
+// for selected cluster, optionally limited by `since` and `until` query
+// parameters
+func (server *HTTPServer) readRuleHitEventsForCluster(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	clusterName, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	since, until, successful := readTimeRangeQueryParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	events, err := server.Storage.ReadRuleHitEventsForCluster(orgID, clusterName, since, until)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule hit events for cluster")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("events", events))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// readRuleHitEventsForOrg returns new, resolved and changed rule hits for
+// all clusters in selected organization, optionally limited by `since` and
+// `until` query parameters
+func (server *HTTPServer) readRuleHitEventsForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	since, until, successful := readTimeRangeQueryParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	events, err := server.Storage.ReadRuleHitEventsForOrg(orgID, since, until)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule hit events for organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("events", events))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
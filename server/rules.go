@@ -25,9 +25,44 @@ import (
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
-// disableRuleForCluster disables a rule for specified cluster, excluding it from reports
+// disableRuleForCluster disables a rule for specified cluster, excluding it
+// from reports. The rule is disabled only until the time provided in the
+// optional `until` query parameter.
 func (server *HTTPServer) disableRuleForCluster(writer http.ResponseWriter, request *http.Request) {
-	server.toggleRuleForCluster(writer, request, storage.RuleToggleDisable)
+	if request.URL.Query().Get("until") == "" {
+		server.toggleRuleForCluster(writer, request, storage.RuleToggleDisable)
+		return
+	}
+
+	clusterID, ruleID, successful := server.readClusterRuleParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	until, successful := readDisabledUntilQueryParam(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = server.checkUserClusterPermissions(writer, request, clusterID)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	err := server.auditedStorage(request).DisableRuleForClusterUntil(clusterID, ruleID, until)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to disable rule for selected cluster")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
 }
 
 // enableRuleForCluster enables a previously disabled rule, showing it on reports again
@@ -49,7 +84,7 @@ func (server *HTTPServer) toggleRuleForCluster(writer http.ResponseWriter, reque
 		return
 	}
 
-	err := server.Storage.ToggleRuleForCluster(clusterID, ruleID, toggleRule)
+	err := server.auditedStorage(request).ToggleRuleForCluster(clusterID, ruleID, toggleRule)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to toggle rule for selected cluster")
 		handleServerError(writer, err)
@@ -62,18 +97,97 @@ func (server *HTTPServer) toggleRuleForCluster(writer http.ResponseWriter, reque
 	}
 }
 
-// getFeedbackAndTogglesOnRules
+// disableRuleForClusters disables a rule for listed or all clusters in organization
+func (server *HTTPServer) disableRuleForClusters(writer http.ResponseWriter, request *http.Request) {
+	server.toggleRuleForClusters(writer, request, storage.RuleToggleDisable)
+}
+
+// enableRuleForClusters enables a previously disabled rule for listed or all clusters in organization
+func (server *HTTPServer) enableRuleForClusters(writer http.ResponseWriter, request *http.Request) {
+	server.toggleRuleForClusters(writer, request, storage.RuleToggleEnable)
+}
+
+// toggleRuleForClusters contains shared functionality for bulk enable/disable.
+// Clusters are read from the request body, the rule is toggled for all
+// clusters in organization only when `all_clusters` flag is set.
+func (server *HTTPServer) toggleRuleForClusters(writer http.ResponseWriter, request *http.Request, toggleRule storage.RuleToggle) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	ruleID, successful := readRuleID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	clusters, allClusters, successful := readClusterSelectionFromBody(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	var (
+		toggledClusters []types.ClusterName
+		err             error
+	)
+
+	if allClusters {
+		toggledClusters, err = server.auditedStorage(request).ToggleRuleForAllOrgClusters(orgID, ruleID, toggleRule)
+	} else {
+		toggledClusters, err = server.auditedStorage(request).ToggleRuleForOrgClusters(orgID, clusters, ruleID, toggleRule)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to toggle rule for selected clusters")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("clusters", toggledClusters))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getFeedbackAndTogglesOnRules fills in user votes and disabled status of the
+// rules. Rules acknowledged for the whole organization are treated as disabled.
 func (server HTTPServer) getFeedbackAndTogglesOnRules(
+	orgID types.OrgID,
 	clusterName types.ClusterName,
 	userID types.UserID,
 	rules []types.RuleOnReport,
-) ([]types.RuleOnReport, error) {
+) ([]types.RuleOnReportResponse, error) {
 	togglesRules, err := server.Storage.GetTogglesForRules(clusterName, rules)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to retrieve disabled status from database")
 		return nil, err
 	}
 
+	disabledUntil, err := server.Storage.GetDisabledUntilForRules(clusterName, rules)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to retrieve time limits of disabled rules from database")
+		return nil, err
+	}
+
+	acks, err := server.Storage.ReadRuleAcksForOrg(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to retrieve rule acknowledgements from database")
+		return nil, err
+	}
+
+	ackedRules := make(map[types.RuleID]storage.RuleAck, len(acks))
+	for _, ack := range acks {
+		ackedRules[ack.RuleID] = ack
+	}
+
 	feedbacks, err := server.Storage.GetUserFeedbackOnRules(clusterName, rules, userID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to retrieve feedback results from database")
@@ -86,6 +200,8 @@ func (server HTTPServer) getFeedbackAndTogglesOnRules(
 		return nil, err
 	}
 
+	response := make([]types.RuleOnReportResponse, len(rules))
+
 	for i := range rules {
 		ruleID := rules[i].Module
 		if vote, found := feedbacks[ruleID]; found {
@@ -104,9 +220,28 @@ func (server HTTPServer) getFeedbackAndTogglesOnRules(
 			rules[i].DisableFeedback = disableFeedback.Message
 			rules[i].DisabledAt = types.Timestamp(disableFeedback.UpdatedAt.Format(time.RFC3339))
 		}
+
+		if until, found := disabledUntil[ruleID]; found {
+			response[i].DisabledUntil = types.Timestamp(until.Format(time.RFC3339))
+		}
+
+		// justification of the acknowledgement is used when the rule
+		// wasn't disabled for this cluster with its own feedback, time
+		// limit of disabling doesn't apply to acknowledged rules
+		if ack, found := ackedRules[ruleID]; found {
+			rules[i].Disabled = true
+			response[i].DisabledUntil = ""
+
+			if rules[i].DisabledAt == "" {
+				rules[i].DisableFeedback = ack.Justification
+				rules[i].DisabledAt = types.Timestamp(ack.UpdatedAt.Format(time.RFC3339))
+			}
+		}
+
+		response[i].RuleOnReport = rules[i]
 	}
 
-	return rules, nil
+	return response, nil
 }
 
 func (server HTTPServer) saveDisableFeedback(writer http.ResponseWriter, request *http.Request) {
@@ -128,7 +263,7 @@ func (server HTTPServer) saveDisableFeedback(writer http.ResponseWriter, request
 		return
 	}
 
-	err = server.Storage.AddFeedbackOnRuleDisable(clusterID, ruleID, userID, feedback)
+	err = server.auditedStorage(request).AddFeedbackOnRuleDisable(clusterID, ruleID, userID, feedback)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -144,16 +279,32 @@ func (server HTTPServer) saveDisableFeedback(writer http.ResponseWriter, request
 
 // getFeedbackAndTogglesOnRule
 func (server HTTPServer) getFeedbackAndTogglesOnRule(
+	orgID types.OrgID,
 	clusterName types.ClusterName,
 	userID types.UserID,
 	rule types.RuleOnReport,
-) types.RuleOnReport {
+) (types.RuleOnReportResponse, error) {
+	var disabledUntil types.Timestamp
+
 	ruleToggle, err := server.Storage.GetFromClusterRuleToggle(clusterName, rule.Module)
 	if err != nil {
 		log.Error().Err(err).Msg("Rule toggle was not found")
 		rule.Disabled = false
 	} else {
 		rule.Disabled = ruleToggle.Disabled == storage.RuleToggleDisable
+		if rule.Disabled && ruleToggle.DisabledUntil.Valid {
+			disabledUntil = types.Timestamp(ruleToggle.DisabledUntil.Time.Format(time.RFC3339))
+		}
+	}
+
+	// rule acknowledged for the whole organization is treated as disabled
+	_, err = server.Storage.GetRuleAckForOrg(orgID, rule.Module)
+	if err == nil {
+		rule.Disabled = true
+		disabledUntil = ""
+	} else if _, notFound := err.(*types.ItemNotFoundError); !notFound {
+		log.Error().Err(err).Msg("Unable to retrieve rule acknowledgement from database")
+		return types.RuleOnReportResponse{}, err
 	}
 
 	feedback, err := server.Storage.GetUserFeedbackOnRule(clusterName, rule.Module, userID)
@@ -164,5 +315,5 @@ func (server HTTPServer) getFeedbackAndTogglesOnRule(
 		rule.UserVote = feedback.UserVote
 	}
 
-	return rule
+	return types.RuleOnReportResponse{RuleOnReport: rule, DisabledUntil: disabledUntil}, nil
 }
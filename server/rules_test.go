@@ -0,0 +1,274 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	httputils "github.com/RedHatInsights/insights-operator-utils/http"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const otherClusterName = types.ClusterName("11111111-1111-1111-1111-111111111111")
+
+func mustWriteReportsForBulkToggle(t *testing.T, mockStorage storage.Storage) {
+	for _, clusterName := range []types.ClusterName{testdata.ClusterName, otherClusterName} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, clusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+}
+
+func TestHTTPServer_DisableRuleForClusters_AllClusters(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportsForBulkToggle(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		Body:         `{"all_clusters":true}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"clusters":["` + string(otherClusterName) + `","` + string(testdata.ClusterName) + `"],"status":"ok"}`,
+	})
+
+	for _, clusterName := range []types.ClusterName{testdata.ClusterName, otherClusterName} {
+		toggle, err := mockStorage.GetFromClusterRuleToggle(clusterName, testdata.Rule1ID)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+	}
+}
+
+func TestHTTPServer_EnableRuleForClusters_SelectedClusters(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportsForBulkToggle(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.EnableRuleForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		Body:         `{"clusters":["` + string(testdata.ClusterName) + `"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"clusters":["` + string(testdata.ClusterName) + `"],"status":"ok"}`,
+	})
+
+	toggle, err := mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleEnable, toggle.Disabled)
+
+	_, err = mockStorage.GetFromClusterRuleToggle(otherClusterName, testdata.Rule1ID)
+	assert.Error(t, err)
+}
+
+func TestHTTPServer_DisableRuleForClusters_UnknownCluster(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		Body:         `{"clusters":["` + string(testdata.ClusterName) + `"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status":"Item with ID ` + string(testdata.ClusterName) + ` was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_DisableRuleForClusters_BadClusterName(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		Body:         `{"clusters":["` + string(testdata.BadClusterName) + `"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'cluster' with value '` + string(testdata.BadClusterName) + `'. Error: 'invalid UUID length: 4'"
+		}`,
+	})
+}
+
+func TestHTTPServer_DisableRuleForClusters_BadBody(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		Body:         `{"clusters":`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status":"unexpected end of JSON input"}`,
+	})
+}
+
+// TestHTTPServer_DisableRuleForClusters_ChunkedBody checks that the body is
+// read even when its length is unknown
+func TestHTTPServer_DisableRuleForClusters_ChunkedBody(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportsForBulkToggle(t, mockStorage)
+
+	url := httputils.MakeURLToEndpoint(
+		helpers.DefaultServerConfig.APIPrefix,
+		server.DisableRuleForClustersEndpoint,
+		testdata.OrgID, testdata.Rule1ID,
+	)
+
+	req := httptest.NewRequest(
+		http.MethodPut, url, strings.NewReader(`{"clusters":["`+string(testdata.ClusterName)+`"]}`),
+	)
+	req.ContentLength = -1
+
+	response := helpers.ExecuteRequest(server.New(helpers.DefaultServerConfig, mockStorage), req).Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	// only the listed cluster is toggled
+	_, err := mockStorage.GetFromClusterRuleToggle(otherClusterName, testdata.Rule1ID)
+	assert.Error(t, err)
+}
+
+func TestHTTPServer_DisableRuleForClusters_NoBody(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status":"client didn't provide request body"}`,
+	})
+}
+
+// TestHTTPServer_DisableRuleForClusters_BadSelection checks that neither
+// empty list of clusters nor the list together with all_clusters flag
+// are accepted
+func TestHTTPServer_DisableRuleForClusters_BadSelection(t *testing.T) {
+	for _, body := range []string{
+		`{"clusters":[]}`,
+		`{}`,
+		`{"clusters":[],"all_clusters":false}`,
+		`{"clusters":["` + string(testdata.ClusterName) + `"],"all_clusters":true}`,
+	} {
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     server.DisableRuleForClustersEndpoint,
+			EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+			Body:         body,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"status":"either non-empty list of clusters or all_clusters flag is expected"}`,
+		})
+	}
+}
+
+func TestHTTPServer_DisableRuleForClusters_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		Body:         `{"all_clusters":true}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status":"Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_DisableRuleForClusterUntil(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	until := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint + "?until=" + until,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Report types.ReportResponse `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+			for _, rule := range response.Report.Report {
+				if rule.Module == testdata.Rule1ID {
+					assert.True(t, rule.Disabled)
+					assert.Equal(t, types.Timestamp(until), rule.DisabledUntil)
+				} else {
+					assert.False(t, rule.Disabled)
+					assert.Empty(t, rule.DisabledUntil)
+				}
+			}
+		},
+	})
+}
+
+func TestHTTPServer_DisableRuleForClusterUntil_BadTime(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	for _, until := range []string{"tomorrow", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)} {
+		helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     server.DisableRuleForClusterEndpoint + "?until=" + until,
+			EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: `{"status": "Error during parsing param 'until' with value '` + until +
+				`'. Error: 'timestamp in the future in RFC 3339 format expected'"}`,
+		})
+	}
+}
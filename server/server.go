@@ -22,14 +22,50 @@ limitations under the License.
 //
 // API_PREFIX/organizations/{organization}/clusters - list of all clusters for given organization (HTTP GET)
 //
+// API_PREFIX/organizations/{organization}/clusters/{cluster} - metadata of given cluster, like OCP version or platform (HTTP GET)
+//
 // API_PREFIX/report/{organization}/{cluster} - insights OCP results for given cluster name (HTTP GET)
 //
+// API_PREFIX/organizations/{organization}/clusters/{cluster}/reports/history - all previous results for given cluster name (HTTP GET)
+//
+// API_PREFIX/organizations/{organization}/clusters/{cluster}/events - new, resolved and changed rule hits for given cluster name (HTTP GET)
+//
+// API_PREFIX/organizations/{organization}/events - new, resolved and changed rule hits for all clusters in given organization (HTTP GET)
+//
+// API_PREFIX/organizations/{organization}/rules - rules hitting clusters in given organization with the clusters they hit (HTTP GET)
+//
+// API_PREFIX/organizations/{organization}/rules/{rule_id}/clusters - clusters in given organization hit by given rule (HTTP GET)
+//
+// API_PREFIX/organizations/{organization}/rules/{rule_id}/disable - disable a rule for listed or all clusters in given organization (HTTP PUT)
+//
+// API_PREFIX/organizations/{organization}/rules/{rule_id}/enable - enable a rule for listed or all clusters in given organization (HTTP PUT)
+//
+// API_PREFIX/organizations/{organization}/acks - rules acknowledged for all clusters in given organization (HTTP GET)
+//
+// API_PREFIX/organizations/{organization}/users/{user_id}/acks/{rule_id} - acknowledge (HTTP PUT) or delete acknowledgement (HTTP DELETE) of a rule for all clusters in given organization
+//
+// API_PREFIX/organizations/{organization}/audit_log - changes made by users of given organization (HTTP GET)
+//
+// API_PREFIX/rules/feedback - votes, disables and feedback messages aggregated per rule, in JSON or CSV format (HTTP GET, admin users only)
+//
+// API_PREFIX/admin/organizations/{organization} - remove all data of given organization and its clusters (HTTP DELETE, admin users only)
+//
+// API_PREFIX/admin/clusters/{cluster} - remove all data of given cluster (HTTP DELETE, admin users only)
+//
+// API_PREFIX/admin/org_allowlist/reload - reload organization allowlist from its source (HTTP POST, admin users only)
+//
+// API_PREFIX/reports - process report pushed directly instead of consuming it from the broker (HTTP POST, only when enabled)
+//
 // API_PREFIX/rule/{cluster}/{rule_id}/like - like a rule for cluster with current user (from auth token)
 //
 // API_PREFIX/rule/{cluster}/{rule_id}/dislike - dislike a rule for cluster with current user (from auth token)
 //
 // API_PREFIX/rule/{cluster}/{rule_id}/reset_vote- reset vote for a rule for cluster with current user (from auth token)
 //
+// API_PREFIX/consumer_errors - list (HTTP GET) or delete (HTTP DELETE) messages that the consumer was unable to process (debug mode only)
+//
+// API_PREFIX/consumer_errors/{topic}/{partition}/{offset} - one message that the consumer was unable to process (debug mode only)
+//
 // Please note that API_PREFIX is part of server configuration (see Configuration). Also please note that
 // JSON format is used to transfer data between server and clients.
 //
@@ -75,6 +111,12 @@ type HTTPServer struct {
 	Config  Configuration
 	Storage storage.Storage
 	Serv    *http.Server
+	// ReportProcessor processes reports pushed by the ingestion endpoint,
+	// it has to be set when the endpoint is enabled
+	ReportProcessor ReportProcessor
+	// OrgAllowlistReloader reloads the organization allowlist used by the
+	// consumer, it's not set when the allowlist is disabled
+	OrgAllowlistReloader OrgAllowlistReloader
 }
 
 // New constructs new implementation of Server interface
@@ -93,19 +135,36 @@ func (server *HTTPServer) mainEndpoint(writer http.ResponseWriter, _ *http.Reque
 	}
 }
 
-func (server *HTTPServer) listOfOrganizations(writer http.ResponseWriter, _ *http.Request) {
-	organizations, err := server.Storage.ListOfOrgs()
+func (server *HTTPServer) listOfOrganizations(writer http.ResponseWriter, request *http.Request) {
+	filter, successful := readClusterListFilter(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	pagination, successful := readListPagination(writer, request, storage.SortByOrgID)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	organizations, total, err := server.Storage.ListOfOrgsPage(filter, pagination)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to get list of organizations")
 		handleServerError(writer, err)
 		return
 	}
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData("organizations", organizations))
+	err = responses.SendOK(writer, buildListResponse("organizations", organizations, total, pagination))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
 }
 
+// listOfClustersForOrganization returns filtered and paginated list of
+// clusters of the organization. The `since` and `until` query parameters
+// filter the time when the latest report was received (reported_at), while
+// `last_checked_from` and `last_checked_to` filter the time when the cluster
+// was last checked according to the report (last_checked_at).
 func (server *HTTPServer) listOfClustersForOrganization(writer http.ResponseWriter, request *http.Request) {
 	organizationID, successful := readOrganizationID(writer, request, server.Config.Auth)
 	if !successful {
@@ -113,16 +172,35 @@ func (server *HTTPServer) listOfClustersForOrganization(writer http.ResponseWrit
 		return
 	}
 
-	// TODO get limit from request param instead of hardcoded config param
-	timeLimit := time.Now().Add(-time.Duration(server.Config.OrgOverviewLimitHours) * time.Hour)
+	filter, successful := readClusterListFilter(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
 
-	clusters, err := server.Storage.ListOfClustersForOrg(organizationID, timeLimit)
+	pagination, successful := readListPagination(writer, request, storage.SortByCluster, storage.SortByLastChecked)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	filter.ReportedFrom, filter.ReportedTo, successful = readTimeRangeQueryParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	if filter.ReportedFrom.IsZero() && server.Config.OrgOverviewLimitHours > 0 {
+		filter.ReportedFrom = time.Now().Add(-time.Duration(server.Config.OrgOverviewLimitHours) * time.Hour)
+	}
+
+	clusters, total, err := server.Storage.ListOfClustersForOrgPage(organizationID, filter, pagination)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to get list of clusters")
 		handleServerError(writer, err)
 		return
 	}
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData("clusters", clusters))
+	err = responses.SendOK(writer, buildListResponse("clusters", clusters, total, pagination))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
@@ -154,11 +232,11 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 
 	hitRulesCount := len(reports)
 
-	reports, err = server.getFeedbackAndTogglesOnRules(clusterName, userID, reports)
-
+	rules, err := server.getFeedbackAndTogglesOnRules(orgID, clusterName, userID, reports)
 	if err != nil {
 		log.Error().Err(err).Msg("An error has occurred when getting feedback or toggles")
 		handleServerError(writer, err)
+		return
 	}
 
 	// -1 as count in response means there are no rules for this cluster
@@ -172,7 +250,7 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 			Count:         hitRulesCount,
 			LastCheckedAt: lastChecked,
 		},
-		Report: reports,
+		Report: rules,
 	}
 
 	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportResponse, response))
@@ -217,9 +295,13 @@ func (server *HTTPServer) readSingleRule(writer http.ResponseWriter, request *ht
 		ErrorKey:     errorKey,
 	}
 
-	reportRule = server.getFeedbackAndTogglesOnRule(clusterName, userID, reportRule)
+	ruleResponse, err := server.getFeedbackAndTogglesOnRule(orgID, clusterName, userID, reportRule)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
 
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportResponse, reportRule))
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportResponse, ruleResponse))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
@@ -251,8 +333,9 @@ func (server *HTTPServer) deleteOrganizations(writer http.ResponseWriter, reques
 		return
 	}
 
+	auditedStorage := server.auditedStorage(request)
 	for _, org := range orgIds {
-		if err := server.Storage.DeleteReportsForOrg(org); err != nil {
+		if err := auditedStorage.DeleteReportsForOrg(org); err != nil {
 			log.Error().Err(err).Msg("Unable to delete reports")
 			handleServerError(writer, err)
 			return
@@ -272,8 +355,9 @@ func (server *HTTPServer) deleteClusters(writer http.ResponseWriter, request *ht
 		return
 	}
 
+	auditedStorage := server.auditedStorage(request)
 	for _, cluster := range clusterNames {
-		if err := server.Storage.DeleteReportsForCluster(cluster); err != nil {
+		if err := auditedStorage.DeleteReportsForCluster(cluster); err != nil {
 			log.Error().Err(err).Msg("Unable to delete reports")
 			handleServerError(writer, err)
 			return
@@ -65,7 +65,7 @@ func TestListOfClustersForNonExistingOrganization(t *testing.T) {
 		EndpointArgs: []interface{}{1},
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
-		Body:       `{"clusters":[],"status":"ok"}`,
+		Body:       `{"clusters":[],"meta":{"total":0,"limit":0,"offset":0},"status":"ok"}`,
 	})
 }
 
@@ -84,7 +84,7 @@ func TestListOfClustersForOrganizationOK(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID},
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
-		Body:       `{"clusters":["` + string(testdata.ClusterName) + `"],"status":"ok"}`,
+		Body:       `{"clusters":["` + string(testdata.ClusterName) + `"],"meta":{"total":1,"limit":0,"offset":0},"status":"ok"}`,
 	})
 }
 
@@ -146,7 +146,7 @@ func TestListOfOrganizationsEmpty(t *testing.T) {
 		Endpoint: server.OrganizationsEndpoint,
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
-		Body:       `{"organizations":[],"status":"ok"}`,
+		Body:       `{"organizations":[],"meta":{"total":0,"limit":0,"offset":0},"status":"ok"}`,
 	})
 }
 
@@ -169,7 +169,7 @@ func TestListOfOrganizationsOK(t *testing.T) {
 		Endpoint: server.OrganizationsEndpoint,
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
-		Body:       `{"organizations":[1, 5],"status":"ok"}`,
+		Body:       `{"organizations":[1, 5],"meta":{"total":2,"limit":0,"offset":0},"status":"ok"}`,
 	})
 }
 
@@ -329,9 +329,19 @@ func TestRuleFeedbackVote_DBError(t *testing.T) {
 			sqlmock.NewRows([]string{"cluster"}).AddRow(testdata.ClusterName),
 		)
 
+	expects.ExpectBegin()
+
+	expects.ExpectQuery("SELECT org_id FROM report").
+		WillReturnRows(sqlmock.NewRows([]string{"org_id"}).AddRow(testdata.OrgID))
+
+	expects.ExpectQuery("SELECT .* FROM cluster_rule_user_feedback").
+		WillReturnRows(sqlmock.NewRows([]string{"cluster_id"}))
+
 	expects.ExpectPrepare("INSERT INTO").
 		WillReturnError(fmt.Errorf(errStr))
 
+	expects.ExpectRollback()
+
 	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
 		Method:       http.MethodPut,
 		Endpoint:     server.LikeRuleEndpoint,
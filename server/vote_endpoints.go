@@ -57,7 +57,7 @@ func (server *HTTPServer) voteOnRule(writer http.ResponseWriter, request *http.R
 		return
 	}
 
-	err := server.Storage.VoteOnRule(clusterID, ruleID, userID, userVote, voteMessage)
+	err := server.auditedStorage(request).VoteOnRule(clusterID, ruleID, userID, userVote, voteMessage)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -0,0 +1,378 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// AuditAction identifies the kind of change recorded in the audit log
+type AuditAction string
+
+const (
+	// AuditActionVote is recorded when user likes, dislikes or resets vote on rule
+	AuditActionVote AuditAction = "vote"
+	// AuditActionFeedback is recorded when user adds or updates feedback on rule
+	AuditActionFeedback AuditAction = "feedback"
+	// AuditActionDisableFeedback is recorded when user adds or updates feedback on rule disable
+	AuditActionDisableFeedback AuditAction = "disable_feedback"
+	// AuditActionRuleToggle is recorded when rule is disabled or enabled for cluster
+	AuditActionRuleToggle AuditAction = "rule_toggle"
+	// AuditActionRuleAck is recorded when rule is acknowledged for organization
+	AuditActionRuleAck AuditAction = "rule_ack"
+	// AuditActionDeleteRuleAck is recorded when rule acknowledgement is deleted
+	AuditActionDeleteRuleAck AuditAction = "delete_rule_ack"
+	// AuditActionDeleteOrgReports is recorded when reports of organization are deleted
+	AuditActionDeleteOrgReports AuditAction = "delete_org_reports"
+	// AuditActionDeleteClusterReports is recorded when reports of cluster are deleted
+	AuditActionDeleteClusterReports AuditAction = "delete_cluster_reports"
+	// AuditActionEraseOrgData is recorded when all data of organization are erased
+	AuditActionEraseOrgData AuditAction = "erase_org_data"
+	// AuditActionEraseClusterData is recorded when all data of cluster are erased
+	AuditActionEraseClusterData AuditAction = "erase_cluster_data"
+)
+
+// AuditInfo identifies who made the change and in which request. User ID is
+// taken from the identity of the caller, the organization recorded in the
+// audit log is the one whose data are changed.
+type AuditInfo struct {
+	UserID    types.UserID
+	RequestID types.RequestID
+}
+
+// AuditLogEntry represents a record from audit_log table. Before and After
+// contain JSON representation of the changed item, they are null when the
+// item didn't exist before the change or doesn't exist after it.
+type AuditLogEntry struct {
+	CreatedAt time.Time       `json:"created_at"`
+	UserID    types.UserID    `json:"user_id"`
+	OrgID     types.OrgID     `json:"org_id"`
+	RequestID types.RequestID `json:"request_id"`
+	Action    AuditAction     `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
+// AuditLogFilter selects audit log entries to be read from the storage,
+// zero values mean that the attribute is not used for filtering
+type AuditLogFilter struct {
+	OrgID  types.OrgID
+	UserID types.UserID
+	Action AuditAction
+	// Since and Until limit the time when the change was made
+	Since time.Time
+	Until time.Time
+	// Limit is the maximum number of entries to be read and Offset is the
+	// number of entries to be skipped
+	Limit  uint64
+	Offset uint64
+}
+
+// whereClause returns SQL condition and its arguments for the filter
+func (filter AuditLogFilter) whereClause() (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.OrgID != 0 {
+		addCondition("org_id = $%d", filter.OrgID)
+	}
+	if filter.UserID != "" {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		addCondition("created_at < $%d", filter.Until)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// reportAuditValue is the representation of deleted report in the audit log
+type reportAuditValue struct {
+	OrgID         types.OrgID       `json:"org_id"`
+	ClusterName   types.ClusterName `json:"cluster"`
+	LastCheckedAt time.Time         `json:"last_checked_at"`
+}
+
+// ruleToggleAuditValue is the representation of rule toggle in the audit log
+type ruleToggleAuditValue struct {
+	Disabled      bool       `json:"disabled"`
+	DisabledUntil *time.Time `json:"disabled_until,omitempty"`
+}
+
+// auditOrg returns ID of the organization whose data are changed, it's
+// called in the transaction before the change is made
+type auditOrg func(tx *sql.Tx) (types.OrgID, error)
+
+// orgForAudit returns auditOrg for changes of the given organization
+func orgForAudit(orgID types.OrgID) auditOrg {
+	return func(*sql.Tx) (types.OrgID, error) {
+		return orgID, nil
+	}
+}
+
+// clusterOrgForAudit returns auditOrg for changes of the cluster. The
+// organization is read from the report of the cluster, zero is used for
+// clusters without report.
+func clusterOrgForAudit(clusterName types.ClusterName) auditOrg {
+	return func(tx *sql.Tx) (types.OrgID, error) {
+		var orgID types.OrgID
+
+		err := tx.QueryRow("SELECT org_id FROM report WHERE cluster = $1", clusterName).Scan(&orgID)
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		return orgID, err
+	}
+}
+
+// WithAuditInfo returns a copy of the storage that records all changes made
+// through it into the audit log, in the same transaction as the change
+func (storage DBStorage) WithAuditInfo(info AuditInfo) Storage {
+	storage.auditInfo = &info
+	return &storage
+}
+
+// writeAuditLog appends a record about the change to the audit log. Nothing
+// is written when the storage has no audit info.
+func (storage DBStorage) writeAuditLog(
+	tx *sql.Tx, orgID types.OrgID, action AuditAction, target string, before, after interface{},
+) error {
+	if storage.auditInfo == nil {
+		return nil
+	}
+
+	beforeValue, err := auditValue(before)
+	if err != nil {
+		return err
+	}
+
+	afterValue, err := auditValue(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_log
+		(created_at, user_id, org_id, request_id, action, target, before_value, after_value)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`,
+		time.Now().UTC(),
+		storage.auditInfo.UserID,
+		orgID,
+		storage.auditInfo.RequestID,
+		action,
+		target,
+		beforeValue,
+		afterValue,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to write audit log")
+	}
+
+	return err
+}
+
+// auditValue converts the item to JSON stored in the audit log, NULL is
+// used for missing items
+func auditValue(item interface{}) (sql.NullString, error) {
+	if item == nil {
+		return sql.NullString{}, nil
+	}
+
+	value, err := json.Marshal(item)
+	if err != nil || string(value) == "null" {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(value), Valid: true}, nil
+}
+
+// readReportsForAudit reads reports that are going to be deleted. Condition
+// selects the reports with the only argument arg.
+func readReportsForAudit(tx *sql.Tx, condition string, arg interface{}) ([]reportAuditValue, error) {
+	rows, err := tx.Query(
+		"SELECT org_id, cluster, last_checked_at FROM report WHERE "+condition+" ORDER BY cluster", arg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	reports := make([]reportAuditValue, 0)
+	for rows.Next() {
+		var report reportAuditValue
+
+		err = rows.Scan(&report.OrgID, &report.ClusterName, &report.LastCheckedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("readReportsForAudit")
+			return nil, err
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// ReadAuditLog reads audit log entries selected by the filter, the most
+// recent entries come first
+func (storage DBStorage) ReadAuditLog(filter AuditLogFilter) ([]AuditLogEntry, error) {
+	entries := make([]AuditLogEntry, 0)
+
+	where, args := filter.whereClause()
+
+	rows, err := storage.connection.Query(`
+		SELECT created_at, user_id, org_id, request_id, action, target, before_value, after_value
+		FROM audit_log
+		`+where+`
+		ORDER BY created_at DESC
+		`+limitOffsetClause(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return entries, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			entry                   AuditLogEntry
+			beforeValue, afterValue sql.NullString
+		)
+
+		err = rows.Scan(
+			&entry.CreatedAt,
+			&entry.UserID,
+			&entry.OrgID,
+			&entry.RequestID,
+			&entry.Action,
+			&entry.Target,
+			&beforeValue,
+			&afterValue,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadAuditLog")
+			return entries, err
+		}
+
+		if beforeValue.Valid {
+			entry.Before = json.RawMessage(beforeValue.String)
+		}
+		if afterValue.Valid {
+			entry.After = json.RawMessage(afterValue.String)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// changeWithAudit executes the change in a transaction together with writing
+// the audit log record about it
+func (storage DBStorage) changeWithAudit(
+	org auditOrg,
+	action AuditAction,
+	target string,
+	readItem func(tx *sql.Tx) (interface{}, error),
+	change func(tx *sql.Tx) error,
+) error {
+	tx, err := storage.connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = storage.auditChange(tx, org, action, target, readItem, change)
+
+	finishTransaction(tx, err)
+
+	return err
+}
+
+// auditChange executes the change in the transaction and records it into the
+// audit log. readItem reads the changed item, it's called before and after
+// the change only when the storage has audit info. readItem returns nil for
+// items that don't exist.
+func (storage DBStorage) auditChange(
+	tx *sql.Tx,
+	org auditOrg,
+	action AuditAction,
+	target string,
+	readItem func(tx *sql.Tx) (interface{}, error),
+	change func(tx *sql.Tx) error,
+) error {
+	if storage.auditInfo == nil {
+		return change(tx)
+	}
+
+	orgID, err := org(tx)
+	if err != nil {
+		return err
+	}
+
+	before, err := readItem(tx)
+	if err != nil {
+		return err
+	}
+
+	err = change(tx)
+	if err != nil {
+		return err
+	}
+
+	after, err := readItem(tx)
+	if err != nil {
+		return err
+	}
+
+	return storage.writeAuditLog(tx, orgID, action, target, before, after)
+}
+
+// optionalAuditItem converts ItemNotFoundError returned when reading the
+// item for the audit log to nil item
+func optionalAuditItem(item interface{}, err error) (interface{}, error) {
+	if _, notFound := err.(*types.ItemNotFoundError); notFound {
+		return nil, nil
+	}
+
+	return item, err
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+var testAuditInfo = storage.AuditInfo{
+	UserID:    testdata.UserID,
+	RequestID: "request-1",
+}
+
+func TestDBStorage_AuditLog_NotWrittenWithoutAuditInfo(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	err := mockStorage.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike, "")
+	helpers.FailOnError(t, err)
+
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDBStorage_AuditLog_Vote(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	audited := mockStorage.WithAuditInfo(testAuditInfo)
+
+	err := audited.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike, "")
+	helpers.FailOnError(t, err)
+
+	err = audited.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteDislike, "")
+	helpers.FailOnError(t, err)
+
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 2)
+
+	for _, entry := range entries {
+		assert.Equal(t, testdata.UserID, entry.UserID)
+		assert.Equal(t, testdata.OrgID, entry.OrgID)
+		assert.Equal(t, types.RequestID("request-1"), entry.RequestID)
+		assert.Equal(t, storage.AuditActionVote, entry.Action)
+		assert.Equal(t, string(testdata.ClusterName)+"/"+string(testdata.Rule1ID)+"/"+string(testdata.UserID), entry.Target)
+		assert.Contains(t, string(entry.After), `"user_vote":`)
+	}
+
+	// the first vote had nothing to change
+	beforeValues := []string{string(entries[0].Before), string(entries[1].Before)}
+	assert.Contains(t, beforeValues, "")
+}
+
+func TestDBStorage_AuditLog_RuleToggle(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	audited := mockStorage.WithAuditInfo(testAuditInfo)
+
+	helpers.FailOnError(t, audited.ToggleRuleForCluster(testdata.ClusterName, testdata.Rule1ID, storage.RuleToggleDisable))
+
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{Action: storage.AuditActionRuleToggle})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Nil(t, entries[0].Before)
+	assert.JSONEq(t, `{"disabled": true}`, string(entries[0].After))
+}
+
+func TestDBStorage_AuditLog_ClusterOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.Org2ID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	audited := mockStorage.WithAuditInfo(testAuditInfo)
+
+	// changes of the cluster are recorded for the organization of the cluster
+	helpers.FailOnError(t, audited.ToggleRuleForCluster(testdata.ClusterName, testdata.Rule1ID, storage.RuleToggleDisable))
+	helpers.FailOnError(t, audited.DeleteReportsForCluster(testdata.ClusterName))
+
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{OrgID: testdata.Org2ID})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 2)
+
+	// cluster without report doesn't belong to any organization
+	helpers.FailOnError(t, audited.ToggleRuleForCluster(testdata.ClusterName, testdata.Rule2ID, storage.RuleToggleDisable))
+
+	entries, err = mockStorage.ReadAuditLog(storage.AuditLogFilter{Action: storage.AuditActionRuleToggle})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, types.OrgID(0), entries[0].OrgID)
+}
+
+func TestDBStorage_AuditLog_RollbackOnError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	audited := mockStorage.WithAuditInfo(testAuditInfo)
+
+	err := audited.DeleteRuleAckForOrg(testdata.OrgID, testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDBStorage_AuditLog_DeleteReportsForOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	helpers.FailOnError(t, mockStorage.WithAuditInfo(testAuditInfo).DeleteReportsForOrg(testdata.OrgID))
+
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{OrgID: testdata.OrgID})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, storage.AuditActionDeleteOrgReports, entries[0].Action)
+	assert.Contains(t, string(entries[0].Before), string(testdata.ClusterName))
+	assert.JSONEq(t, `[]`, string(entries[0].After))
+}
+
+func TestDBStorage_ReadAuditLog_Filter(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.WithAuditInfo(testAuditInfo).AckRuleForOrg(
+		testdata.OrgID, testdata.Rule1ID, testdata.UserID, "",
+	))
+	helpers.FailOnError(t, mockStorage.WithAuditInfo(storage.AuditInfo{
+		UserID: "2",
+	}).AckRuleForOrg(testdata.Org2ID, testdata.Rule1ID, "2", ""))
+
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{OrgID: testdata.Org2ID})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, types.UserID("2"), entries[0].UserID)
+
+	entries, err = mockStorage.ReadAuditLog(storage.AuditLogFilter{UserID: testdata.UserID})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, testdata.OrgID, entries[0].OrgID)
+
+	entries, err = mockStorage.ReadAuditLog(storage.AuditLogFilter{Since: time.Now().Add(time.Hour)})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, entries)
+
+	entries, err = mockStorage.ReadAuditLog(storage.AuditLogFilter{Limit: 1, Offset: 1})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 1)
+}
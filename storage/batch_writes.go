@@ -0,0 +1,173 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// maxRowsPerInsert limits the number of rows inserted by one statement, so
+// the number of query parameters stays under the limits of supported databases
+const maxRowsPerInsert = 100
+
+// ReportForCluster contains everything that is needed to write one report
+// (see WriteReportForCluster)
+type ReportForCluster struct {
+	OrgID           types.OrgID
+	ClusterName     types.ClusterName
+	Report          types.ClusterReport
+	Rules           []types.ReportItem
+	LastCheckedTime time.Time
+	KafkaOffset     types.KafkaOffset
+}
+
+// WrittenReport identifies a report written by WriteReportsForClusters by its
+// index in the batch. NewRuleHits contains rule hits that were not present in
+// the previous report for the cluster.
+type WrittenReport struct {
+	Index       int
+	NewRuleHits []RuleHitEvent
+}
+
+// insertRows inserts rows by multi-row INSERT statements. Each statement
+// consists of the insert clause (e.g. "INSERT INTO table(a, b)"), values of
+// the rows and the conflict clause (e.g. "ON CONFLICT DO NOTHING") that can be
+// empty. Nothing is done when there are no rows to be inserted.
+func insertRows(tx *sql.Tx, insertClause, conflictClause string, rows [][]interface{}) error {
+	for len(rows) > 0 {
+		chunk := rows
+		if len(chunk) > maxRowsPerInsert {
+			chunk = chunk[:maxRowsPerInsert]
+		}
+		rows = rows[len(chunk):]
+
+		var (
+			values []string
+			args   []interface{}
+		)
+
+		for _, row := range chunk {
+			placeholders := make([]string, 0, len(row))
+			for _, value := range row {
+				args = append(args, value)
+				placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+			}
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+		}
+
+		// disable "G202 (CWE-89): SQL string concatenation"
+		// #nosec G202
+		query := insertClause + " VALUES " + strings.Join(values, ", ") + " " + conflictClause + ";"
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteReportsForClusters writes results (health statuses) for many clusters
+// in one transaction. Reports that are not newer than reports already written
+// for the same clusters are skipped, like in WriteReportForCluster. Either all
+// reports are written or none of them when an error is returned. Reports that
+// have actually been written are returned together with their new rule hits.
+func (storage DBStorage) WriteReportsForClusters(reports []ReportForCluster) ([]WrittenReport, error) {
+	if storage.dbDriverType != types.DBDriverSQLite3 && storage.dbDriverType != types.DBDriverPostgres {
+		return nil, fmt.Errorf("writing report with DB %v is not supported", storage.dbDriverType)
+	}
+
+	// Begin a new transaction.
+	tx, err := storage.connection.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// timestamps of reports written in this transaction
+	written := make(map[types.ClusterName]time.Time)
+	writtenReports := make([]WrittenReport, 0, len(reports))
+
+	err = func(tx *sql.Tx) error {
+		for i, report := range reports {
+			// only the cache is consulted here, clusters missing in it are
+			// checked by hasMoreRecentReport within the transaction
+			oldLastChecked, exists := written[report.ClusterName]
+			if !exists {
+				oldLastChecked, exists = storage.clustersLastChecked.get(report.ClusterName)
+			}
+
+			// Skip writing the report if it isn't newer than a report
+			// that is already in the database for the same cluster.
+			if exists && !report.LastCheckedTime.After(oldLastChecked) {
+				log.Info().Msgf("Skipping report for organization %d and cluster name %s, more recent one already exists",
+					report.OrgID, report.ClusterName)
+				continue
+			}
+
+			moreRecent, err := storage.hasMoreRecentReport(tx, report.OrgID, report.ClusterName, report.LastCheckedTime)
+			if err != nil {
+				return err
+			}
+			if moreRecent {
+				continue
+			}
+
+			newHits, err := storage.updateReport(
+				tx,
+				report.OrgID,
+				report.ClusterName,
+				report.Report,
+				report.Rules,
+				report.LastCheckedTime,
+				report.KafkaOffset,
+			)
+			if err != nil {
+				return err
+			}
+
+			written[report.ClusterName] = report.LastCheckedTime
+			writtenReports = append(writtenReports, WrittenReport{Index: i, NewRuleHits: newHits})
+		}
+
+		return nil
+	}(tx)
+
+	if err != nil {
+		finishTransaction(tx, err)
+		return nil, err
+	}
+
+	// commit error has to be returned, so the reports can be written again
+	if err = tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("Unable to commit batch of reports")
+		return nil, err
+	}
+
+	// the cache is updated only when the transaction has been committed
+	for clusterName, lastChecked := range written {
+		storage.setClusterLastChecked(clusterName, lastChecked)
+	}
+	metrics.WrittenReports.Add(float64(len(writtenReports)))
+
+	return writtenReports, nil
+}
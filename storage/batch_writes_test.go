@@ -0,0 +1,168 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestDBStorageWriteReportsForClusters(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	olderTime := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newerTime := olderTime.Add(30 * time.Minute)
+	anotherCluster := testdata.GetRandomClusterID()
+
+	written, err := mockStorage.WriteReportsForClusters([]storage.ReportForCluster{
+		{
+			OrgID:           testdata.OrgID,
+			ClusterName:     testdata.ClusterName,
+			Report:          testdata.Report3Rules,
+			Rules:           testdata.Report3RulesParsed,
+			LastCheckedTime: olderTime,
+			KafkaOffset:     testdata.KafkaOffset,
+		},
+		{
+			OrgID:           testdata.OrgID,
+			ClusterName:     anotherCluster,
+			Report:          testdata.Report2Rules,
+			Rules:           testdata.Report2RulesParsed,
+			LastCheckedTime: olderTime,
+			KafkaOffset:     testdata.KafkaOffset,
+		},
+		{
+			OrgID:           testdata.OrgID,
+			ClusterName:     testdata.ClusterName,
+			Report:          testdata.Report2Rules,
+			Rules:           testdata.Report2RulesParsed,
+			LastCheckedTime: newerTime,
+			KafkaOffset:     testdata.KafkaOffset,
+		},
+		// older than the report above, so it is skipped
+		{
+			OrgID:           testdata.OrgID,
+			ClusterName:     testdata.ClusterName,
+			Report:          testdata.ClusterReportEmpty,
+			Rules:           testdata.ReportEmptyRulesParsed,
+			LastCheckedTime: olderTime.Add(time.Minute),
+			KafkaOffset:     testdata.KafkaOffset,
+		},
+	})
+	helpers.FailOnError(t, err)
+
+	// the last report is not written
+	assert.Len(t, written, 3)
+	for i, writtenReport := range written {
+		assert.Equal(t, i, writtenReport.Index)
+	}
+
+	// all rule hits are new for the clusters written for the first time
+	assert.Len(t, written[0].NewRuleHits, len(testdata.Report3RulesParsed))
+	assert.Len(t, written[1].NewRuleHits, len(testdata.Report2RulesParsed))
+	for _, ruleHit := range written[1].NewRuleHits {
+		assert.Equal(t, anotherCluster, ruleHit.ClusterName)
+		assert.Equal(t, storage.RuleHitEventNew, ruleHit.Type)
+		assert.Equal(t, types.Timestamp(olderTime.Format(time.RFC3339)), ruleHit.LastCheckedAt)
+	}
+
+	report, lastChecked, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, report, len(testdata.Report2RulesParsed))
+	assert.Equal(t, types.Timestamp(newerTime.Format(time.RFC3339)), lastChecked)
+
+	report, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, anotherCluster)
+	helpers.FailOnError(t, err)
+	assert.Len(t, report, len(testdata.Report2RulesParsed))
+
+	// both written reports are kept in history
+	history, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, history, 2)
+
+	// written reports are not written again
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.ReportEmptyRulesParsed,
+		newerTime,
+		testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+}
+
+func TestDBStorageWriteReportsForClustersManyRules(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// more rule hits than can be inserted by one statement
+	var rules []types.ReportItem
+	for i := 0; i < 250; i++ {
+		rules = append(rules, types.ReportItem{
+			Module:       testdata.Rule1ID,
+			ErrorKey:     types.ErrorKey(fmt.Sprintf("ERROR_KEY_%d", i)),
+			TemplateData: json.RawMessage(testdata.Rule1ExtraData),
+		})
+	}
+
+	_, err := mockStorage.WriteReportsForClusters([]storage.ReportForCluster{{
+		OrgID:           testdata.OrgID,
+		ClusterName:     testdata.ClusterName,
+		Report:          testdata.Report3Rules,
+		Rules:           rules,
+		LastCheckedTime: testdata.LastCheckedAt,
+		KafkaOffset:     testdata.KafkaOffset,
+	}})
+	helpers.FailOnError(t, err)
+
+	events, err := mockStorage.ReadRuleHitEventsForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, events, 250)
+}
+
+func TestDBStorageWriteReportsForClustersClosedStorage(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	// we need to close storage right now
+	closer()
+
+	_, err := mockStorage.WriteReportsForClusters([]storage.ReportForCluster{{
+		OrgID:           testdata.OrgID,
+		ClusterName:     testdata.ClusterName,
+		Report:          testdata.Report3Rules,
+		Rules:           testdata.Report3RulesParsed,
+		LastCheckedTime: testdata.LastCheckedAt,
+		KafkaOffset:     testdata.KafkaOffset,
+	}})
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageWriteReportsForClustersUnsupportedDriverError(t *testing.T) {
+	fakeStorage := storage.NewFromConnection(nil, -1)
+
+	_, err := fakeStorage.WriteReportsForClusters([]storage.ReportForCluster{})
+	assert.EqualError(t, err, "writing report with DB -1 is not supported")
+}
@@ -0,0 +1,300 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// SortByOrgID sorts organizations by their IDs
+	SortByOrgID = "org_id"
+	// SortByCluster sorts clusters by their names
+	SortByCluster = "cluster"
+	// SortByLastChecked sorts clusters by the time they were last checked
+	SortByLastChecked = "last_checked_at"
+)
+
+// ClusterListFilter limits the clusters read by ListOfClustersForOrgPage
+// and the organizations read by ListOfOrgsPage to the organizations having
+// at least one such cluster. Zero values are not used for filtering.
+type ClusterListFilter struct {
+	// ReportedFrom omits clusters whose last report was received by the
+	// aggregator earlier (reported_at column)
+	ReportedFrom time.Time
+	// ReportedTo omits clusters whose last report was received by the
+	// aggregator at this time or later (reported_at column)
+	ReportedTo time.Time
+	// LastCheckedFrom omits clusters last checked earlier, the time of the
+	// check is taken from the report (last_checked_at column)
+	LastCheckedFrom time.Time
+	// LastCheckedTo omits clusters last checked at this time or later
+	// (last_checked_at column)
+	LastCheckedTo time.Time
+	// HasHits keeps only the clusters with (true) or without (false) any rule hit
+	HasHits *bool
+	// RuleID keeps only the clusters hit by the rule
+	RuleID types.RuleID
+	// ErrorKey keeps only the clusters hit by the rule with the error key
+	ErrorKey types.ErrorKey
+	// OCPVersion keeps only the clusters with the OCP version, "4.6" matches
+	// all 4.6.z versions
+	OCPVersion string
+	// Platform keeps only the clusters running on the platform
+	Platform string
+}
+
+// ListPagination specifies order and part of a list to be read
+type ListPagination struct {
+	// SortBy is one of SortBy* constants, the default order is used if empty
+	SortBy     string
+	Descending bool
+	// Limit is the maximum number of items, 0 means no limit
+	Limit  uint64
+	Offset uint64
+}
+
+// whereClause returns SQL WHERE clause for the report table with the
+// conditions of the filter and their arguments, the clusters are limited to
+// the organization if it is not nil
+func (filter ClusterListFilter) whereClause(orgID *types.OrgID) (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if orgID != nil {
+		addCondition("org_id = $%d", *orgID)
+	}
+
+	if !filter.ReportedFrom.IsZero() {
+		addCondition("reported_at >= $%d", filter.ReportedFrom)
+	}
+	if !filter.ReportedTo.IsZero() {
+		addCondition("reported_at < $%d", filter.ReportedTo)
+	}
+	if !filter.LastCheckedFrom.IsZero() {
+		addCondition("last_checked_at >= $%d", filter.LastCheckedFrom)
+	}
+	if !filter.LastCheckedTo.IsZero() {
+		addCondition("last_checked_at < $%d", filter.LastCheckedTo)
+	}
+	if filter.HasHits != nil {
+		condition := `EXISTS (
+			SELECT 1 FROM rule_hit
+			WHERE rule_hit.org_id = report.org_id AND rule_hit.cluster_id = report.cluster
+		)`
+		if !*filter.HasHits {
+			condition = "NOT " + condition
+		}
+		conditions = append(conditions, condition)
+	}
+	if filter.RuleID != "" {
+		args = append(args, filter.RuleID)
+		condition := fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM rule_hit
+			WHERE rule_hit.org_id = report.org_id AND rule_hit.cluster_id = report.cluster
+				AND rule_hit.rule_fqdn = $%d`, len(args))
+		if filter.ErrorKey != "" {
+			args = append(args, filter.ErrorKey)
+			condition += fmt.Sprintf(" AND rule_hit.error_key = $%d", len(args))
+		}
+		conditions = append(conditions, condition+")")
+	}
+	if filter.OCPVersion != "" {
+		args = append(args, filter.OCPVersion, escapeLike(filter.OCPVersion))
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM cluster_metadata
+			WHERE cluster_metadata.cluster = report.cluster
+				AND (cluster_metadata.ocp_version = $%d OR cluster_metadata.ocp_version LIKE $%d || '.%%' ESCAPE '\')
+		)`, len(args)-1, len(args)))
+	}
+	if filter.Platform != "" {
+		addCondition(`EXISTS (
+			SELECT 1 FROM cluster_metadata
+			WHERE cluster_metadata.cluster = report.cluster AND cluster_metadata.platform = $%d
+		)`, filter.Platform)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// orderByClause returns SQL ORDER BY clause for the pagination, items with
+// the same value of the sort column are ordered by the default column
+func (pagination ListPagination) orderByClause(defaultColumn string) string {
+	direction := "ASC"
+	if pagination.Descending {
+		direction = "DESC"
+	}
+
+	clause := fmt.Sprintf(" ORDER BY %s %s", defaultColumn, direction)
+	if pagination.SortBy != "" && pagination.SortBy != defaultColumn {
+		clause = fmt.Sprintf(" ORDER BY %s %s, %s %s", pagination.SortBy, direction, defaultColumn, direction)
+	}
+
+	return clause
+}
+
+// limitOffsetClause returns SQL LIMIT and OFFSET clauses, limit 0 means no
+// limit
+func limitOffsetClause(limit, offset uint64) string {
+	var clause string
+
+	if limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		// OFFSET without LIMIT is not supported by SQLite
+		if limit == 0 {
+			clause += fmt.Sprintf(" LIMIT %d", math.MaxInt64)
+		}
+		clause += fmt.Sprintf(" OFFSET %d", offset)
+	}
+
+	return clause
+}
+
+// validateSortBy checks that the pagination sorts by one of allowed columns
+func (pagination ListPagination) validateSortBy(allowedColumns ...string) error {
+	if pagination.SortBy == "" {
+		return nil
+	}
+
+	for _, column := range allowedColumns {
+		if pagination.SortBy == column {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("sorting by %q is not supported", pagination.SortBy)
+}
+
+// ListOfOrgsPage reads the requested part of the list of organizations that
+// have at least one cluster matching the filter. Total number of such
+// organizations is returned as well.
+func (storage DBStorage) ListOfOrgsPage(
+	filter ClusterListFilter, pagination ListPagination,
+) ([]types.OrgID, int, error) {
+	orgs := make([]types.OrgID, 0)
+
+	err := pagination.validateSortBy(SortByOrgID)
+	if err != nil {
+		return orgs, 0, err
+	}
+
+	whereClause, args := filter.whereClause(nil)
+
+	var total int
+	err = storage.connection.QueryRow(
+		"SELECT COUNT(DISTINCT org_id) FROM report "+whereClause, args...,
+	).Scan(&total)
+	err = types.ConvertDBError(err, nil)
+	if err != nil {
+		return orgs, 0, err
+	}
+
+	rows, err := storage.connection.Query(
+		"SELECT DISTINCT org_id FROM report "+whereClause+
+			pagination.orderByClause(SortByOrgID)+
+			limitOffsetClause(pagination.Limit, pagination.Offset),
+		args...,
+	)
+	err = types.ConvertDBError(err, nil)
+	if err != nil {
+		return orgs, 0, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var orgID types.OrgID
+
+		err = rows.Scan(&orgID)
+		if err != nil {
+			log.Error().Err(err).Msg("ListOfOrgsPage")
+			return orgs, 0, err
+		}
+
+		orgs = append(orgs, orgID)
+	}
+
+	return orgs, total, rows.Err()
+}
+
+// ListOfClustersForOrgPage reads the requested part of the list of clusters
+// for given organization that match the filter. Total number of such clusters
+// is returned as well.
+func (storage DBStorage) ListOfClustersForOrgPage(
+	orgID types.OrgID, filter ClusterListFilter, pagination ListPagination,
+) ([]types.ClusterName, int, error) {
+	clusters := make([]types.ClusterName, 0)
+
+	err := pagination.validateSortBy(SortByCluster, SortByLastChecked)
+	if err != nil {
+		return clusters, 0, err
+	}
+
+	whereClause, args := filter.whereClause(&orgID)
+
+	var total int
+	err = storage.connection.QueryRow(
+		"SELECT COUNT(*) FROM report "+whereClause, args...,
+	).Scan(&total)
+	err = types.ConvertDBError(err, orgID)
+	if err != nil {
+		return clusters, 0, err
+	}
+
+	rows, err := storage.connection.Query(
+		"SELECT cluster FROM report "+whereClause+
+			pagination.orderByClause(SortByCluster)+
+			limitOffsetClause(pagination.Limit, pagination.Offset),
+		args...,
+	)
+	err = types.ConvertDBError(err, orgID)
+	if err != nil {
+		return clusters, 0, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var clusterName types.ClusterName
+
+		err = rows.Scan(&clusterName)
+		if err != nil {
+			log.Error().Err(err).Msg("ListOfClustersForOrgPage")
+			return clusters, 0, err
+		}
+
+		clusters = append(clusters, clusterName)
+	}
+
+	return clusters, total, rows.Err()
+}
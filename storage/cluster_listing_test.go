@@ -0,0 +1,200 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const thirdClusterName = types.ClusterName("33333333-3333-3333-3333-333333333333")
+
+// mustWriteClustersForListing writes three clusters for testdata.OrgID
+// checked one hour apart with 3, 2 and no rule hits respectively and one
+// cluster for testdata.Org2ID
+func mustWriteClustersForListing(t *testing.T, mockStorage storage.Storage) time.Time {
+	lastChecked := time.Now().UTC().Truncate(time.Second)
+
+	writeReports := []struct {
+		orgID       types.OrgID
+		clusterName types.ClusterName
+		rules       []types.ReportItem
+		lastChecked time.Time
+	}{
+		{testdata.OrgID, firstClusterName, testdata.Report3RulesParsed, lastChecked.Add(-2 * time.Hour)},
+		{testdata.OrgID, secondClusterName, testdata.Report2RulesParsed, lastChecked},
+		{testdata.OrgID, thirdClusterName, testdata.ReportEmptyRulesParsed, lastChecked.Add(-time.Hour)},
+		{testdata.Org2ID, testdata.GetRandomClusterID(), testdata.ReportEmptyRulesParsed, lastChecked},
+	}
+	for _, report := range writeReports {
+		err := mockStorage.WriteReportForCluster(
+			report.orgID, report.clusterName, testdata.Report3Rules, report.rules, report.lastChecked, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	return lastChecked
+}
+
+func TestDBStorageListOfClustersForOrgPage(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastChecked := mustWriteClustersForListing(t, mockStorage)
+	hasHits, hasNoHits := true, false
+
+	for _, testCase := range []struct {
+		name             string
+		filter           storage.ClusterListFilter
+		pagination       storage.ListPagination
+		expectedClusters []types.ClusterName
+		expectedTotal    int
+	}{
+		{
+			name:             "all",
+			expectedClusters: []types.ClusterName{firstClusterName, secondClusterName, thirdClusterName},
+			expectedTotal:    3,
+		},
+		{
+			name:             "page",
+			pagination:       storage.ListPagination{Limit: 1, Offset: 1},
+			expectedClusters: []types.ClusterName{secondClusterName},
+			expectedTotal:    3,
+		},
+		{
+			name:             "offset only",
+			pagination:       storage.ListPagination{Offset: 2},
+			expectedClusters: []types.ClusterName{thirdClusterName},
+			expectedTotal:    3,
+		},
+		{
+			name:             "descending",
+			pagination:       storage.ListPagination{Descending: true, Limit: 2},
+			expectedClusters: []types.ClusterName{thirdClusterName, secondClusterName},
+			expectedTotal:    3,
+		},
+		{
+			name:             "by last checked",
+			pagination:       storage.ListPagination{SortBy: storage.SortByLastChecked},
+			expectedClusters: []types.ClusterName{firstClusterName, thirdClusterName, secondClusterName},
+			expectedTotal:    3,
+		},
+		{
+			name: "last checked range",
+			filter: storage.ClusterListFilter{
+				LastCheckedFrom: lastChecked.Add(-time.Hour),
+				LastCheckedTo:   lastChecked,
+			},
+			expectedClusters: []types.ClusterName{thirdClusterName},
+			expectedTotal:    1,
+		},
+		{
+			name:             "has hits",
+			filter:           storage.ClusterListFilter{HasHits: &hasHits},
+			expectedClusters: []types.ClusterName{firstClusterName, secondClusterName},
+			expectedTotal:    2,
+		},
+		{
+			name:             "has no hits",
+			filter:           storage.ClusterListFilter{HasHits: &hasNoHits},
+			expectedClusters: []types.ClusterName{thirdClusterName},
+			expectedTotal:    1,
+		},
+		{
+			name:             "hit by rule",
+			filter:           storage.ClusterListFilter{RuleID: testdata.Rule3ID},
+			expectedClusters: []types.ClusterName{firstClusterName},
+			expectedTotal:    1,
+		},
+		{
+			name:             "hit by rule with error key",
+			filter:           storage.ClusterListFilter{RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey1},
+			expectedClusters: []types.ClusterName{},
+			expectedTotal:    0,
+		},
+		{
+			name:             "reported since",
+			filter:           storage.ClusterListFilter{ReportedFrom: time.Now().Add(time.Hour)},
+			expectedClusters: []types.ClusterName{},
+			expectedTotal:    0,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			clusters, total, err := mockStorage.ListOfClustersForOrgPage(
+				testdata.OrgID, testCase.filter, testCase.pagination,
+			)
+			helpers.FailOnError(t, err)
+
+			assert.Equal(t, testCase.expectedClusters, clusters)
+			assert.Equal(t, testCase.expectedTotal, total)
+		})
+	}
+}
+
+func TestDBStorageListOfClustersForOrgPage_BadSortBy(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, _, err := mockStorage.ListOfClustersForOrgPage(
+		testdata.OrgID, storage.ClusterListFilter{}, storage.ListPagination{SortBy: storage.SortByOrgID},
+	)
+	assert.EqualError(t, err, `sorting by "org_id" is not supported`)
+}
+
+func TestDBStorageListOfOrgsPage(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteClustersForListing(t, mockStorage)
+	hasHits := true
+
+	orgs, total, err := mockStorage.ListOfOrgsPage(storage.ClusterListFilter{}, storage.ListPagination{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgID{testdata.OrgID, testdata.Org2ID}, orgs)
+	assert.Equal(t, 2, total)
+
+	orgs, total, err = mockStorage.ListOfOrgsPage(
+		storage.ClusterListFilter{}, storage.ListPagination{Descending: true, Limit: 1},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgID{testdata.Org2ID}, orgs)
+	assert.Equal(t, 2, total)
+
+	orgs, total, err = mockStorage.ListOfOrgsPage(
+		storage.ClusterListFilter{HasHits: &hasHits}, storage.ListPagination{},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgID{testdata.OrgID}, orgs)
+	assert.Equal(t, 1, total)
+}
+
+func TestDBStorageListOfOrgsPage_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, _, err := mockStorage.ListOfOrgsPage(storage.ClusterListFilter{}, storage.ListPagination{})
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, _, err = mockStorage.ListOfClustersForOrgPage(testdata.OrgID, storage.ClusterListFilter{}, storage.ListPagination{})
+	assert.EqualError(t, err, "sql: database is closed")
+}
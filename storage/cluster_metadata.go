@@ -0,0 +1,111 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ClusterMetadata contains attributes of the cluster extracted from its
+// latest report and from the message the report was received in
+type ClusterMetadata struct {
+	OrgID         types.OrgID         `json:"org_id"`
+	ClusterName   types.ClusterName   `json:"cluster"`
+	OCPVersion    string              `json:"ocp_version"`
+	Platform      string              `json:"platform"`
+	Nodes         int                 `json:"nodes"`
+	MasterNodes   int                 `json:"master_nodes"`
+	WorkerNodes   int                 `json:"worker_nodes"`
+	RequestID     types.RequestID     `json:"request_id"`
+	SchemaVersion types.SchemaVersion `json:"schema_version"`
+	LastCheckedAt time.Time           `json:"last_checked_at"`
+}
+
+// WriteClusterMetadata writes metadata of the cluster into the storage.
+// Metadata from older reports than the stored ones are ignored.
+func (storage DBStorage) WriteClusterMetadata(metadata ClusterMetadata) error {
+	_, err := storage.connection.Exec(`
+		INSERT INTO cluster_metadata (
+			org_id, cluster, ocp_version, platform, nodes, master_nodes, worker_nodes,
+			request_id, schema_version, last_checked_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (cluster) DO UPDATE SET
+			org_id = EXCLUDED.org_id,
+			ocp_version = EXCLUDED.ocp_version,
+			platform = EXCLUDED.platform,
+			nodes = EXCLUDED.nodes,
+			master_nodes = EXCLUDED.master_nodes,
+			worker_nodes = EXCLUDED.worker_nodes,
+			request_id = EXCLUDED.request_id,
+			schema_version = EXCLUDED.schema_version,
+			last_checked_at = EXCLUDED.last_checked_at
+		WHERE cluster_metadata.last_checked_at <= EXCLUDED.last_checked_at
+	`,
+		metadata.OrgID,
+		metadata.ClusterName,
+		metadata.OCPVersion,
+		metadata.Platform,
+		metadata.Nodes,
+		metadata.MasterNodes,
+		metadata.WorkerNodes,
+		metadata.RequestID,
+		metadata.SchemaVersion,
+		metadata.LastCheckedAt,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf(
+			"Unable to write cluster metadata (org: %v, cluster: %v)", metadata.OrgID, metadata.ClusterName,
+		)
+	}
+
+	return err
+}
+
+// ReadClusterMetadata reads metadata of the cluster that belongs to the
+// organization
+func (storage DBStorage) ReadClusterMetadata(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (ClusterMetadata, error) {
+	var metadata ClusterMetadata
+
+	err := storage.connection.QueryRow(`
+		SELECT org_id, cluster, ocp_version, platform, nodes, master_nodes, worker_nodes,
+			request_id, schema_version, last_checked_at
+		FROM cluster_metadata
+		WHERE org_id = $1 AND cluster = $2
+	`, orgID, clusterName).Scan(
+		&metadata.OrgID,
+		&metadata.ClusterName,
+		&metadata.OCPVersion,
+		&metadata.Platform,
+		&metadata.Nodes,
+		&metadata.MasterNodes,
+		&metadata.WorkerNodes,
+		&metadata.RequestID,
+		&metadata.SchemaVersion,
+		&metadata.LastCheckedAt,
+	)
+	if err == sql.ErrNoRows {
+		return metadata, &types.ItemNotFoundError{ItemID: clusterName}
+	}
+
+	return metadata, err
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func mustWriteClusterMetadata(
+	t *testing.T, mockStorage storage.Storage, clusterName types.ClusterName,
+	ocpVersion, platform string, lastChecked time.Time,
+) {
+	err := mockStorage.WriteClusterMetadata(storage.ClusterMetadata{
+		OrgID:         testdata.OrgID,
+		ClusterName:   clusterName,
+		OCPVersion:    ocpVersion,
+		Platform:      platform,
+		Nodes:         3,
+		RequestID:     "request-1",
+		SchemaVersion: 1,
+		LastCheckedAt: lastChecked,
+	})
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageClusterMetadata(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastChecked := time.Now().UTC().Truncate(time.Second)
+
+	mustWriteClusterMetadata(t, mockStorage, testdata.ClusterName, "4.6.1", "AWS", lastChecked)
+
+	metadata, err := mockStorage.ReadClusterMetadata(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, testdata.OrgID, metadata.OrgID)
+	assert.Equal(t, testdata.ClusterName, metadata.ClusterName)
+	assert.Equal(t, "4.6.1", metadata.OCPVersion)
+	assert.Equal(t, "AWS", metadata.Platform)
+	assert.Equal(t, 3, metadata.Nodes)
+	assert.Equal(t, types.RequestID("request-1"), metadata.RequestID)
+	assert.Equal(t, types.SchemaVersion(1), metadata.SchemaVersion)
+	assert.True(t, lastChecked.Equal(metadata.LastCheckedAt))
+
+	// metadata from an older report are ignored
+	mustWriteClusterMetadata(t, mockStorage, testdata.ClusterName, "4.5.0", "AWS", lastChecked.Add(-time.Hour))
+
+	metadata, err = mockStorage.ReadClusterMetadata(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "4.6.1", metadata.OCPVersion)
+
+	mustWriteClusterMetadata(t, mockStorage, testdata.ClusterName, "4.6.2", "AWS", lastChecked.Add(time.Hour))
+
+	metadata, err = mockStorage.ReadClusterMetadata(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "4.6.2", metadata.OCPVersion)
+}
+
+func TestDBStorageClusterMetadataNotFound(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteClusterMetadata(t, mockStorage, testdata.ClusterName, "4.6.1", "AWS", time.Now())
+
+	// the cluster belongs to another organization
+	_, err := mockStorage.ReadClusterMetadata(testdata.Org2ID, testdata.ClusterName)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.ClusterName}, err)
+}
+
+func TestDBStorageListOfClustersForOrgPageByMetadata(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastChecked := mustWriteClustersForListing(t, mockStorage)
+
+	mustWriteClusterMetadata(t, mockStorage, firstClusterName, "4.6.1", "AWS", lastChecked)
+	mustWriteClusterMetadata(t, mockStorage, secondClusterName, "4.6", "GCP", lastChecked)
+	mustWriteClusterMetadata(t, mockStorage, thirdClusterName, "4.60.0", "AWS", lastChecked)
+
+	for _, testCase := range []struct {
+		name             string
+		filter           storage.ClusterListFilter
+		expectedClusters []types.ClusterName
+	}{
+		{"version", storage.ClusterListFilter{OCPVersion: "4.6"}, []types.ClusterName{firstClusterName, secondClusterName}},
+		{"full version", storage.ClusterListFilter{OCPVersion: "4.6.1"}, []types.ClusterName{firstClusterName}},
+		{"wildcard version", storage.ClusterListFilter{OCPVersion: "4%"}, []types.ClusterName{}},
+		{"wildcard character", storage.ClusterListFilter{OCPVersion: "4_6"}, []types.ClusterName{}},
+		{"platform", storage.ClusterListFilter{Platform: "AWS"}, []types.ClusterName{firstClusterName, thirdClusterName}},
+		{"both", storage.ClusterListFilter{OCPVersion: "4.6", Platform: "GCP"}, []types.ClusterName{secondClusterName}},
+		{"unknown", storage.ClusterListFilter{Platform: "Azure"}, []types.ClusterName{}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			clusters, total, err := mockStorage.ListOfClustersForOrgPage(
+				testdata.OrgID, testCase.filter, storage.ListPagination{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, testCase.expectedClusters, clusters)
+			assert.Equal(t, len(testCase.expectedClusters), total)
+		})
+	}
+}
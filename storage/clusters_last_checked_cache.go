@@ -0,0 +1,116 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// DefaultClustersLastCheckedCacheSize is the number of clusters whose
+// timestamps are cached when the size is not configured
+const DefaultClustersLastCheckedCacheSize = 10000
+
+// clusterLastChecked is one item of clustersLastCheckedCache
+type clusterLastChecked struct {
+	clusterName types.ClusterName
+	lastChecked time.Time
+}
+
+// clustersLastCheckedCache is a concurrency-safe cache of timestamps when
+// the clusters were last checked. It has limited size, the least recently
+// used clusters are evicted when it is full.
+type clustersLastCheckedCache struct {
+	mutex sync.Mutex
+	size  int
+	// items contains elements of the order list for each cached cluster
+	items map[types.ClusterName]*list.Element
+	// order contains clusterLastChecked items, the most recently used first
+	order *list.List
+}
+
+// newClustersLastCheckedCache constructs new cache for given number of
+// clusters, the default size is used when it is not positive
+func newClustersLastCheckedCache(size int) *clustersLastCheckedCache {
+	if size <= 0 {
+		size = DefaultClustersLastCheckedCacheSize
+	}
+
+	return &clustersLastCheckedCache{
+		size:  size,
+		items: make(map[types.ClusterName]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get returns timestamp cached for the cluster, false is returned when the
+// cluster is not in the cache
+func (cache *clustersLastCheckedCache) get(clusterName types.ClusterName) (time.Time, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, found := cache.items[clusterName]
+	if !found {
+		metrics.ClustersLastCheckedCacheMisses.Inc()
+		return time.Time{}, false
+	}
+
+	metrics.ClustersLastCheckedCacheHits.Inc()
+	cache.order.MoveToFront(element)
+
+	return element.Value.(*clusterLastChecked).lastChecked, true
+}
+
+// set stores timestamp for the cluster, the least recently used cluster is
+// evicted if the cache is full
+func (cache *clustersLastCheckedCache) set(clusterName types.ClusterName, lastChecked time.Time) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, found := cache.items[clusterName]; found {
+		element.Value.(*clusterLastChecked).lastChecked = lastChecked
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.items[clusterName] = cache.order.PushFront(&clusterLastChecked{
+		clusterName: clusterName,
+		lastChecked: lastChecked,
+	})
+
+	if cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.items, oldest.Value.(*clusterLastChecked).clusterName)
+	}
+}
+
+// remove evicts the clusters from the cache, it's used when their reports
+// are deleted, so the timestamps of the deleted reports are not used anymore
+func (cache *clustersLastCheckedCache) remove(clusterNames ...types.ClusterName) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for _, clusterName := range clusterNames {
+		if element, found := cache.items[clusterName]; found {
+			cache.order.Remove(element)
+			delete(cache.items, clusterName)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/prometheus/client_golang/prometheus"
+	prommodels "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func getCounterValue(t *testing.T, counter prometheus.Counter) float64 {
+	pb := &prommodels.Metric{}
+	helpers.FailOnError(t, counter.Write(pb))
+
+	return pb.GetCounter().GetValue()
+}
+
+// mustGetStorageWithCacheSize returns storage sharing connection with mock
+// storage, but with given size of cache for last checked timestamps
+func mustGetStorageWithCacheSize(t *testing.T, cacheSize int) (*storage.DBStorage, func()) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	return storage.NewFromConnectionWithCacheSize(
+		storage.GetConnection(dbStorage), dbStorage.GetDBDriverType(), cacheSize,
+	), closer
+}
+
+func mustWriteReportAt(t *testing.T, dbStorage *storage.DBStorage, clusterName types.ClusterName, lastChecked time.Time) {
+	err := dbStorage.WriteReportForCluster(
+		testdata.OrgID,
+		clusterName,
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		lastChecked,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestClustersLastCheckedCacheEviction(t *testing.T) {
+	dbStorage, closer := mustGetStorageWithCacheSize(t, 2)
+	defer closer()
+
+	clusters := []types.ClusterName{
+		testdata.GetRandomClusterID(),
+		testdata.GetRandomClusterID(),
+		testdata.GetRandomClusterID(),
+	}
+	lastChecked := time.Now().UTC().Truncate(time.Second)
+
+	for _, clusterName := range clusters {
+		mustWriteReportAt(t, dbStorage, clusterName, lastChecked)
+	}
+
+	// the least recently used cluster was evicted
+	clustersLastChecked := storage.GetClustersLastChecked(dbStorage)
+	assert.Len(t, clustersLastChecked, 2)
+	assert.NotContains(t, clustersLastChecked, clusters[0])
+	assert.Contains(t, clustersLastChecked, clusters[1])
+	assert.Contains(t, clustersLastChecked, clusters[2])
+}
+
+func TestClustersLastCheckedCacheDBFallback(t *testing.T) {
+	dbStorage, closer := mustGetStorageWithCacheSize(t, 1)
+	defer closer()
+
+	evictedCluster := testdata.GetRandomClusterID()
+	lastChecked := time.Now().UTC().Truncate(time.Second)
+
+	mustWriteReportAt(t, dbStorage, evictedCluster, lastChecked)
+	mustWriteReportAt(t, dbStorage, testdata.GetRandomClusterID(), lastChecked)
+
+	hits := getCounterValue(t, metrics.ClustersLastCheckedCacheHits)
+	misses := getCounterValue(t, metrics.ClustersLastCheckedCacheMisses)
+
+	// evicted cluster is read from DB and cached again
+	timestamp, exists := storage.GetClusterLastChecked(dbStorage, evictedCluster)
+	assert.True(t, exists)
+	assert.Equal(t, lastChecked.Unix(), timestamp.Unix())
+	assert.Equal(t, hits, getCounterValue(t, metrics.ClustersLastCheckedCacheHits))
+	assert.Equal(t, misses+1, getCounterValue(t, metrics.ClustersLastCheckedCacheMisses))
+
+	timestamp, exists = storage.GetClusterLastChecked(dbStorage, evictedCluster)
+	assert.True(t, exists)
+	assert.Equal(t, lastChecked.Unix(), timestamp.Unix())
+	assert.Equal(t, hits+1, getCounterValue(t, metrics.ClustersLastCheckedCacheHits))
+	assert.Equal(t, misses+1, getCounterValue(t, metrics.ClustersLastCheckedCacheMisses))
+
+	// older report for the evicted cluster is not written
+	err := dbStorage.WriteReportForCluster(
+		testdata.OrgID,
+		evictedCluster,
+		testdata.ClusterReportEmpty,
+		testdata.ReportEmptyRulesParsed,
+		lastChecked.Add(-time.Hour),
+		testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	report, lastCheckedTimestamp, err := dbStorage.ReadReportForCluster(testdata.OrgID, evictedCluster)
+	helpers.FailOnError(t, err)
+	assert.Len(t, report, len(testdata.Report2RulesParsed))
+	assert.Equal(t, types.Timestamp(lastChecked.Format(time.RFC3339)), lastCheckedTimestamp)
+}
+
+func TestClustersLastCheckedCacheUnknownCluster(t *testing.T) {
+	dbStorage, closer := mustGetStorageWithCacheSize(t, 1)
+	defer closer()
+
+	_, exists := storage.GetClusterLastChecked(dbStorage, testdata.GetRandomClusterID())
+	assert.False(t, exists)
+}
+
+func TestDBStorage_Init_PreloadsMostRecentClusters(t *testing.T) {
+	dbStorage, closer := mustGetStorageWithCacheSize(t, 2)
+	defer closer()
+
+	olderCluster := testdata.GetRandomClusterID()
+	newerClusters := []types.ClusterName{
+		testdata.GetRandomClusterID(),
+		testdata.GetRandomClusterID(),
+	}
+	lastChecked := time.Now().UTC().Truncate(time.Second)
+
+	mustWriteReportAt(t, dbStorage, olderCluster, lastChecked.Add(-time.Hour))
+	for _, clusterName := range newerClusters {
+		mustWriteReportAt(t, dbStorage, clusterName, lastChecked)
+	}
+
+	dbStorage = storage.NewFromConnectionWithCacheSize(
+		storage.GetConnection(dbStorage), dbStorage.GetDBDriverType(), 2,
+	)
+	helpers.FailOnError(t, dbStorage.Init())
+
+	clustersLastChecked := storage.GetClustersLastChecked(dbStorage)
+	assert.Len(t, clustersLastChecked, 2)
+	assert.NotContains(t, clustersLastChecked, olderCluster)
+	for _, clusterName := range newerClusters {
+		assert.Equal(t, lastChecked.Unix(), clustersLastChecked[clusterName].Unix())
+	}
+}
+
+// TestClustersLastCheckedCacheRemovedOnDelete checks that timestamps of
+// deleted reports are evicted, so older reports can be written again
+func TestClustersLastCheckedCacheRemovedOnDelete(t *testing.T) {
+	type testCase struct {
+		name   string
+		delete func(dbStorage *storage.DBStorage, clusterName types.ClusterName) error
+	}
+
+	testCases := []testCase{
+		{
+			name: "delete reports for cluster",
+			delete: func(dbStorage *storage.DBStorage, clusterName types.ClusterName) error {
+				return dbStorage.DeleteReportsForCluster(clusterName)
+			},
+		},
+		{
+			name: "delete reports for organization",
+			delete: func(dbStorage *storage.DBStorage, _ types.ClusterName) error {
+				return dbStorage.DeleteReportsForOrg(testdata.OrgID)
+			},
+		},
+		{
+			name: "erase cluster data",
+			delete: func(dbStorage *storage.DBStorage, clusterName types.ClusterName) error {
+				_, err := dbStorage.EraseClusterData(clusterName)
+				return err
+			},
+		},
+		{
+			name: "erase organization data",
+			delete: func(dbStorage *storage.DBStorage, _ types.ClusterName) error {
+				_, err := dbStorage.EraseOrgData(testdata.OrgID)
+				return err
+			},
+		},
+		{
+			name: "retention policy",
+			delete: func(dbStorage *storage.DBStorage, _ types.ClusterName) error {
+				_, err := dbStorage.ApplyRetentionPolicy(time.Now().Add(time.Hour), time.Time{}, false)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbStorage, closer := mustGetStorageWithCacheSize(t, 10)
+			defer closer()
+
+			clusterName := testdata.GetRandomClusterID()
+			lastChecked := time.Now().UTC().Truncate(time.Second)
+
+			mustWriteReportAt(t, dbStorage, clusterName, lastChecked)
+			assert.Contains(t, storage.GetClustersLastChecked(dbStorage), clusterName)
+
+			helpers.FailOnError(t, tc.delete(dbStorage, clusterName))
+			assert.NotContains(t, storage.GetClustersLastChecked(dbStorage), clusterName)
+
+			// older report is not rejected because of the deleted one
+			mustWriteReportAt(t, dbStorage, clusterName, lastChecked.Add(-time.Hour))
+		})
+	}
+}
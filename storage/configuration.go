@@ -27,4 +27,20 @@ type Configuration struct {
 	PGPort           int    `mapstructure:"pg_port" toml:"pg_port"`
 	PGDBName         string `mapstructure:"pg_db_name" toml:"pg_db_name"`
 	PGParams         string `mapstructure:"pg_params" toml:"pg_params"`
+	// ClustersLastCheckedCacheSize is the maximum number of clusters whose
+	// last checked timestamps are cached in memory
+	ClustersLastCheckedCacheSize int `mapstructure:"clusters_last_checked_cache_size" toml:"clusters_last_checked_cache_size"`
+}
+
+// RetentionConfiguration represents policy of removing stale data from the
+// storage, zero values mean that the data are kept forever
+type RetentionConfiguration struct {
+	// ReportMaxAgeDays is the maximum age of the latest report of a cluster,
+	// reports of clusters that stopped reporting earlier are removed
+	ReportMaxAgeDays int `mapstructure:"report_max_age_days" toml:"report_max_age_days"`
+	// ConsumerErrorMaxAgeDays is the maximum age of stored consumer errors
+	ConsumerErrorMaxAgeDays int `mapstructure:"consumer_error_max_age_days" toml:"consumer_error_max_age_days"`
+	// CleanupIntervalHours is the interval in which the service enforces
+	// the policy, zero means that it's enforced only by the cleanup command
+	CleanupIntervalHours int `mapstructure:"cleanup_interval_hours" toml:"cleanup_interval_hours"`
 }
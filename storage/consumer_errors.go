@@ -0,0 +1,256 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ConsumerErrorReplayStatus represents result of the last attempt to replay
+// the message that the consumer was unable to process
+type ConsumerErrorReplayStatus string
+
+const (
+	// ConsumerErrorNotReplayed is used for messages that were not replayed yet
+	ConsumerErrorNotReplayed ConsumerErrorReplayStatus = ""
+	// ConsumerErrorReplayed is used for messages that were processed successfully when replayed
+	ConsumerErrorReplayed ConsumerErrorReplayStatus = "replayed"
+	// ConsumerErrorReplayFailing is used for messages that still can't be processed
+	ConsumerErrorReplayFailing ConsumerErrorReplayStatus = "failing"
+)
+
+// ConsumerError represents a message that the consumer was unable to process
+// together with the error that happened
+type ConsumerError struct {
+	Topic        string                    `json:"topic"`
+	Partition    int32                     `json:"partition"`
+	Offset       int64                     `json:"offset"`
+	Key          string                    `json:"key"`
+	ProducedAt   time.Time                 `json:"produced_at"`
+	ConsumedAt   time.Time                 `json:"consumed_at"`
+	Message      string                    `json:"message,omitempty"`
+	Error        string                    `json:"error"`
+	ReplayStatus ConsumerErrorReplayStatus `json:"replay_status"`
+	ReplayedAt   *time.Time                `json:"replayed_at"`
+	ReplayError  string                    `json:"replay_error"`
+}
+
+// ConsumerErrorFilter selects consumer errors to be read from the storage,
+// zero values mean that the attribute is not used for filtering
+type ConsumerErrorFilter struct {
+	// From and To limit the time when the message was consumed
+	From time.Time
+	To   time.Time
+	// Topic is the topic the message was consumed from
+	Topic string
+	// Partition is the partition the message was consumed from, nil means any
+	Partition *int32
+	// ErrorContains is a substring of the error
+	ErrorContains string
+	// SkipReplayed excludes messages that were replayed successfully
+	SkipReplayed bool
+	// Limit and Skip are used for pagination: Limit is the maximum number
+	// of records to be read and Skip is the number of records to be skipped.
+	// They are not used when consumer errors are deleted.
+	Limit uint64
+	Skip  uint64
+}
+
+// whereClause returns SQL condition and its arguments for the filter
+func (filter ConsumerErrorFilter) whereClause() (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if !filter.From.IsZero() {
+		addCondition("consumed_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("consumed_at < $%d", filter.To)
+	}
+	if filter.Topic != "" {
+		addCondition("topic = $%d", filter.Topic)
+	}
+	if filter.Partition != nil {
+		addCondition("partition = $%d", *filter.Partition)
+	}
+	if filter.ErrorContains != "" {
+		addCondition(`error LIKE '%%' || $%d || '%%' ESCAPE '\'`, escapeLike(filter.ErrorContains))
+	}
+	if filter.SkipReplayed {
+		addCondition("replay_status <> $%d", ConsumerErrorReplayed)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// paginationClause returns SQL LIMIT and OFFSET clauses for the filter
+func (filter ConsumerErrorFilter) paginationClause() string {
+	return limitOffsetClause(filter.Limit, filter.Skip)
+}
+
+const selectConsumerErrorColumns = `
+	SELECT topic, partition, topic_offset, key, produced_at, consumed_at, message, error,
+		replay_status, replayed_at, replay_error
+	FROM consumer_error
+`
+
+// scanConsumerError reads one consumer_error row selected with
+// selectConsumerErrorColumns
+func scanConsumerError(row interface{ Scan(...interface{}) error }) (ConsumerError, error) {
+	var (
+		consumerError ConsumerError
+		key           sql.NullString
+		message       sql.NullString
+		replayedAt    sql.NullTime
+	)
+
+	err := row.Scan(
+		&consumerError.Topic,
+		&consumerError.Partition,
+		&consumerError.Offset,
+		&key,
+		&consumerError.ProducedAt,
+		&consumerError.ConsumedAt,
+		&message,
+		&consumerError.Error,
+		&consumerError.ReplayStatus,
+		&replayedAt,
+		&consumerError.ReplayError,
+	)
+	if err != nil {
+		return consumerError, err
+	}
+
+	consumerError.Key = key.String
+	consumerError.Message = message.String
+	if replayedAt.Valid {
+		consumerError.ReplayedAt = &replayedAt.Time
+	}
+
+	return consumerError, nil
+}
+
+// ReadConsumerErrors reads messages that the consumer was unable to process,
+// the oldest one goes first
+func (storage DBStorage) ReadConsumerErrors(filter ConsumerErrorFilter) ([]ConsumerError, error) {
+	consumerErrors := make([]ConsumerError, 0)
+
+	where, args := filter.whereClause()
+
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	query := selectConsumerErrorColumns + where +
+		" ORDER BY consumed_at, topic, partition, topic_offset" + filter.paginationClause() + ";"
+
+	rows, err := storage.connection.Query(query, args...)
+	err = types.ConvertDBError(err, nil)
+	if err != nil {
+		return consumerErrors, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		consumerError, err := scanConsumerError(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadConsumerErrors")
+			return consumerErrors, err
+		}
+
+		consumerErrors = append(consumerErrors, consumerError)
+	}
+
+	return consumerErrors, rows.Err()
+}
+
+// ReadConsumerError reads one message that the consumer was unable to
+// process, identified by its topic, partition and offset
+func (storage DBStorage) ReadConsumerError(topic string, partition int32, offset int64) (ConsumerError, error) {
+	itemID := fmt.Sprintf("%v/%v/%v", topic, partition, offset)
+
+	row := storage.connection.QueryRow(
+		selectConsumerErrorColumns+"WHERE topic = $1 AND partition = $2 AND topic_offset = $3;",
+		topic, partition, offset,
+	)
+
+	consumerError, err := scanConsumerError(row)
+	return consumerError, types.ConvertDBError(err, itemID)
+}
+
+// DeleteConsumerErrors deletes messages that the consumer was unable to
+// process selected by the filter and returns the number of deleted records.
+// Pagination attributes of the filter are ignored.
+func (storage DBStorage) DeleteConsumerErrors(filter ConsumerErrorFilter) (int64, error) {
+	where, args := filter.whereClause()
+
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	result, err := storage.connection.Exec("DELETE FROM consumer_error "+where+";", args...)
+	err = types.ConvertDBError(err, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// MarkConsumerErrorReplayed stores the result of replaying the message that
+// the consumer was unable to process. Nil replayErr means that the message
+// has been processed successfully.
+func (storage DBStorage) MarkConsumerErrorReplayed(
+	topic string, partition int32, offset int64, replayErr error,
+) error {
+	status, replayError := ConsumerErrorReplayed, ""
+	if replayErr != nil {
+		status, replayError = ConsumerErrorReplayFailing, replayErr.Error()
+	}
+
+	result, err := storage.connection.Exec(`
+		UPDATE consumer_error
+		SET replay_status = $1, replayed_at = $2, replay_error = $3
+		WHERE topic = $4 AND partition = $5 AND topic_offset = $6;
+	`, status, time.Now().UTC(), replayError, topic, partition, offset)
+	if err != nil {
+		return err
+	}
+
+	numberOfAffectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if numberOfAffectedRows == 0 {
+		return &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v/%v/%v", topic, partition, offset)}
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const testTopic = "ccx.ocp.results"
+
+func mustWriteConsumerError(t testing.TB, mockStorage storage.Storage, offset int64, consumerErr string) {
+	err := mockStorage.WriteConsumerError(&broker.Message{
+		Topic:     testTopic,
+		Partition: 1,
+		Offset:    offset,
+		Key:       []byte("key"),
+		Value:     []byte(testdata.ConsumerMessage),
+		Timestamp: testdata.LastCheckedAt,
+	}, errors.New(consumerErr))
+	helpers.FailOnError(t, err)
+}
+
+// mustWriteConsumerErrorMessage writes consumer error of the given (possibly
+// malformed) message
+func mustWriteConsumerErrorMessage(t testing.TB, mockStorage storage.Storage, offset int64, message string) {
+	err := mockStorage.WriteConsumerError(&broker.Message{
+		Topic:     testTopic,
+		Partition: 1,
+		Offset:    offset,
+		Key:       []byte("key"),
+		Value:     []byte(message),
+		Timestamp: testdata.LastCheckedAt,
+	}, errors.New("unexpected EOF"))
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageReadConsumerErrorsEmpty(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, consumerErrors)
+}
+
+func TestDBStorageReadConsumerErrors(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+	mustWriteConsumerError(t, mockStorage, 2, "database is locked")
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 2)
+	assert.Equal(t, testTopic, consumerErrors[0].Topic)
+	assert.Equal(t, int32(1), consumerErrors[0].Partition)
+	assert.Equal(t, int64(1), consumerErrors[0].Offset)
+	assert.Equal(t, "key", consumerErrors[0].Key)
+	assert.Equal(t, testdata.ConsumerMessage, consumerErrors[0].Message)
+	assert.Equal(t, "unexpected EOF", consumerErrors[0].Error)
+	assert.Equal(t, storage.ConsumerErrorNotReplayed, consumerErrors[0].ReplayStatus)
+	assert.Nil(t, consumerErrors[0].ReplayedAt)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{
+		Topic:         testTopic,
+		ErrorContains: "locked",
+		From:          time.Now().Add(-time.Hour),
+		To:            time.Now().Add(time.Hour),
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, int64(2), consumerErrors[0].Offset)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{
+		Topic: "another.topic",
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, consumerErrors)
+}
+
+// TestDBStorageReadConsumerErrorsWildcards checks that LIKE wildcards in the
+// searched error are matched literally
+func TestDBStorageReadConsumerErrorsWildcards(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+	mustWriteConsumerError(t, mockStorage, 2, `disk 100% full, path C:\data_1`)
+
+	for _, errorContains := range []string{"%", "_", `\`, "100% full", `C:\data_1`} {
+		consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{
+			ErrorContains: errorContains,
+		})
+		helpers.FailOnError(t, err)
+
+		assert.Len(t, consumerErrors, 1, errorContains)
+	}
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{ErrorContains: "unexpected_EOF"})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, consumerErrors)
+
+	deleted, err := mockStorage.DeleteConsumerErrors(storage.ConsumerErrorFilter{ErrorContains: "%"})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+// TestDBStorageWriteConsumerErrorSameOffset checks that error of the message
+// consumed from the same position again replaces the stored one
+func TestDBStorageWriteConsumerErrorSameOffset(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+
+	err := mockStorage.MarkConsumerErrorReplayed(testTopic, 1, 1, errors.New("still failing"))
+	helpers.FailOnError(t, err)
+
+	mustWriteConsumerError(t, mockStorage, 1, "database is locked")
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, "database is locked", consumerErrors[0].Error)
+	assert.Equal(t, storage.ConsumerErrorNotReplayed, consumerErrors[0].ReplayStatus)
+	assert.Nil(t, consumerErrors[0].ReplayedAt)
+	assert.Empty(t, consumerErrors[0].ReplayError)
+}
+
+func TestDBStorageMarkConsumerErrorReplayed(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+	mustWriteConsumerError(t, mockStorage, 2, "database is locked")
+
+	err := mockStorage.MarkConsumerErrorReplayed(testTopic, 1, 1, nil)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.MarkConsumerErrorReplayed(testTopic, 1, 2, errors.New("still locked"))
+	helpers.FailOnError(t, err)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 2)
+	assert.Equal(t, storage.ConsumerErrorReplayed, consumerErrors[0].ReplayStatus)
+	assert.NotNil(t, consumerErrors[0].ReplayedAt)
+	assert.Empty(t, consumerErrors[0].ReplayError)
+	assert.Equal(t, storage.ConsumerErrorReplayFailing, consumerErrors[1].ReplayStatus)
+	assert.NotNil(t, consumerErrors[1].ReplayedAt)
+	assert.Equal(t, "still locked", consumerErrors[1].ReplayError)
+
+	// successfully replayed messages are skipped
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{SkipReplayed: true})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, int64(2), consumerErrors[0].Offset)
+}
+
+func TestDBStorageMarkConsumerErrorReplayedNotFound(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.MarkConsumerErrorReplayed(testTopic, 1, 42, nil)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testTopic + "/1/42"}, err)
+}
+
+func TestDBStorageReadConsumerErrorsClosedStorage(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	// we need to close storage right now
+	closer()
+
+	_, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	assert.EqualError(t, err, "sql: database is closed")
+
+	err = mockStorage.MarkConsumerErrorReplayed(testTopic, 1, 1, nil)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadConsumerError(testTopic, 1, 1)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.DeleteConsumerErrors(storage.ConsumerErrorFilter{})
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageReadConsumerErrorsPagination(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	for offset := int64(1); offset <= 5; offset++ {
+		mustWriteConsumerError(t, mockStorage, offset, "unexpected EOF")
+	}
+
+	partition := int32(1)
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{
+		Partition: &partition,
+		Limit:     2,
+		Skip:      1,
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 2)
+	assert.Equal(t, int64(2), consumerErrors[0].Offset)
+	assert.Equal(t, int64(3), consumerErrors[1].Offset)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{Skip: 3})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 2)
+	assert.Equal(t, int64(4), consumerErrors[0].Offset)
+
+	partition = 0
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{Partition: &partition})
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, consumerErrors)
+}
+
+func TestDBStorageReadConsumerError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+
+	consumerError, err := mockStorage.ReadConsumerError(testTopic, 1, 1)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, testdata.ConsumerMessage, consumerError.Message)
+	assert.Equal(t, "unexpected EOF", consumerError.Error)
+
+	_, err = mockStorage.ReadConsumerError(testTopic, 1, 2)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testTopic + "/1/2"}, err)
+}
+
+func TestDBStorageDeleteConsumerErrors(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+	mustWriteConsumerError(t, mockStorage, 2, "database is locked")
+	mustWriteConsumerError(t, mockStorage, 3, "database is locked")
+
+	deleted, err := mockStorage.DeleteConsumerErrors(storage.ConsumerErrorFilter{ErrorContains: "locked"})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, int64(1), consumerErrors[0].Offset)
+}
@@ -0,0 +1,300 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ErasureResult contains numbers of rows removed from each table by the
+// erasure of organization or cluster data
+type ErasureResult map[string]int64
+
+// erasureStep removes rows from the table selected by the condition with the
+// only argument, the organization ID or the cluster name
+type erasureStep struct {
+	table     string
+	condition string
+}
+
+// clusterDataErasureSteps remove data stored for clusters without the
+// organization ID
+var clusterDataErasureSteps = []erasureStep{
+	{"cluster_rule_toggle", "cluster_id = $1"},
+	{"cluster_rule_user_feedback", "cluster_id = $1"},
+	{"cluster_user_rule_disable_feedback", "cluster_id = $1"},
+	// consumer errors are stored unparsed, the cluster is searched in the message
+	{"consumer_error", "message LIKE '%' || $1 || '%'"},
+}
+
+// orgErasureSteps remove data stored with the organization ID
+var orgErasureSteps = []erasureStep{
+	{"report", "org_id = $1"},
+	{"cluster_metadata", "org_id = $1"},
+	{"rule_hit", "org_id = $1"},
+	{"report_history", "org_id = $1"},
+	{"rule_hit_history", "org_id = $1"},
+	{"rule_hit_event", "org_id = $1"},
+	{"rule_ack", "org_id = $1"},
+}
+
+// clusterErasureSteps remove data stored with the cluster name. Data without
+// the organization ID go first, user feedback still references the report
+// with cascade delete and it wouldn't be counted otherwise.
+var clusterErasureSteps = append(append([]erasureStep{}, clusterDataErasureSteps...), []erasureStep{
+	{"report", "cluster = $1"},
+	{"cluster_metadata", "cluster = $1"},
+	{"rule_hit", "cluster_id = $1"},
+	{"report_history", "cluster = $1"},
+	{"rule_hit_history", "cluster_id = $1"},
+	{"rule_hit_event", "cluster_id = $1"},
+}...)
+
+// erasureUserID is recorded in the audit log when the erasure is made
+// without audit info, e.g. by the erase command of the service
+const erasureUserID = types.UserID("erase")
+
+// consumerErrorOrgIDPattern finds organization ID in the unparsed message of
+// consumer error, the ID might be sent as a number or as a string
+var consumerErrorOrgIDPattern = regexp.MustCompile(`"OrgID"\s*:\s*"?(\d+)`)
+
+// EraseOrgData removes all data of the organization and its clusters from
+// all tables in one transaction, including history, user feedback and
+// consumer errors. The audit log is append-only and it's kept. Numbers of
+// removed rows are returned per table.
+func (storage DBStorage) EraseOrgData(orgID types.OrgID) (ErasureResult, error) {
+	result := newErasureResult(orgErasureSteps, clusterDataErasureSteps)
+
+	var clusters []types.ClusterName
+
+	err := storage.erase(orgForAudit(orgID), AuditActionEraseOrgData, fmt.Sprint(orgID), result, func(tx *sql.Tx) error {
+		var err error
+
+		clusters, err = readClustersForErasure(tx, orgID)
+		if err != nil {
+			return err
+		}
+
+		for _, cluster := range clusters {
+			err = eraseRows(tx, result, clusterDataErasureSteps, cluster)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = eraseConsumerErrorsOfOrg(tx, result, orgID)
+		if err != nil {
+			return err
+		}
+
+		return eraseRows(tx, result, orgErasureSteps, orgID)
+	})
+	if err == nil {
+		storage.forgetClustersLastChecked(clusters...)
+	}
+
+	return result, err
+}
+
+// EraseClusterData removes all data of the cluster from all tables in one
+// transaction. Numbers of removed rows are returned per table.
+func (storage DBStorage) EraseClusterData(clusterName types.ClusterName) (ErasureResult, error) {
+	result := newErasureResult(clusterErasureSteps)
+
+	err := storage.erase(clusterOrgForAudit(clusterName), AuditActionEraseClusterData, string(clusterName), result, func(tx *sql.Tx) error {
+		return eraseRows(tx, result, clusterErasureSteps, clusterName)
+	})
+	if err == nil {
+		storage.forgetClustersLastChecked(clusterName)
+	}
+
+	return result, err
+}
+
+// newErasureResult returns result with zero counts for all tables touched by
+// the erasure steps
+func newErasureResult(stepLists ...[]erasureStep) ErasureResult {
+	result := make(ErasureResult)
+	for _, steps := range stepLists {
+		for _, step := range steps {
+			result[step.table] = 0
+		}
+	}
+
+	return result
+}
+
+// erase executes the erasure in a transaction and records it into the audit
+// log together with numbers of removed rows. The erasure is always recorded,
+// erasure user is used when the storage has no audit info.
+func (storage DBStorage) erase(
+	org auditOrg, action AuditAction, target string, result ErasureResult, erasure func(tx *sql.Tx) error,
+) error {
+	if storage.auditInfo == nil {
+		storage.auditInfo = &AuditInfo{UserID: erasureUserID}
+	}
+
+	tx, err := storage.connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = func(tx *sql.Tx) error {
+		orgID, err := org(tx)
+		if err != nil {
+			return err
+		}
+
+		err = erasure(tx)
+		if err != nil {
+			return err
+		}
+
+		return storage.writeAuditLog(tx, orgID, action, target, result, nil)
+	}(tx)
+
+	finishTransaction(tx, err)
+
+	return err
+}
+
+// eraseRows executes the erasure steps with the given argument and adds
+// numbers of removed rows to the result
+func eraseRows(tx *sql.Tx, result ErasureResult, steps []erasureStep, arg interface{}) error {
+	for _, step := range steps {
+		res, err := tx.Exec("DELETE FROM "+step.table+" WHERE "+step.condition, arg)
+		if err != nil {
+			log.Error().Err(err).Str("table", step.table).Msg("Unable to erase data")
+			return err
+		}
+
+		count, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		result[step.table] += count
+	}
+
+	return nil
+}
+
+// eraseConsumerErrorsOfOrg removes consumer errors with messages sent by the
+// organization. Messages are stored unparsed and they might not be valid
+// JSON, so the organization ID is searched in the text of the message.
+func eraseConsumerErrorsOfOrg(tx *sql.Tx, result ErasureResult, orgID types.OrgID) error {
+	type consumerErrorKey struct {
+		topic     string
+		partition int32
+		offset    int64
+	}
+
+	rows, err := tx.Query(`
+		SELECT topic, partition, topic_offset, message
+		  FROM consumer_error
+		 WHERE message LIKE '%"OrgID"%'
+	`)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]consumerErrorKey, 0)
+	for rows.Next() {
+		var (
+			key     consumerErrorKey
+			message string
+		)
+
+		err = rows.Scan(&key.topic, &key.partition, &key.offset, &message)
+		if err != nil {
+			log.Error().Err(err).Msg("eraseConsumerErrorsOfOrg")
+			closeRows(rows)
+			return err
+		}
+
+		if messageHasOrgID(message, orgID) {
+			keys = append(keys, key)
+		}
+	}
+	closeRows(rows)
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		_, err = tx.Exec(`
+			DELETE FROM consumer_error
+			 WHERE topic = $1 AND partition = $2 AND topic_offset = $3
+		`, key.topic, key.partition, key.offset)
+		if err != nil {
+			log.Error().Err(err).Str("table", "consumer_error").Msg("Unable to erase data")
+			return err
+		}
+	}
+
+	result["consumer_error"] += int64(len(keys))
+
+	return nil
+}
+
+// messageHasOrgID checks whether the unparsed message contains the
+// organization ID
+func messageHasOrgID(message string, orgID types.OrgID) bool {
+	for _, match := range consumerErrorOrgIDPattern.FindAllStringSubmatch(message, -1) {
+		if match[1] == strconv.FormatUint(uint64(orgID), 10) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readClustersForErasure reads names of all clusters of the organization,
+// including clusters that are kept in the history only
+func readClustersForErasure(tx *sql.Tx, orgID types.OrgID) ([]types.ClusterName, error) {
+	rows, err := tx.Query(`
+		SELECT cluster FROM report WHERE org_id = $1
+		UNION SELECT cluster FROM report_history WHERE org_id = $1
+		UNION SELECT cluster_id FROM rule_hit WHERE org_id = $1
+		UNION SELECT cluster_id FROM rule_hit_event WHERE org_id = $1
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	clusters := make([]types.ClusterName, 0)
+	for rows.Next() {
+		var cluster types.ClusterName
+
+		err = rows.Scan(&cluster)
+		if err != nil {
+			log.Error().Err(err).Msg("readClustersForErasure")
+			return nil, err
+		}
+
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters, rows.Err()
+}
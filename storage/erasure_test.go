@@ -0,0 +1,200 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const otherOrgClusterName = types.ClusterName("22222222-3333-4444-5555-666666666666")
+
+// mustWriteDataForErasure writes data of the cluster into all tables
+func mustWriteDataForErasure(t *testing.T, mockStorage storage.Storage, clusterName types.ClusterName) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, clusterName, testdata.Report3Rules, testdata.Report3RulesParsed, time.Now(), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	mustWriteClusterMetadata(t, mockStorage, clusterName, "4.6.1", "AWS", time.Now())
+
+	audited := mockStorage.WithAuditInfo(testAuditInfo)
+
+	err = audited.VoteOnRule(clusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike, "")
+	helpers.FailOnError(t, err)
+
+	err = audited.AddFeedbackOnRuleDisable(clusterName, testdata.Rule1ID, testdata.UserID, "feedback")
+	helpers.FailOnError(t, err)
+
+	err = audited.ToggleRuleForCluster(clusterName, testdata.Rule1ID, storage.RuleToggleDisable)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageEraseOrgData(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteDataForErasure(t, mockStorage, testdata.ClusterName)
+	mustWriteDataForErasure(t, mockStorage, recentClusterName)
+	// the consumer message contains testdata.ClusterName
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+	// the cluster of consumer message is unknown, only organization matches
+	mustWriteConsumerErrorMessage(t, mockStorage, 2, `{"OrgID": 1, "ClusterName": "`+string(otherOrgClusterName)+`"`)
+	// consumer messages of other organizations are kept
+	mustWriteConsumerErrorMessage(t, mockStorage, 3, `{"OrgID": "2", "ClusterName": "`+string(otherOrgClusterName)+`"`)
+
+	err := mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, "justification")
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportForCluster(
+		testdata.Org2ID, otherOrgClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, time.Now(), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	result, err := mockStorage.EraseOrgData(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, storage.ErasureResult{
+		"report":                             2,
+		"cluster_metadata":                   2,
+		"rule_hit":                           6,
+		"report_history":                     2,
+		"rule_hit_history":                   6,
+		"rule_hit_event":                     6,
+		"rule_ack":                           1,
+		"cluster_rule_toggle":                2,
+		"cluster_rule_user_feedback":         2,
+		"cluster_user_rule_disable_feedback": 2,
+		"consumer_error":                     2,
+	}, result)
+
+	clusters, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, clusters)
+
+	_, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	_, err = mockStorage.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, int64(3), consumerErrors[0].Offset)
+
+	// data of other organizations are kept
+	clusters, err = mockStorage.ListOfClustersForOrg(testdata.Org2ID, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{otherOrgClusterName}, clusters)
+}
+
+func TestDBStorageEraseOrgDataAudit(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteDataForErasure(t, mockStorage, testdata.ClusterName)
+
+	_, err := mockStorage.WithAuditInfo(testAuditInfo).EraseOrgData(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	// the audit log is kept and the erasure itself is appended to it
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 4)
+
+	erasure := entries[0]
+	assert.Equal(t, storage.AuditActionEraseOrgData, erasure.Action)
+	assert.Equal(t, testAuditInfo.UserID, erasure.UserID)
+	assert.Equal(t, "1", erasure.Target)
+	assert.Contains(t, string(erasure.Before), `"report":1`)
+	assert.Nil(t, erasure.After)
+}
+
+func TestDBStorageEraseClusterDataAuditWithoutInfo(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteDataForErasure(t, mockStorage, testdata.ClusterName)
+
+	_, err := mockStorage.EraseClusterData(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	// the erasure is recorded even without audit info
+	entries, err := mockStorage.ReadAuditLog(storage.AuditLogFilter{Action: storage.AuditActionEraseClusterData})
+	helpers.FailOnError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, types.UserID("erase"), entries[0].UserID)
+	assert.Equal(t, string(testdata.ClusterName), entries[0].Target)
+}
+
+func TestDBStorageEraseClusterData(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteDataForErasure(t, mockStorage, testdata.ClusterName)
+	mustWriteDataForErasure(t, mockStorage, recentClusterName)
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+
+	err := mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, "justification")
+	helpers.FailOnError(t, err)
+
+	result, err := mockStorage.EraseClusterData(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, storage.ErasureResult{
+		"report":                             1,
+		"cluster_metadata":                   1,
+		"rule_hit":                           3,
+		"report_history":                     1,
+		"rule_hit_history":                   3,
+		"rule_hit_event":                     3,
+		"cluster_rule_toggle":                1,
+		"cluster_rule_user_feedback":         1,
+		"cluster_user_rule_disable_feedback": 1,
+		"consumer_error":                     1,
+	}, result)
+
+	clusters, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{recentClusterName}, clusters)
+
+	// organization-wide data are kept
+	acks, err := mockStorage.ReadRuleAcksForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, acks, 1)
+
+	_, err = mockStorage.GetFromClusterRuleToggle(recentClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageEraseDBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.EraseOrgData(testdata.OrgID)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.EraseClusterData(testdata.ClusterName)
+	assert.EqualError(t, err, "sql: database is closed")
+}
@@ -49,5 +49,22 @@ func GetConnection(storage *DBStorage) *sql.DB {
 }
 
 func GetClustersLastChecked(storage *DBStorage) map[types.ClusterName]time.Time {
-	return storage.clustersLastChecked
+	cache := storage.clustersLastChecked
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	clustersLastChecked := make(map[types.ClusterName]time.Time, len(cache.items))
+	for clusterName, element := range cache.items {
+		clustersLastChecked[clusterName] = element.Value.(*clusterLastChecked).lastChecked
+	}
+
+	return clustersLastChecked
+}
+
+func NewFromConnectionWithCacheSize(connection *sql.DB, dbDriverType types.DBDriver, cacheSize int) *DBStorage {
+	return newFromConnection(connection, dbDriverType, cacheSize)
+}
+
+func GetClusterLastChecked(storage *DBStorage, clusterName types.ClusterName) (time.Time, bool) {
+	return storage.getClusterLastChecked(clusterName)
 }
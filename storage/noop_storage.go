@@ -18,8 +18,8 @@ import (
 	"time"
 
 	"github.com/RedHatInsights/insights-content-service/content"
-	"github.com/Shopify/sarama"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -46,6 +46,18 @@ func (*NoopStorage) ListOfClustersForOrg(types.OrgID, time.Time) ([]types.Cluste
 	return nil, nil
 }
 
+// ListOfOrgsPage noop
+func (*NoopStorage) ListOfOrgsPage(ClusterListFilter, ListPagination) ([]types.OrgID, int, error) {
+	return nil, 0, nil
+}
+
+// ListOfClustersForOrgPage noop
+func (*NoopStorage) ListOfClustersForOrgPage(
+	types.OrgID, ClusterListFilter, ListPagination,
+) ([]types.ClusterName, int, error) {
+	return nil, 0, nil
+}
+
 // ReadReportForCluster noop
 func (*NoopStorage) ReadReportForCluster(types.OrgID, types.ClusterName) ([]types.RuleOnReport, types.Timestamp, error) {
 	return []types.RuleOnReport{}, "", nil
@@ -75,6 +87,40 @@ func (*NoopStorage) WriteReportForCluster(
 	return nil
 }
 
+// WriteReportsForClusters noop
+func (*NoopStorage) WriteReportsForClusters([]ReportForCluster) ([]WrittenReport, error) {
+	return nil, nil
+}
+
+// ReadReportHistoryForCluster noop
+func (*NoopStorage) ReadReportHistoryForCluster(
+	types.OrgID, types.ClusterName, time.Time, time.Time,
+) ([]ReportHistoryItem, error) {
+	return nil, nil
+}
+
+// ReadRuleHitEventsForCluster noop
+func (*NoopStorage) ReadRuleHitEventsForCluster(
+	types.OrgID, types.ClusterName, time.Time, time.Time,
+) ([]RuleHitEvent, error) {
+	return nil, nil
+}
+
+// ReadRuleHitEventsForOrg noop
+func (*NoopStorage) ReadRuleHitEventsForOrg(types.OrgID, time.Time, time.Time) ([]RuleHitEvent, error) {
+	return nil, nil
+}
+
+// ReadRuleHitsForOrg noop
+func (*NoopStorage) ReadRuleHitsForOrg(types.OrgID) ([]OrgRuleHits, error) {
+	return nil, nil
+}
+
+// ReadClustersHitByRule noop
+func (*NoopStorage) ReadClustersHitByRule(types.OrgID, types.RuleID, types.ErrorKey) ([]ClusterHitByRule, error) {
+	return nil, nil
+}
+
 // ReportsCount noop
 func (*NoopStorage) ReportsCount() (int, error) {
 	return 0, nil
@@ -159,10 +205,30 @@ func (*NoopStorage) DeleteRuleErrorKey(types.RuleID, types.ErrorKey) error {
 }
 
 // WriteConsumerError noop
-func (*NoopStorage) WriteConsumerError(*sarama.ConsumerMessage, error) error {
+func (*NoopStorage) WriteConsumerError(*broker.Message, error) error {
+	return nil
+}
+
+// ReadConsumerErrors noop
+func (*NoopStorage) ReadConsumerErrors(ConsumerErrorFilter) ([]ConsumerError, error) {
+	return nil, nil
+}
+
+// MarkConsumerErrorReplayed noop
+func (*NoopStorage) MarkConsumerErrorReplayed(string, int32, int64, error) error {
 	return nil
 }
 
+// ReadConsumerError noop
+func (*NoopStorage) ReadConsumerError(string, int32, int64) (ConsumerError, error) {
+	return ConsumerError{}, nil
+}
+
+// DeleteConsumerErrors noop
+func (*NoopStorage) DeleteConsumerErrors(ConsumerErrorFilter) (int64, error) {
+	return 0, nil
+}
+
 // ToggleRuleForCluster noop
 func (*NoopStorage) ToggleRuleForCluster(
 	types.ClusterName, types.RuleID, RuleToggle,
@@ -170,12 +236,46 @@ func (*NoopStorage) ToggleRuleForCluster(
 	return nil
 }
 
+// ToggleRuleForOrgClusters noop
+func (*NoopStorage) ToggleRuleForOrgClusters(
+	types.OrgID, []types.ClusterName, types.RuleID, RuleToggle,
+) ([]types.ClusterName, error) {
+	return nil, nil
+}
+
+// ToggleRuleForAllOrgClusters noop
+func (*NoopStorage) ToggleRuleForAllOrgClusters(
+	types.OrgID, types.RuleID, RuleToggle,
+) ([]types.ClusterName, error) {
+	return nil, nil
+}
+
 // DeleteFromRuleClusterToggle noop
 func (*NoopStorage) DeleteFromRuleClusterToggle(
 	types.ClusterName, types.RuleID) error {
 	return nil
 }
 
+// AckRuleForOrg noop
+func (*NoopStorage) AckRuleForOrg(types.OrgID, types.RuleID, types.UserID, string) error {
+	return nil
+}
+
+// GetRuleAckForOrg noop
+func (*NoopStorage) GetRuleAckForOrg(types.OrgID, types.RuleID) (*RuleAck, error) {
+	return nil, nil
+}
+
+// ReadRuleAcksForOrg noop
+func (*NoopStorage) ReadRuleAcksForOrg(types.OrgID) ([]RuleAck, error) {
+	return nil, nil
+}
+
+// DeleteRuleAckForOrg noop
+func (*NoopStorage) DeleteRuleAckForOrg(types.OrgID, types.RuleID) error {
+	return nil
+}
+
 // GetFromClusterRuleToggle noop
 func (*NoopStorage) GetFromClusterRuleToggle(
 	types.ClusterName,
@@ -192,6 +292,21 @@ func (*NoopStorage) GetTogglesForRules(
 	return nil, nil
 }
 
+// DisableRuleForClusterUntil noop
+func (*NoopStorage) DisableRuleForClusterUntil(
+	types.ClusterName, types.RuleID, time.Time,
+) error {
+	return nil
+}
+
+// GetDisabledUntilForRules noop
+func (*NoopStorage) GetDisabledUntilForRules(
+	types.ClusterName,
+	[]types.RuleOnReport,
+) (map[types.RuleID]time.Time, error) {
+	return nil, nil
+}
+
 // GetUserFeedbackOnRules noop
 func (*NoopStorage) GetUserFeedbackOnRules(
 	types.ClusterName,
@@ -230,3 +345,48 @@ func (*NoopStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName) ([]t
 func (*NoopStorage) ReadReportsForClusters(clusterNames []types.ClusterName) (map[types.ClusterName]types.ClusterReport, error) {
 	return nil, nil
 }
+
+// WithAuditInfo noop
+func (storage *NoopStorage) WithAuditInfo(AuditInfo) Storage {
+	return storage
+}
+
+// ReadAuditLog noop
+func (*NoopStorage) ReadAuditLog(AuditLogFilter) ([]AuditLogEntry, error) {
+	return nil, nil
+}
+
+// GetRuleFeedbackStats noop
+func (*NoopStorage) GetRuleFeedbackStats(time.Time, time.Time, int) ([]RuleFeedbackStats, error) {
+	return nil, nil
+}
+
+// ApplyRetentionPolicy noop
+func (*NoopStorage) ApplyRetentionPolicy(time.Time, time.Time, bool) (RetentionResult, error) {
+	return RetentionResult{}, nil
+}
+
+// EraseOrgData noop
+func (*NoopStorage) EraseOrgData(types.OrgID) (ErasureResult, error) {
+	return ErasureResult{}, nil
+}
+
+// EraseClusterData noop
+func (*NoopStorage) EraseClusterData(types.ClusterName) (ErasureResult, error) {
+	return ErasureResult{}, nil
+}
+
+// WriteClusterMetadata noop
+func (*NoopStorage) WriteClusterMetadata(ClusterMetadata) error {
+	return nil
+}
+
+// ReadClusterMetadata noop
+func (*NoopStorage) ReadClusterMetadata(types.OrgID, types.ClusterName) (ClusterMetadata, error) {
+	return ClusterMetadata{}, nil
+}
+
+// ReadOrgAllowlist noop
+func (*NoopStorage) ReadOrgAllowlist() ([]types.OrgID, error) {
+	return nil, nil
+}
@@ -31,10 +31,18 @@ func TestNoopStorage_Methods(t *testing.T) {
 	_ = noopStorage.Close()
 	_, _ = noopStorage.ListOfOrgs()
 	_, _ = noopStorage.ListOfClustersForOrg(0, time.Now())
+	_, _, _ = noopStorage.ListOfOrgsPage(storage.ClusterListFilter{}, storage.ListPagination{})
+	_, _, _ = noopStorage.ListOfClustersForOrgPage(0, storage.ClusterListFilter{}, storage.ListPagination{})
 	_, _, _ = noopStorage.ReadReportForCluster(0, "")
 	_, _, _ = noopStorage.ReadReportForClusterByClusterName("")
 	_, _ = noopStorage.GetLatestKafkaOffset()
 	_ = noopStorage.WriteReportForCluster(0, "", "", []types.ReportItem{}, time.Now(), 0)
+	_, _ = noopStorage.WriteReportsForClusters([]storage.ReportForCluster{})
+	_, _ = noopStorage.ReadReportHistoryForCluster(0, "", time.Now(), time.Now())
+	_, _ = noopStorage.ReadRuleHitEventsForCluster(0, "", time.Now(), time.Now())
+	_, _ = noopStorage.ReadRuleHitEventsForOrg(0, time.Now(), time.Now())
+	_, _ = noopStorage.ReadRuleHitsForOrg(0)
+	_, _ = noopStorage.ReadClustersHitByRule(0, "", "")
 	_, _ = noopStorage.ReportsCount()
 	_ = noopStorage.VoteOnRule("", "", "", 0, "")
 	_ = noopStorage.AddOrUpdateFeedbackOnRule("", "", "", "")
@@ -56,10 +64,22 @@ func TestNoopStorage_Methods_Cont(t *testing.T) {
 	_ = noopStorage.CreateRuleErrorKey(types.RuleErrorKey{})
 	_ = noopStorage.DeleteRuleErrorKey("", "")
 	_ = noopStorage.WriteConsumerError(nil, nil)
+	_, _ = noopStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	_ = noopStorage.MarkConsumerErrorReplayed("", 0, 0, nil)
+	_, _ = noopStorage.ReadConsumerError("", 0, 0)
+	_, _ = noopStorage.DeleteConsumerErrors(storage.ConsumerErrorFilter{})
 	_ = noopStorage.ToggleRuleForCluster("", "", 0)
+	_, _ = noopStorage.ToggleRuleForOrgClusters(0, nil, "", 0)
+	_, _ = noopStorage.ToggleRuleForAllOrgClusters(0, "", 0)
+	_ = noopStorage.AckRuleForOrg(0, "", "", "")
+	_, _ = noopStorage.GetRuleAckForOrg(0, "")
+	_, _ = noopStorage.ReadRuleAcksForOrg(0)
+	_ = noopStorage.DeleteRuleAckForOrg(0, "")
 	_ = noopStorage.DeleteFromRuleClusterToggle("", "")
 	_, _ = noopStorage.GetFromClusterRuleToggle("", "")
 	_, _ = noopStorage.GetTogglesForRules("", nil)
+	_ = noopStorage.DisableRuleForClusterUntil("", "", time.Time{})
+	_, _ = noopStorage.GetDisabledUntilForRules("", nil)
 	_, _ = noopStorage.GetUserFeedbackOnRules("", nil, "")
 	_, _ = noopStorage.GetRuleWithContent("", "")
 	_, _ = noopStorage.ReadOrgIDsForClusters([]types.ClusterName{})
@@ -67,4 +87,13 @@ func TestNoopStorage_Methods_Cont(t *testing.T) {
 	_, _ = noopStorage.ReadSingleRuleTemplateData(0, "", "", "")
 	_, _ = noopStorage.GetUserDisableFeedbackOnRules("", []types.RuleOnReport{}, "")
 	_, _ = noopStorage.DoesClusterExist("")
+	_ = noopStorage.WithAuditInfo(storage.AuditInfo{})
+	_, _ = noopStorage.ReadAuditLog(storage.AuditLogFilter{})
+	_, _ = noopStorage.GetRuleFeedbackStats(time.Time{}, time.Time{}, 0)
+	_, _ = noopStorage.ApplyRetentionPolicy(time.Time{}, time.Time{}, true)
+	_, _ = noopStorage.EraseOrgData(0)
+	_, _ = noopStorage.EraseClusterData("")
+	_ = noopStorage.WriteClusterMetadata(storage.ClusterMetadata{})
+	_, _ = noopStorage.ReadClusterMetadata(0, "")
+	_, _ = noopStorage.ReadOrgAllowlist()
 }
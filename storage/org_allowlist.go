@@ -0,0 +1,46 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ReadOrgAllowlist reads IDs of all organizations allowed to send reports
+func (storage DBStorage) ReadOrgAllowlist() ([]types.OrgID, error) {
+	orgIDs := make([]types.OrgID, 0)
+
+	rows, err := storage.connection.Query("SELECT org_id FROM org_allowlist ORDER BY org_id")
+	if err != nil {
+		return orgIDs, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var orgID types.OrgID
+
+		err = rows.Scan(&orgID)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadOrgAllowlist")
+			return orgIDs, err
+		}
+
+		orgIDs = append(orgIDs, orgID)
+	}
+
+	return orgIDs, rows.Err()
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestDBStorageReadOrgAllowlistEmpty(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	orgIDs, err := mockStorage.ReadOrgAllowlist()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, orgIDs)
+}
+
+func TestDBStorageReadOrgAllowlist(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	connection := mockStorage.(*storage.DBStorage).GetConnection()
+	for _, orgID := range []types.OrgID{3, 1, 2} {
+		_, err := connection.Exec("INSERT INTO org_allowlist (org_id) VALUES ($1)", orgID)
+		helpers.FailOnError(t, err)
+	}
+
+	orgIDs, err := mockStorage.ReadOrgAllowlist()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgID{1, 2, 3}, orgIDs)
+}
+
+func TestDBStorageReadOrgAllowlistOnClosedStorage(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadOrgAllowlist()
+	assert.EqualError(t, err, "sql: database is closed")
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// OrgRuleHits represents one rule hit aggregated over all clusters in an
+// organization
+type OrgRuleHits struct {
+	RuleFQDN      types.RuleID        `json:"rule_fqdn"`
+	ErrorKey      types.ErrorKey      `json:"error_key"`
+	ClustersCount int                 `json:"clusters_count"`
+	Clusters      []types.ClusterName `json:"clusters"`
+}
+
+// ReadRuleHitsForOrg aggregates rule hits of all clusters that belong to the
+// selected organization by rule and error key. Rules disabled for a cluster
+// are not counted for that cluster unless the time limit of disabling is
+// over. Rules acknowledged for the whole organization are not counted at
+// all. The rule hitting the most clusters goes first.
+func (storage DBStorage) ReadRuleHitsForOrg(orgID types.OrgID) ([]OrgRuleHits, error) {
+	rows, err := storage.connection.Query(`
+		SELECT rule_hit.rule_fqdn, rule_hit.error_key, rule_hit.cluster_id
+		FROM rule_hit
+		WHERE rule_hit.org_id = $1 AND NOT EXISTS (
+			SELECT 1 FROM cluster_rule_toggle
+			WHERE cluster_rule_toggle.cluster_id = rule_hit.cluster_id
+				AND cluster_rule_toggle.rule_id = rule_hit.rule_fqdn
+				AND cluster_rule_toggle.disabled = $2
+				AND (cluster_rule_toggle.disabled_until IS NULL OR cluster_rule_toggle.disabled_until > $3)
+		) AND NOT EXISTS (
+			SELECT 1 FROM rule_ack
+			WHERE rule_ack.org_id = rule_hit.org_id AND rule_ack.rule_id = rule_hit.rule_fqdn
+		)
+		ORDER BY rule_hit.rule_fqdn, rule_hit.error_key, rule_hit.cluster_id;
+	`, orgID, RuleToggleDisable, time.Now().UTC())
+	err = types.ConvertDBError(err, orgID)
+	if err != nil {
+		return []OrgRuleHits{}, err
+	}
+	defer closeRows(rows)
+
+	ruleHits := make([]OrgRuleHits, 0)
+
+	for rows.Next() {
+		var (
+			key         ruleHitKey
+			clusterName types.ClusterName
+		)
+
+		err = rows.Scan(&key.ruleFQDN, &key.errorKey, &clusterName)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadRuleHitsForOrg")
+			return ruleHits, err
+		}
+
+		// rows are ordered by the rule, so the clusters of the same rule
+		// hit are always consecutive
+		last := len(ruleHits) - 1
+		if last < 0 || ruleHits[last].RuleFQDN != key.ruleFQDN || ruleHits[last].ErrorKey != key.errorKey {
+			ruleHits = append(ruleHits, OrgRuleHits{
+				RuleFQDN: key.ruleFQDN,
+				ErrorKey: key.errorKey,
+				Clusters: []types.ClusterName{},
+			})
+			last++
+		}
+
+		ruleHits[last].Clusters = append(ruleHits[last].Clusters, clusterName)
+		ruleHits[last].ClustersCount++
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return ruleHits, err
+	}
+
+	sort.SliceStable(ruleHits, func(i, j int) bool {
+		return ruleHits[i].ClustersCount > ruleHits[j].ClustersCount
+	})
+
+	return ruleHits, nil
+}
+
+// ClusterHitByRule represents one cluster hit by a rule
+type ClusterHitByRule struct {
+	ClusterName   types.ClusterName `json:"cluster"`
+	LastCheckedAt types.Timestamp   `json:"last_checked_at"`
+}
+
+// ReadClustersHitByRule reads all clusters that belong to the selected
+// organization and are hit by the rule with given error key. Clusters that
+// have the rule disabled (without expired time limit) are omitted, no cluster
+// is returned for the rule acknowledged for the whole organization. The most
+// recently checked cluster goes first.
+func (storage DBStorage) ReadClustersHitByRule(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) ([]ClusterHitByRule, error) {
+	rows, err := storage.connection.Query(`
+		SELECT rule_hit.cluster_id, report.last_checked_at
+		FROM rule_hit
+		JOIN report ON report.org_id = rule_hit.org_id AND report.cluster = rule_hit.cluster_id
+		WHERE rule_hit.org_id = $1 AND rule_hit.rule_fqdn = $2 AND rule_hit.error_key = $3 AND NOT EXISTS (
+			SELECT 1 FROM cluster_rule_toggle
+			WHERE cluster_rule_toggle.cluster_id = rule_hit.cluster_id
+				AND cluster_rule_toggle.rule_id = rule_hit.rule_fqdn
+				AND cluster_rule_toggle.disabled = $4
+				AND (cluster_rule_toggle.disabled_until IS NULL OR cluster_rule_toggle.disabled_until > $5)
+		) AND NOT EXISTS (
+			SELECT 1 FROM rule_ack
+			WHERE rule_ack.org_id = rule_hit.org_id AND rule_ack.rule_id = rule_hit.rule_fqdn
+		)
+		ORDER BY report.last_checked_at DESC, rule_hit.cluster_id;
+	`, orgID, ruleID, errorKey, RuleToggleDisable, time.Now().UTC())
+	err = types.ConvertDBError(err, []interface{}{orgID, ruleID, errorKey})
+	if err != nil {
+		return []ClusterHitByRule{}, err
+	}
+	defer closeRows(rows)
+
+	clusters := make([]ClusterHitByRule, 0)
+
+	for rows.Next() {
+		var (
+			cluster     ClusterHitByRule
+			lastChecked time.Time
+		)
+
+		err = rows.Scan(&cluster.ClusterName, &lastChecked)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadClustersHitByRule")
+			return clusters, err
+		}
+
+		cluster.LastCheckedAt = types.Timestamp(lastChecked.UTC().Format(time.RFC3339))
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters, rows.Err()
+}
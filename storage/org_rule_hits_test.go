@@ -0,0 +1,185 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	firstClusterName  = types.ClusterName("11111111-1111-1111-1111-111111111111")
+	secondClusterName = types.ClusterName("22222222-2222-2222-2222-222222222222")
+)
+
+func TestDBStorageReadRuleHitsForOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeReports := []struct {
+		orgID       types.OrgID
+		clusterName types.ClusterName
+		report      types.ClusterReport
+		rules       []types.ReportItem
+	}{
+		{testdata.OrgID, firstClusterName, testdata.Report3Rules, testdata.Report3RulesParsed},
+		{testdata.OrgID, secondClusterName, testdata.Report2Rules, testdata.Report2RulesParsed},
+		{testdata.Org2ID, testdata.GetRandomClusterID(), testdata.Report3Rules, testdata.Report3RulesParsed},
+	}
+	for _, report := range writeReports {
+		err := mockStorage.WriteReportForCluster(
+			report.orgID, report.clusterName, report.report, report.rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	err := mockStorage.ToggleRuleForCluster(secondClusterName, testdata.Rule2ID, storage.RuleToggleDisable)
+	helpers.FailOnError(t, err)
+
+	ruleHits, err := mockStorage.ReadRuleHitsForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []storage.OrgRuleHits{
+		{
+			RuleFQDN:      testdata.Rule1ID,
+			ErrorKey:      testdata.ErrorKey1,
+			ClustersCount: 2,
+			Clusters:      []types.ClusterName{firstClusterName, secondClusterName},
+		},
+		{
+			RuleFQDN:      testdata.Rule2ID,
+			ErrorKey:      testdata.ErrorKey2,
+			ClustersCount: 1,
+			Clusters:      []types.ClusterName{firstClusterName},
+		},
+		{
+			RuleFQDN:      testdata.Rule3ID,
+			ErrorKey:      testdata.ErrorKey3,
+			ClustersCount: 1,
+			Clusters:      []types.ClusterName{firstClusterName},
+		},
+	}, ruleHits)
+}
+
+// TestDBStorageReadRuleHitsForOrg_AckedRule checks that rules acknowledged
+// for the organization are not counted
+func TestDBStorageReadRuleHitsForOrg_AckedRule(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, firstClusterName, testdata.Report2Rules, testdata.Report2RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, ""))
+	// acknowledgement of another organization is not used
+	helpers.FailOnError(t, mockStorage.AckRuleForOrg(testdata.Org2ID, testdata.Rule2ID, testdata.UserID, ""))
+
+	ruleHits, err := mockStorage.ReadRuleHitsForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []storage.OrgRuleHits{
+		{
+			RuleFQDN:      testdata.Rule2ID,
+			ErrorKey:      testdata.ErrorKey2,
+			ClustersCount: 1,
+			Clusters:      []types.ClusterName{firstClusterName},
+		},
+	}, ruleHits)
+
+	clusters, err := mockStorage.ReadClustersHitByRule(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, clusters)
+}
+
+func TestDBStorageReadRuleHitsForOrg_Empty(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	ruleHits, err := mockStorage.ReadRuleHitsForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, ruleHits)
+}
+
+func TestDBStorageReadRuleHitsForOrg_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
+	closer()
+
+	_, err := mockStorage.ReadRuleHitsForOrg(testdata.OrgID)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageReadClustersHitByRule(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	olderTime := testdata.LastCheckedAt.Add(-time.Hour)
+
+	writeReports := []struct {
+		orgID       types.OrgID
+		clusterName types.ClusterName
+		rules       []types.ReportItem
+		lastChecked time.Time
+	}{
+		{testdata.OrgID, firstClusterName, testdata.Report3RulesParsed, olderTime},
+		{testdata.OrgID, secondClusterName, testdata.Report3RulesParsed, testdata.LastCheckedAt},
+		{testdata.OrgID, testdata.GetRandomClusterID(), testdata.Report2RulesParsed, testdata.LastCheckedAt},
+		{testdata.OrgID, testdata.GetRandomClusterID(), testdata.Report3RulesParsed, testdata.LastCheckedAt},
+		{testdata.Org2ID, testdata.GetRandomClusterID(), testdata.Report3RulesParsed, testdata.LastCheckedAt},
+	}
+	for _, report := range writeReports {
+		err := mockStorage.WriteReportForCluster(
+			report.orgID, report.clusterName, testdata.Report3Rules, report.rules, report.lastChecked, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	// the rule is disabled for the fourth cluster
+	err := mockStorage.ToggleRuleForCluster(writeReports[3].clusterName, testdata.Rule3ID, storage.RuleToggleDisable)
+	helpers.FailOnError(t, err)
+
+	clusters, err := mockStorage.ReadClustersHitByRule(testdata.OrgID, testdata.Rule3ID, testdata.ErrorKey3)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []storage.ClusterHitByRule{
+		{
+			ClusterName:   secondClusterName,
+			LastCheckedAt: types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339)),
+		},
+		{
+			ClusterName:   firstClusterName,
+			LastCheckedAt: types.Timestamp(olderTime.UTC().Format(time.RFC3339)),
+		},
+	}, clusters)
+}
+
+func TestDBStorageReadClustersHitByRule_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
+	closer()
+
+	_, err := mockStorage.ReadClustersHitByRule(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	assert.EqualError(t, err, "sql: database is closed")
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ReportHistoryItem represents one of the previous reports written for a cluster
+type ReportHistoryItem struct {
+	LastCheckedAt types.Timestamp      `json:"last_checked_at"`
+	ReportedAt    types.Timestamp      `json:"reported_at"`
+	Report        []types.RuleOnReport `json:"report"`
+}
+
+// writeReportHistory stores the report and its rule hits into the history
+// tables. It is expected to be called in the same transaction that updates
+// the latest report for the cluster.
+func (storage DBStorage) writeReportHistory(
+	tx *sql.Tx,
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	rules []types.ReportItem,
+	reportedAtTime time.Time,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	_, err := tx.Exec(`
+		INSERT INTO report_history(org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (org_id, cluster, last_checked_at) DO NOTHING
+	`, orgID, clusterName, report, reportedAtTime, lastCheckedTime, kafkaOffset)
+	if err != nil {
+		log.Err(err).Msgf("Unable to insert the cluster report into history (org: %v, cluster: %v)", orgID, clusterName)
+		return err
+	}
+
+	ruleHits := make([][]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		ruleHits = append(ruleHits, []interface{}{
+			orgID, clusterName, rule.Module, rule.ErrorKey, string(rule.TemplateData), lastCheckedTime,
+		})
+	}
+
+	err = insertRows(
+		tx,
+		"INSERT INTO rule_hit_history(org_id, cluster_id, rule_fqdn, error_key, template_data, last_checked_at)",
+		"ON CONFLICT (cluster_id, org_id, rule_fqdn, error_key, last_checked_at) DO NOTHING",
+		ruleHits,
+	)
+	if err != nil {
+		log.Err(err).Msgf("Unable to insert the rule hits into history (org: %v, cluster: %v)", orgID, clusterName)
+	}
+
+	return err
+}
+
+// ReadReportHistoryForCluster reads all reports written for selected cluster
+// that were checked at the given time or later and before until, if it is not
+// zero. The oldest report goes first.
+func (storage DBStorage) ReadReportHistoryForCluster(
+	orgID types.OrgID, clusterName types.ClusterName, since, until time.Time,
+) ([]ReportHistoryItem, error) {
+	history := make([]ReportHistoryItem, 0)
+
+	untilClause, args := untilCondition("last_checked_at", until, []interface{}{orgID, clusterName, since})
+
+	rows, err := storage.connection.Query(`
+		SELECT last_checked_at, reported_at
+		FROM report_history
+		WHERE org_id = $1 AND cluster = $2 AND last_checked_at >= $3`+untilClause+`
+		ORDER BY last_checked_at;
+	`, args...)
+	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	if err != nil {
+		return history, err
+	}
+	defer closeRows(rows)
+
+	// index of history item for given last checked timestamp
+	index := make(map[int64]int)
+
+	for rows.Next() {
+		var (
+			lastChecked time.Time
+			reportedAt  sql.NullTime
+		)
+
+		err = rows.Scan(&lastChecked, &reportedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadReportHistoryForCluster")
+			return history, err
+		}
+
+		item := ReportHistoryItem{
+			LastCheckedAt: types.Timestamp(lastChecked.UTC().Format(time.RFC3339)),
+			Report:        make([]types.RuleOnReport, 0),
+		}
+		if reportedAt.Valid {
+			item.ReportedAt = types.Timestamp(reportedAt.Time.UTC().Format(time.RFC3339))
+		}
+
+		index[lastChecked.UnixNano()] = len(history)
+		history = append(history, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return history, err
+	}
+
+	ruleRows, err := storage.connection.Query(`
+		SELECT last_checked_at, template_data, rule_fqdn, error_key
+		FROM rule_hit_history
+		WHERE org_id = $1 AND cluster_id = $2 AND last_checked_at >= $3;
+	`, orgID, clusterName, since)
+	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	if err != nil {
+		return history, err
+	}
+	defer closeRows(ruleRows)
+
+	for ruleRows.Next() {
+		var (
+			lastChecked       time.Time
+			templateDataBytes []byte
+			ruleFQDN          types.RuleID
+			errorKey          types.ErrorKey
+		)
+
+		err = ruleRows.Scan(&lastChecked, &templateDataBytes, &ruleFQDN, &errorKey)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadReportHistoryForCluster")
+			return history, err
+		}
+
+		i, found := index[lastChecked.UnixNano()]
+		if !found {
+			log.Warn().Msgf("Rule hit history without report (cluster: %v, last checked: %v)", clusterName, lastChecked)
+			continue
+		}
+
+		history[i].Report = append(history[i].Report, types.RuleOnReport{
+			Module:       ruleFQDN,
+			ErrorKey:     errorKey,
+			TemplateData: parseTemplateData(templateDataBytes),
+		})
+	}
+
+	return history, ruleRows.Err()
+}
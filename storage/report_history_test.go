@@ -0,0 +1,95 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+func TestDBStorageReadReportHistoryForClusterEmpty(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	history, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, history)
+}
+
+func TestDBStorageReadReportHistoryForCluster(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	olderTime := time.Now().UTC().Add(-time.Hour)
+	newerTime := olderTime.Add(30 * time.Minute)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		olderTime,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.ReportEmptyRulesParsed,
+		newerTime,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// both reports are kept, the older one goes first
+	history, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, history, 2)
+	assert.Len(t, history[0].Report, 3)
+	assert.Empty(t, history[1].Report)
+
+	// only the newer report is returned
+	history, err = mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName, newerTime, time.Time{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, history, 1)
+	assert.Empty(t, history[0].Report)
+
+	// only the older report is returned
+	history, err = mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, newerTime)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, history, 1)
+	assert.Len(t, history[0].Report, 3)
+}
+
+func TestDBStorageReadReportHistoryForClusterClosedStorage(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	// we need to close storage right now
+	closer()
+
+	_, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{})
+	assert.EqualError(t, err, "sql: database is closed")
+}
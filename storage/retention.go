@@ -0,0 +1,168 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// StaleCluster is a cluster whose latest report is older than the
+// retention policy allows
+type StaleCluster struct {
+	OrgID         types.OrgID       `json:"org_id"`
+	ClusterName   types.ClusterName `json:"cluster"`
+	LastCheckedAt time.Time         `json:"last_checked_at"`
+}
+
+// RetentionResult describes data removed by the retention policy, or data
+// that would be removed in dry-run mode
+type RetentionResult struct {
+	StaleClusters  []StaleCluster `json:"stale_clusters"`
+	ConsumerErrors int64          `json:"consumer_errors"`
+}
+
+// ApplyRetentionPolicy removes reports, rule hits, metadata, history and rule
+// hit events of clusters last checked before reportsBefore and consumer
+// errors consumed before consumerErrorsBefore in one transaction. Zero time
+// means that the data are kept. Nothing is removed in dry-run mode.
+func (storage DBStorage) ApplyRetentionPolicy(
+	reportsBefore, consumerErrorsBefore time.Time, dryRun bool,
+) (RetentionResult, error) {
+	result := RetentionResult{StaleClusters: make([]StaleCluster, 0)}
+
+	tx, err := storage.connection.Begin()
+	if err != nil {
+		return result, err
+	}
+
+	err = func(tx *sql.Tx) error {
+		var err error
+
+		if !reportsBefore.IsZero() {
+			result.StaleClusters, err = readStaleClusters(tx, reportsBefore)
+			if err != nil {
+				return err
+			}
+
+			if !dryRun {
+				err = deleteStaleReports(tx, reportsBefore)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if !consumerErrorsBefore.IsZero() {
+			result.ConsumerErrors, err = removeOldConsumerErrors(tx, consumerErrorsBefore, dryRun)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}(tx)
+
+	finishTransaction(tx, err)
+
+	if err == nil && !dryRun {
+		for _, cluster := range result.StaleClusters {
+			storage.forgetClustersLastChecked(cluster.ClusterName)
+		}
+	}
+
+	return result, err
+}
+
+// readStaleClusters reads clusters last checked before the given time
+func readStaleClusters(tx *sql.Tx, before time.Time) ([]StaleCluster, error) {
+	rows, err := tx.Query(`
+		SELECT org_id, cluster, last_checked_at
+		FROM report
+		WHERE last_checked_at < $1
+		ORDER BY org_id, cluster
+	`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	clusters := make([]StaleCluster, 0)
+	for rows.Next() {
+		var cluster StaleCluster
+
+		err = rows.Scan(&cluster.OrgID, &cluster.ClusterName, &cluster.LastCheckedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("readStaleClusters")
+			return nil, err
+		}
+
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters, rows.Err()
+}
+
+// staleClusterTables contain data of clusters removed together with their
+// reports, the column contains the cluster name
+var staleClusterTables = []struct {
+	table  string
+	column string
+}{
+	{"rule_hit", "cluster_id"},
+	{"cluster_metadata", "cluster"},
+	{"report_history", "cluster"},
+	{"rule_hit_history", "cluster_id"},
+	{"rule_hit_event", "cluster_id"},
+}
+
+// deleteStaleReports deletes reports, rule hits, metadata, history and rule
+// hit events of clusters last checked before the given time
+func deleteStaleReports(tx *sql.Tx, before time.Time) error {
+	for _, stale := range staleClusterTables {
+		_, err := tx.Exec(`
+			DELETE FROM `+stale.table+`
+			WHERE `+stale.column+` IN (SELECT cluster FROM report WHERE last_checked_at < $1)
+		`, before)
+		if err != nil {
+			log.Error().Err(err).Str("table", stale.table).Msg("Unable to delete stale data")
+			return err
+		}
+	}
+
+	_, err := tx.Exec("DELETE FROM report WHERE last_checked_at < $1", before)
+	return err
+}
+
+// removeOldConsumerErrors deletes consumer errors consumed before the given
+// time, or only counts them in dry-run mode
+func removeOldConsumerErrors(tx *sql.Tx, before time.Time, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := tx.QueryRow("SELECT COUNT(*) FROM consumer_error WHERE consumed_at < $1", before).Scan(&count)
+		return count, err
+	}
+
+	result, err := tx.Exec("DELETE FROM consumer_error WHERE consumed_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
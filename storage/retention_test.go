@@ -0,0 +1,136 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const recentClusterName = types.ClusterName("11111111-2222-3333-4444-555555555555")
+
+// mustWriteDataForRetention writes one stale and one recent report together
+// with metadata of the stale cluster and one consumer error
+func mustWriteDataForRetention(t *testing.T, mockStorage storage.Storage) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		time.Now().Add(-48*time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, recentClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		time.Now(), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	mustWriteClusterMetadata(t, mockStorage, testdata.ClusterName, "4.6.1", "AWS", time.Now().Add(-48*time.Hour))
+
+	mustWriteConsumerError(t, mockStorage, 1, "unexpected EOF")
+}
+
+func TestDBStorageApplyRetentionPolicy(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteDataForRetention(t, mockStorage)
+
+	result, err := mockStorage.ApplyRetentionPolicy(
+		time.Now().Add(-24*time.Hour), time.Now().Add(time.Hour), false,
+	)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, result.StaleClusters, 1)
+	assert.Equal(t, testdata.OrgID, result.StaleClusters[0].OrgID)
+	assert.Equal(t, testdata.ClusterName, result.StaleClusters[0].ClusterName)
+	assert.Equal(t, int64(1), result.ConsumerErrors)
+
+	clusters, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{recentClusterName}, clusters)
+
+	ruleHits, err := mockStorage.ReadRuleHitsForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.NotEmpty(t, ruleHits)
+	for _, ruleHit := range ruleHits {
+		assert.Equal(t, []types.ClusterName{recentClusterName}, ruleHit.Clusters)
+	}
+
+	_, err = mockStorage.ReadClusterMetadata(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	history, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, history)
+
+	events, err := mockStorage.ReadRuleHitEventsForOrg(testdata.OrgID, time.Time{}, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.NotEmpty(t, events)
+	for _, event := range events {
+		assert.Equal(t, recentClusterName, event.ClusterName)
+	}
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, consumerErrors)
+}
+
+func TestDBStorageApplyRetentionPolicyDryRun(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteDataForRetention(t, mockStorage)
+
+	result, err := mockStorage.ApplyRetentionPolicy(
+		time.Now().Add(-24*time.Hour), time.Now().Add(time.Hour), true,
+	)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, result.StaleClusters, 1)
+	assert.Equal(t, int64(1), result.ConsumerErrors)
+
+	clusters, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, clusters, 2)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+}
+
+func TestDBStorageApplyRetentionPolicyDisabled(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteDataForRetention(t, mockStorage)
+
+	result, err := mockStorage.ApplyRetentionPolicy(time.Time{}, time.Time{}, false)
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, result.StaleClusters)
+	assert.Equal(t, int64(0), result.ConsumerErrors)
+
+	clusters, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, clusters, 2)
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RuleAck represents a rule acknowledged for the whole organization. The
+// acknowledged rule is treated as disabled for all clusters of the
+// organization, including clusters registered after the acknowledgement.
+type RuleAck struct {
+	OrgID         types.OrgID  `json:"org_id"`
+	RuleID        types.RuleID `json:"rule_id"`
+	Justification string       `json:"justification"`
+	CreatedBy     types.UserID `json:"created_by"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// AckRuleForOrg acknowledges rule for the whole organization. Justification
+// and the user are updated when the rule has been acknowledged already.
+func (storage DBStorage) AckRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, userID types.UserID, justification string,
+) error {
+	now := time.Now()
+
+	return storage.changeWithAudit(
+		orgForAudit(orgID), AuditActionRuleAck, fmt.Sprintf("%v/%v", orgID, ruleID), readRuleAckForAudit(orgID, ruleID),
+		func(tx *sql.Tx) error {
+			_, err := tx.Exec(`
+				INSERT INTO rule_ack(org_id, rule_id, justification, created_by, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $5)
+				ON CONFLICT (org_id, rule_id) DO UPDATE SET
+					justification = $3,
+					created_by = $4,
+					updated_at = $5
+			`, orgID, ruleID, justification, userID, now)
+			if err != nil {
+				log.Error().Err(err).Msg("Error during execution SQL exec for rule acknowledgement")
+			}
+
+			return err
+		},
+	)
+}
+
+// readRuleAckForAudit returns function reading acknowledgement of the rule
+// for the audit log
+func readRuleAckForAudit(orgID types.OrgID, ruleID types.RuleID) func(tx *sql.Tx) (interface{}, error) {
+	return func(tx *sql.Tx) (interface{}, error) {
+		return optionalAuditItem(readRuleAck(tx, orgID, ruleID))
+	}
+}
+
+// GetRuleAckForOrg reads acknowledgement of the rule for the organization,
+// ItemNotFoundError is returned when the rule is not acknowledged
+func (storage DBStorage) GetRuleAckForOrg(
+	orgID types.OrgID, ruleID types.RuleID,
+) (*RuleAck, error) {
+	return readRuleAck(storage.connection, orgID, ruleID)
+}
+
+// readRuleAck reads acknowledgement of the rule inside or outside of
+// transaction
+func readRuleAck(db queryRower, orgID types.OrgID, ruleID types.RuleID) (*RuleAck, error) {
+	var ack RuleAck
+
+	err := db.QueryRow(`
+		SELECT org_id, rule_id, justification, created_by, created_at, updated_at
+		FROM rule_ack
+		WHERE org_id = $1 AND rule_id = $2
+	`, orgID, ruleID).Scan(
+		&ack.OrgID,
+		&ack.RuleID,
+		&ack.Justification,
+		&ack.CreatedBy,
+		&ack.CreatedAt,
+		&ack.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, &types.ItemNotFoundError{ItemID: ruleID}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ack, nil
+}
+
+// ReadRuleAcksForOrg reads all rules acknowledged for the organization
+// ordered by rule ID
+func (storage DBStorage) ReadRuleAcksForOrg(orgID types.OrgID) ([]RuleAck, error) {
+	acks := make([]RuleAck, 0)
+
+	rows, err := storage.connection.Query(`
+		SELECT org_id, rule_id, justification, created_by, created_at, updated_at
+		FROM rule_ack
+		WHERE org_id = $1
+		ORDER BY rule_id
+	`, orgID)
+	if err != nil {
+		return acks, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var ack RuleAck
+
+		err = rows.Scan(
+			&ack.OrgID,
+			&ack.RuleID,
+			&ack.Justification,
+			&ack.CreatedBy,
+			&ack.CreatedAt,
+			&ack.UpdatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadRuleAcksForOrg")
+			return acks, err
+		}
+
+		acks = append(acks, ack)
+	}
+
+	return acks, rows.Err()
+}
+
+// DeleteRuleAckForOrg deletes acknowledgement of the rule for the
+// organization, ItemNotFoundError is returned when the rule is not
+// acknowledged
+func (storage DBStorage) DeleteRuleAckForOrg(orgID types.OrgID, ruleID types.RuleID) error {
+	return storage.changeWithAudit(
+		orgForAudit(orgID), AuditActionDeleteRuleAck, fmt.Sprintf("%v/%v", orgID, ruleID), readRuleAckForAudit(orgID, ruleID),
+		func(tx *sql.Tx) error {
+			result, err := tx.Exec(
+				"DELETE FROM rule_ack WHERE org_id = $1 AND rule_id = $2", orgID, ruleID,
+			)
+			if err != nil {
+				return err
+			}
+
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			if affected == 0 {
+				return &types.ItemNotFoundError{ItemID: ruleID}
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestDBStorage_AckRuleForOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, "first"))
+
+	ack, err := mockStorage.GetRuleAckForOrg(testdata.OrgID, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.OrgID, ack.OrgID)
+	assert.Equal(t, testdata.Rule1ID, ack.RuleID)
+	assert.Equal(t, testdata.UserID, ack.CreatedBy)
+	assert.Equal(t, "first", ack.Justification)
+
+	// acknowledging the rule again updates the justification
+	helpers.FailOnError(t, mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, "second"))
+
+	acks, err := mockStorage.ReadRuleAcksForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, acks, 1)
+	assert.Equal(t, "second", acks[0].Justification)
+	assert.Equal(t, ack.CreatedAt.Unix(), acks[0].CreatedAt.Unix())
+}
+
+func TestDBStorage_ReadRuleAcksForOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule2ID, testdata.UserID, ""))
+	helpers.FailOnError(t, mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, ""))
+	helpers.FailOnError(t, mockStorage.AckRuleForOrg(testdata.Org2ID, testdata.Rule3ID, testdata.UserID, ""))
+
+	acks, err := mockStorage.ReadRuleAcksForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, acks, 2)
+	assert.Equal(t, testdata.Rule1ID, acks[0].RuleID)
+	assert.Equal(t, testdata.Rule2ID, acks[1].RuleID)
+
+	acks, err = mockStorage.ReadRuleAcksForOrg(testdata.Org2ID + 1)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, acks)
+}
+
+func TestDBStorage_DeleteRuleAckForOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, ""))
+	helpers.FailOnError(t, mockStorage.DeleteRuleAckForOrg(testdata.OrgID, testdata.Rule1ID))
+
+	_, err := mockStorage.GetRuleAckForOrg(testdata.OrgID, testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+
+	err = mockStorage.DeleteRuleAckForOrg(testdata.OrgID, testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+}
+
+func TestDBStorage_RuleAcks_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	assert.EqualError(t, mockStorage.AckRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID, ""), "sql: database is closed")
+
+	_, err := mockStorage.GetRuleAckForOrg(testdata.OrgID, testdata.Rule1ID)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadRuleAcksForOrg(testdata.OrgID)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	assert.EqualError(t, mockStorage.DeleteRuleAckForOrg(testdata.OrgID, testdata.Rule1ID), "sql: database is closed")
+}
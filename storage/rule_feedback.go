@@ -28,13 +28,13 @@ import (
 
 // UserFeedbackOnRule shows user's feedback on rule
 type UserFeedbackOnRule struct {
-	ClusterID types.ClusterName
-	RuleID    types.RuleID
-	UserID    types.UserID
-	Message   string
-	UserVote  types.UserVote
-	AddedAt   time.Time
-	UpdatedAt time.Time
+	ClusterID types.ClusterName `json:"cluster"`
+	RuleID    types.RuleID      `json:"rule_id"`
+	UserID    types.UserID      `json:"user_id"`
+	Message   string            `json:"message"`
+	UserVote  types.UserVote    `json:"user_vote"`
+	AddedAt   time.Time         `json:"added_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
 }
 
 // VoteOnRule likes or dislikes rule for cluster by user. If entry exists, it overwrites it
@@ -88,24 +88,42 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 		return err
 	}
 
-	statement, err := storage.connection.Prepare(query)
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to prepare statement")
-		return err
+	action := AuditActionFeedback
+	if updateVote {
+		action = AuditActionVote
 	}
-	defer func() {
-		err := statement.Close()
-		if err != nil {
-			log.Error().Err(err).Msg("Unable to close statement")
-		}
-	}()
 
-	now := time.Now()
+	readFeedback := func(tx *sql.Tx) (interface{}, error) {
+		return optionalAuditItem(readUserFeedbackOnRule(tx, clusterID, ruleID, userID))
+	}
 
-	_, err = statement.Exec(clusterID, ruleID, userID, userVote, now, now, message)
-	err = types.ConvertDBError(err, nil)
+	err = storage.changeWithAudit(
+		clusterOrgForAudit(clusterID), action, fmt.Sprintf("%v/%v/%v", clusterID, ruleID, userID), readFeedback,
+		func(tx *sql.Tx) error {
+			statement, err := tx.Prepare(query)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to prepare statement")
+				return err
+			}
+			defer func() {
+				err := statement.Close()
+				if err != nil {
+					log.Error().Err(err).Msg("Unable to close statement")
+				}
+			}()
+
+			now := time.Now()
+
+			_, err = statement.Exec(clusterID, ruleID, userID, userVote, now, now, message)
+			err = types.ConvertDBError(err, nil)
+			if err != nil {
+				log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleForCluster")
+			}
+
+			return err
+		},
+	)
 	if err != nil {
-		log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleForCluster")
 		return err
 	}
 
@@ -150,10 +168,17 @@ func (storage DBStorage) constructUpsertClusterRuleUserFeedback(updateVote bool,
 // GetUserFeedbackOnRule gets user feedback from DB
 func (storage DBStorage) GetUserFeedbackOnRule(
 	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	return readUserFeedbackOnRule(storage.connection, clusterID, ruleID, userID)
+}
+
+// readUserFeedbackOnRule reads user feedback inside or outside of transaction
+func readUserFeedbackOnRule(
+	db queryRower, clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
 ) (*UserFeedbackOnRule, error) {
 	feedback := UserFeedbackOnRule{}
 
-	err := storage.connection.QueryRow(
+	err := db.QueryRow(
 		`SELECT cluster_id, rule_id, user_id, message, user_vote, added_at, updated_at
 		FROM cluster_rule_user_feedback
 		WHERE cluster_id = $1 AND rule_id = $2 AND user_id = $3`,
@@ -183,10 +208,18 @@ func (storage DBStorage) GetUserFeedbackOnRule(
 // GetUserFeedbackOnRuleDisable gets user feedback from DB
 func (storage DBStorage) GetUserFeedbackOnRuleDisable(
 	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	return readUserFeedbackOnRuleDisable(storage.connection, clusterID, ruleID, userID)
+}
+
+// readUserFeedbackOnRuleDisable reads user feedback on rule disable inside
+// or outside of transaction
+func readUserFeedbackOnRuleDisable(
+	db queryRower, clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
 ) (*UserFeedbackOnRule, error) {
 	feedback := UserFeedbackOnRule{}
 
-	err := storage.connection.QueryRow(
+	err := db.QueryRow(
 		`SELECT cluster_id, user_id, rule_id, message, added_at, updated_at
 		FROM cluster_user_rule_disable_feedback
 		WHERE cluster_id = $1 AND user_id = $2 AND rule_id = $3`,
@@ -288,29 +321,42 @@ func (storage DBStorage) AddFeedbackOnRuleDisable(
 	userID types.UserID,
 	message string,
 ) error {
-	statement, err := storage.connection.Prepare(`
-		INSERT INTO cluster_user_rule_disable_feedback
-		(cluster_id, user_id, rule_id, message, added_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (cluster_id, user_id, rule_id)
-		DO UPDATE SET updated_at = $6, message = $4;
-	`)
-	if err != nil {
-		return err
+	readFeedback := func(tx *sql.Tx) (interface{}, error) {
+		return optionalAuditItem(readUserFeedbackOnRuleDisable(tx, clusterID, ruleID, userID))
 	}
-	defer func() {
-		err := statement.Close()
-		if err != nil {
-			log.Error().Err(err).Msg("Unable to close statement")
-		}
-	}()
 
-	now := time.Now()
+	err := storage.changeWithAudit(
+		clusterOrgForAudit(clusterID), AuditActionDisableFeedback, fmt.Sprintf("%v/%v/%v", clusterID, ruleID, userID), readFeedback,
+		func(tx *sql.Tx) error {
+			statement, err := tx.Prepare(`
+				INSERT INTO cluster_user_rule_disable_feedback
+				(cluster_id, user_id, rule_id, message, added_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (cluster_id, user_id, rule_id)
+				DO UPDATE SET updated_at = $6, message = $4;
+			`)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				err := statement.Close()
+				if err != nil {
+					log.Error().Err(err).Msg("Unable to close statement")
+				}
+			}()
+
+			now := time.Now()
+
+			_, err = statement.Exec(clusterID, userID, ruleID, message, now, now)
+			err = types.ConvertDBError(err, nil)
+			if err != nil {
+				log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleDisableForCluster")
+			}
 
-	_, err = statement.Exec(clusterID, userID, ruleID, message, now, now)
-	err = types.ConvertDBError(err, nil)
+			return err
+		},
+	)
 	if err != nil {
-		log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleDisableForCluster")
 		return err
 	}
 
@@ -0,0 +1,239 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RuleFeedbackMessage is a feedback or disable feedback message left by user
+type RuleFeedbackMessage struct {
+	ClusterID types.ClusterName `json:"cluster"`
+	UserID    types.UserID      `json:"user_id"`
+	Message   string            `json:"message"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// RuleFeedbackStats aggregates feedback of all users on the rule. Feedback
+// and DisableFeedback contain the most recent messages first.
+type RuleFeedbackStats struct {
+	RuleID          types.RuleID          `json:"rule_id"`
+	Likes           int                   `json:"likes"`
+	Dislikes        int                   `json:"dislikes"`
+	Disables        int                   `json:"disables"`
+	Feedback        []RuleFeedbackMessage `json:"feedback"`
+	DisableFeedback []RuleFeedbackMessage `json:"disable_feedback"`
+}
+
+// GetRuleFeedbackStats aggregates votes, disables and feedback messages
+// updated at since or later and before until, if it is not zero. At most
+// messagesLimit most recent messages of each kind are returned for every
+// rule. Rules are ordered by rule ID.
+func (storage DBStorage) GetRuleFeedbackStats(
+	since, until time.Time, messagesLimit int,
+) ([]RuleFeedbackStats, error) {
+	stats := make(map[types.RuleID]*RuleFeedbackStats)
+
+	getStats := func(ruleID types.RuleID) *RuleFeedbackStats {
+		ruleStats, found := stats[ruleID]
+		if !found {
+			ruleStats = &RuleFeedbackStats{
+				RuleID:          ruleID,
+				Feedback:        make([]RuleFeedbackMessage, 0),
+				DisableFeedback: make([]RuleFeedbackMessage, 0),
+			}
+			stats[ruleID] = ruleStats
+		}
+
+		return ruleStats
+	}
+
+	err := storage.readVoteStats(since, until, getStats)
+	if err != nil {
+		return nil, err
+	}
+
+	err = storage.readDisableStats(since, until, getStats)
+	if err != nil {
+		return nil, err
+	}
+
+	err = storage.readFeedbackMessages(
+		"cluster_rule_user_feedback", since, until, messagesLimit,
+		func(ruleID types.RuleID, message RuleFeedbackMessage) {
+			ruleStats := getStats(ruleID)
+			ruleStats.Feedback = append(ruleStats.Feedback, message)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = storage.readFeedbackMessages(
+		"cluster_user_rule_disable_feedback", since, until, messagesLimit,
+		func(ruleID types.RuleID, message RuleFeedbackMessage) {
+			ruleStats := getStats(ruleID)
+			ruleStats.DisableFeedback = append(ruleStats.DisableFeedback, message)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]RuleFeedbackStats, 0, len(stats))
+	for _, ruleStats := range stats {
+		result = append(result, *ruleStats)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RuleID < result[j].RuleID
+	})
+
+	return result, nil
+}
+
+// readVoteStats counts likes and dislikes of the rules
+func (storage DBStorage) readVoteStats(
+	since, until time.Time, getStats func(types.RuleID) *RuleFeedbackStats,
+) error {
+	untilClause, args := untilCondition(
+		"updated_at", until, []interface{}{types.UserVoteLike, types.UserVoteDislike, since},
+	)
+
+	rows, err := storage.connection.Query(`
+		SELECT rule_id,
+			SUM(CASE WHEN user_vote = $1 THEN 1 ELSE 0 END),
+			SUM(CASE WHEN user_vote = $2 THEN 1 ELSE 0 END)
+		FROM cluster_rule_user_feedback
+		WHERE updated_at >= $3`+untilClause+`
+		GROUP BY rule_id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			ruleID          types.RuleID
+			likes, dislikes int
+		)
+
+		err = rows.Scan(&ruleID, &likes, &dislikes)
+		if err != nil {
+			log.Error().Err(err).Msg("readVoteStats")
+			return err
+		}
+
+		if likes > 0 || dislikes > 0 {
+			ruleStats := getStats(ruleID)
+			ruleStats.Likes = likes
+			ruleStats.Dislikes = dislikes
+		}
+	}
+
+	return rows.Err()
+}
+
+// readDisableStats counts clusters for which the rules are disabled, rules
+// whose time-limited disabling has expired are not counted
+func (storage DBStorage) readDisableStats(
+	since, until time.Time, getStats func(types.RuleID) *RuleFeedbackStats,
+) error {
+	untilClause, args := untilCondition(
+		"disabled_at", until, []interface{}{RuleToggleDisable, time.Now(), since},
+	)
+
+	rows, err := storage.connection.Query(`
+		SELECT rule_id, COUNT(*)
+		FROM cluster_rule_toggle
+		WHERE disabled = $1 AND (disabled_until IS NULL OR disabled_until > $2)
+			AND disabled_at >= $3`+untilClause+`
+		GROUP BY rule_id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			ruleID   types.RuleID
+			disables int
+		)
+
+		err = rows.Scan(&ruleID, &disables)
+		if err != nil {
+			log.Error().Err(err).Msg("readDisableStats")
+			return err
+		}
+
+		getStats(ruleID).Disables = disables
+	}
+
+	return rows.Err()
+}
+
+// readFeedbackMessages reads at most limit most recent non-empty feedback
+// messages of each rule from the table, the most recent messages first
+func (storage DBStorage) readFeedbackMessages(
+	table string, since, until time.Time, limit int, addMessage func(types.RuleID, RuleFeedbackMessage),
+) error {
+	untilClause, args := untilCondition("updated_at", until, []interface{}{since})
+	args = append(args, limit)
+
+	// messages are ranked per rule, so the limit is applied by the database
+	rows, err := storage.connection.Query(`
+		SELECT rule_id, cluster_id, user_id, message, updated_at
+		FROM (
+			SELECT rule_id, cluster_id, user_id, message, updated_at,
+				ROW_NUMBER() OVER (PARTITION BY rule_id ORDER BY updated_at DESC) AS message_rank
+			FROM `+table+`
+			WHERE message <> '' AND updated_at >= $1`+untilClause+`
+		) AS ranked_messages
+		WHERE message_rank <= $`+fmt.Sprint(len(args))+`
+		ORDER BY rule_id, updated_at DESC
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			ruleID  types.RuleID
+			message RuleFeedbackMessage
+			text    sql.NullString
+		)
+
+		err = rows.Scan(&ruleID, &message.ClusterID, &message.UserID, &text, &message.UpdatedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("readFeedbackMessages")
+			return err
+		}
+
+		message.Message = text.String
+		addMessage(ruleID, message)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func mustWriteRuleFeedback(t *testing.T, mockStorage storage.Storage) {
+	mustWriteReport3Rules(t, mockStorage)
+
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, "1", types.UserVoteLike, "",
+	))
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, "2", types.UserVoteDislike, "too noisy",
+	))
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule2ID, "1", types.UserVoteLike, "helpful",
+	))
+	helpers.FailOnError(t, mockStorage.AddFeedbackOnRuleDisable(
+		testdata.ClusterName, testdata.Rule1ID, "2", "not relevant",
+	))
+	helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, storage.RuleToggleDisable,
+	))
+}
+
+func TestDBStorage_GetRuleFeedbackStats(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteRuleFeedback(t, mockStorage)
+
+	stats, err := mockStorage.GetRuleFeedbackStats(time.Time{}, time.Time{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, stats, 2)
+
+	assert.Equal(t, testdata.Rule1ID, stats[0].RuleID)
+	assert.Equal(t, 1, stats[0].Likes)
+	assert.Equal(t, 1, stats[0].Dislikes)
+	assert.Equal(t, 1, stats[0].Disables)
+	assert.Len(t, stats[0].Feedback, 1)
+	assert.Equal(t, "too noisy", stats[0].Feedback[0].Message)
+	assert.Equal(t, types.UserID("2"), stats[0].Feedback[0].UserID)
+	assert.Len(t, stats[0].DisableFeedback, 1)
+	assert.Equal(t, "not relevant", stats[0].DisableFeedback[0].Message)
+
+	assert.Equal(t, testdata.Rule2ID, stats[1].RuleID)
+	assert.Equal(t, 1, stats[1].Likes)
+	assert.Equal(t, 0, stats[1].Dislikes)
+	assert.Equal(t, 0, stats[1].Disables)
+	assert.Len(t, stats[1].Feedback, 1)
+	assert.Empty(t, stats[1].DisableFeedback)
+}
+
+func TestDBStorage_GetRuleFeedbackStats_MessagesLimit(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteRuleFeedback(t, mockStorage)
+
+	stats, err := mockStorage.GetRuleFeedbackStats(time.Time{}, time.Time{}, 0)
+	helpers.FailOnError(t, err)
+	assert.Len(t, stats, 2)
+
+	for _, ruleStats := range stats {
+		assert.Empty(t, ruleStats.Feedback)
+		assert.Empty(t, ruleStats.DisableFeedback)
+	}
+}
+
+func TestDBStorage_GetRuleFeedbackStats_MostRecentMessages(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteRuleFeedback(t, mockStorage)
+
+	time.Sleep(10 * time.Millisecond)
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, "3", types.UserVoteDislike, "still noisy",
+	))
+
+	stats, err := mockStorage.GetRuleFeedbackStats(time.Time{}, time.Time{}, 1)
+	helpers.FailOnError(t, err)
+	assert.Len(t, stats, 2)
+
+	// only the most recent message is returned for each rule
+	assert.Len(t, stats[0].Feedback, 1)
+	assert.Equal(t, "still noisy", stats[0].Feedback[0].Message)
+	assert.Len(t, stats[1].Feedback, 1)
+	assert.Equal(t, "helpful", stats[1].Feedback[0].Message)
+}
+
+func TestDBStorage_GetRuleFeedbackStats_ExpiredDisable(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(
+		testdata.ClusterName, testdata.Rule1ID, time.Now().Add(-time.Minute),
+	))
+	helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(
+		testdata.ClusterName, testdata.Rule2ID, time.Now().Add(time.Hour),
+	))
+
+	stats, err := mockStorage.GetRuleFeedbackStats(time.Time{}, time.Time{}, 10)
+	helpers.FailOnError(t, err)
+
+	// the expired disabling is not counted
+	assert.Len(t, stats, 1)
+	assert.Equal(t, testdata.Rule2ID, stats[0].RuleID)
+	assert.Equal(t, 1, stats[0].Disables)
+}
+
+func TestDBStorage_GetRuleFeedbackStats_TimeWindow(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteRuleFeedback(t, mockStorage)
+
+	stats, err := mockStorage.GetRuleFeedbackStats(time.Time{}, time.Now().Add(-time.Hour), 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, stats)
+
+	stats, err = mockStorage.GetRuleFeedbackStats(time.Now().Add(time.Hour), time.Time{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, stats)
+}
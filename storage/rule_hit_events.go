@@ -0,0 +1,235 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RuleHitEventType represents what happened with a rule hit between two
+// consecutive reports for the same cluster
+type RuleHitEventType string
+
+const (
+	// RuleHitEventNew is used when the rule hit was not present in previous report
+	RuleHitEventNew RuleHitEventType = "new"
+	// RuleHitEventResolved is used when the rule hit is not present in the new report anymore
+	RuleHitEventResolved RuleHitEventType = "resolved"
+	// RuleHitEventChanged is used when the rule hit is present in both reports,
+	// but its template data are different
+	RuleHitEventChanged RuleHitEventType = "changed"
+)
+
+// RuleHitEvent represents one change of rule hits for a cluster
+type RuleHitEvent struct {
+	OrgID         types.OrgID       `json:"org_id"`
+	ClusterName   types.ClusterName `json:"cluster"`
+	RuleFQDN      types.RuleID      `json:"rule_fqdn"`
+	ErrorKey      types.ErrorKey    `json:"error_key"`
+	Type          RuleHitEventType  `json:"type"`
+	TemplateData  interface{}       `json:"details"`
+	LastCheckedAt types.Timestamp   `json:"last_checked_at"`
+}
+
+// ruleHitKey identifies one rule hit in a report
+type ruleHitKey struct {
+	ruleFQDN types.RuleID
+	errorKey types.ErrorKey
+}
+
+// writeRuleHitEvents compares the rule hits stored for the cluster with the
+// new ones and stores the differences as events. It must be called in the same
+// transaction before the old rule hits are deleted, the new rule hits are
+// expected to be unique. Events of rule hits that were not present in the
+// previous report are returned.
+func (storage DBStorage) writeRuleHitEvents(
+	tx *sql.Tx,
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	rules []types.ReportItem,
+	lastCheckedTime time.Time,
+) ([]RuleHitEvent, error) {
+	rows, err := tx.Query(`
+		SELECT rule_fqdn, error_key, template_data
+		FROM rule_hit
+		WHERE org_id = $1 AND cluster_id = $2;
+	`, orgID, clusterName)
+	if err != nil {
+		log.Err(err).Msgf("Unable to read previous rule hits (org: %v, cluster: %v)", orgID, clusterName)
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	previousHits := make(map[ruleHitKey]string)
+
+	for rows.Next() {
+		var (
+			key          ruleHitKey
+			templateData string
+		)
+
+		err = rows.Scan(&key.ruleFQDN, &key.errorKey, &templateData)
+		if err != nil {
+			log.Error().Err(err).Msg("writeRuleHitEvents")
+			return nil, err
+		}
+
+		previousHits[key] = templateData
+	}
+
+	err = rows.Err()
+	if err != nil {
+		log.Err(err).Msgf("Unable to read previous rule hits (org: %v, cluster: %v)", orgID, clusterName)
+		return nil, err
+	}
+
+	var events [][]interface{}
+	newHits := make([]RuleHitEvent, 0)
+
+	addEvent := func(key ruleHitKey, eventType RuleHitEventType, templateData string) {
+		events = append(events, []interface{}{
+			orgID, clusterName, key.ruleFQDN, key.errorKey, eventType, templateData, lastCheckedTime,
+		})
+
+		if eventType == RuleHitEventNew {
+			newHits = append(newHits, RuleHitEvent{
+				OrgID:         orgID,
+				ClusterName:   clusterName,
+				RuleFQDN:      key.ruleFQDN,
+				ErrorKey:      key.errorKey,
+				Type:          eventType,
+				TemplateData:  parseTemplateData([]byte(templateData)),
+				LastCheckedAt: types.Timestamp(lastCheckedTime.UTC().Format(time.RFC3339)),
+			})
+		}
+	}
+
+	for _, rule := range rules {
+		key := ruleHitKey{ruleFQDN: rule.Module, errorKey: rule.ErrorKey}
+		templateData := string(rule.TemplateData)
+
+		previousTemplateData, found := previousHits[key]
+		delete(previousHits, key)
+
+		switch {
+		case !found:
+			addEvent(key, RuleHitEventNew, templateData)
+		case previousTemplateData != templateData:
+			addEvent(key, RuleHitEventChanged, templateData)
+		}
+	}
+
+	// all remaining rule hits are not present in the new report
+	for key, templateData := range previousHits {
+		addEvent(key, RuleHitEventResolved, templateData)
+	}
+
+	err = insertRows(
+		tx,
+		"INSERT INTO rule_hit_event(org_id, cluster_id, rule_fqdn, error_key, event_type, template_data, last_checked_at)",
+		"ON CONFLICT (cluster_id, org_id, rule_fqdn, error_key, last_checked_at) DO NOTHING",
+		events,
+	)
+	if err != nil {
+		log.Err(err).Msgf("Unable to insert the rule hit events (org: %v, cluster: %v)", orgID, clusterName)
+		return nil, err
+	}
+
+	return newHits, nil
+}
+
+// ReadRuleHitEventsForCluster reads all rule hit events for selected cluster
+// that happened at the given time or later and before until, if it is not
+// zero. The oldest event goes first.
+func (storage DBStorage) ReadRuleHitEventsForCluster(
+	orgID types.OrgID, clusterName types.ClusterName, since, until time.Time,
+) ([]RuleHitEvent, error) {
+	untilClause, args := untilCondition("last_checked_at", until, []interface{}{orgID, clusterName, since})
+
+	rows, err := storage.connection.Query(`
+		SELECT org_id, cluster_id, rule_fqdn, error_key, event_type, template_data, last_checked_at
+		FROM rule_hit_event
+		WHERE org_id = $1 AND cluster_id = $2 AND last_checked_at >= $3`+untilClause+`
+		ORDER BY last_checked_at, rule_fqdn, error_key;
+	`, args...)
+	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	if err != nil {
+		return []RuleHitEvent{}, err
+	}
+	defer closeRows(rows)
+
+	return parseRuleHitEventRows(rows)
+}
+
+// ReadRuleHitEventsForOrg reads all rule hit events for all clusters that
+// belong to the selected organization that happened at the given time or
+// later and before until, if it is not zero. The oldest event goes first.
+func (storage DBStorage) ReadRuleHitEventsForOrg(
+	orgID types.OrgID, since, until time.Time,
+) ([]RuleHitEvent, error) {
+	untilClause, args := untilCondition("last_checked_at", until, []interface{}{orgID, since})
+
+	rows, err := storage.connection.Query(`
+		SELECT org_id, cluster_id, rule_fqdn, error_key, event_type, template_data, last_checked_at
+		FROM rule_hit_event
+		WHERE org_id = $1 AND last_checked_at >= $2`+untilClause+`
+		ORDER BY last_checked_at, cluster_id, rule_fqdn, error_key;
+	`, args...)
+	err = types.ConvertDBError(err, orgID)
+	if err != nil {
+		return []RuleHitEvent{}, err
+	}
+	defer closeRows(rows)
+
+	return parseRuleHitEventRows(rows)
+}
+
+func parseRuleHitEventRows(rows *sql.Rows) ([]RuleHitEvent, error) {
+	events := make([]RuleHitEvent, 0)
+
+	for rows.Next() {
+		var (
+			event             RuleHitEvent
+			templateDataBytes []byte
+			lastChecked       time.Time
+		)
+
+		err := rows.Scan(
+			&event.OrgID,
+			&event.ClusterName,
+			&event.RuleFQDN,
+			&event.ErrorKey,
+			&event.Type,
+			&templateDataBytes,
+			&lastChecked,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("parseRuleHitEventRows")
+			return events, err
+		}
+
+		event.TemplateData = parseTemplateData(templateDataBytes)
+		event.LastCheckedAt = types.Timestamp(lastChecked.UTC().Format(time.RFC3339))
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
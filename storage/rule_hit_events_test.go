@@ -0,0 +1,179 @@
+// Copyright 2021 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestDBStorageReadRuleHitEventsForClusterEmpty(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	events, err := mockStorage.ReadRuleHitEventsForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, events)
+}
+
+func TestDBStorageReadRuleHitEvents(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	olderTime := time.Now().UTC().Add(-time.Hour)
+	newerTime := olderTime.Add(30 * time.Minute)
+
+	// both rule hits are new for the cluster
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		olderTime,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// first rule hit is changed, second one is resolved and third one is new
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report2Rules,
+		[]types.ReportItem{
+			{
+				Module:       testdata.Rule1ID,
+				ErrorKey:     testdata.ErrorKey1,
+				TemplateData: json.RawMessage(testdata.Rule3ExtraData),
+			},
+			{
+				Module:       testdata.Rule3ID,
+				ErrorKey:     testdata.ErrorKey3,
+				TemplateData: json.RawMessage(testdata.Rule3ExtraData),
+			},
+		},
+		newerTime,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	events, err := mockStorage.ReadRuleHitEventsForCluster(testdata.OrgID, testdata.ClusterName, newerTime, time.Time{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, testdata.Rule1ID, events[0].RuleFQDN)
+	assert.Equal(t, storage.RuleHitEventChanged, events[0].Type)
+	assert.Equal(t, testdata.Rule2ID, events[1].RuleFQDN)
+	assert.Equal(t, storage.RuleHitEventResolved, events[1].Type)
+	assert.Equal(t, testdata.Rule3ID, events[2].RuleFQDN)
+	assert.Equal(t, storage.RuleHitEventNew, events[2].Type)
+
+	events, err = mockStorage.ReadRuleHitEventsForOrg(testdata.OrgID, time.Time{}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, events, 5)
+	for _, event := range events[:2] {
+		assert.Equal(t, storage.RuleHitEventNew, event.Type)
+		assert.Equal(t, testdata.ClusterName, event.ClusterName)
+	}
+
+	// only the events of the older report are returned
+	events, err = mockStorage.ReadRuleHitEventsForOrg(testdata.OrgID, time.Time{}, newerTime)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, events, 2)
+	for _, event := range events {
+		assert.Equal(t, storage.RuleHitEventNew, event.Type)
+	}
+}
+
+func TestDBStorageReadRuleHitEventsSameReport(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	olderTime := time.Now().UTC().Add(-time.Hour)
+	newerTime := olderTime.Add(30 * time.Minute)
+
+	for _, lastChecked := range []time.Time{olderTime, newerTime} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID,
+			testdata.ClusterName,
+			testdata.Report3Rules,
+			testdata.Report3RulesParsed,
+			lastChecked,
+			testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	// nothing has changed in the newer report
+	events, err := mockStorage.ReadRuleHitEventsForCluster(testdata.OrgID, testdata.ClusterName, newerTime, time.Time{})
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, events)
+}
+
+func TestDBStorageReadRuleHitEventsClosedStorage(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	// we need to close storage right now
+	closer()
+
+	_, err := mockStorage.ReadRuleHitEventsForCluster(testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{})
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadRuleHitEventsForOrg(testdata.OrgID, time.Time{}, time.Time{})
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageRuleHitEventsDuplicateRuleHits(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	olderTime := time.Now().UTC().Add(-time.Hour)
+	newerTime := olderTime.Add(30 * time.Minute)
+
+	ruleHit := types.ReportItem{
+		Module:       testdata.Rule1ID,
+		ErrorKey:     testdata.ErrorKey1,
+		TemplateData: json.RawMessage(testdata.Rule1ExtraData),
+	}
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules,
+		[]types.ReportItem{ruleHit}, olderTime, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// the same rule hit reported twice is neither new nor changed
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules,
+		[]types.ReportItem{ruleHit, ruleHit}, newerTime, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	events, err := mockStorage.ReadRuleHitEventsForCluster(testdata.OrgID, testdata.ClusterName, newerTime, time.Time{})
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, events)
+}
@@ -35,26 +35,45 @@ const (
 	RuleToggleEnable RuleToggle = 0
 )
 
-// ClusterRuleToggle represents a record from rule_cluster_toggle
+// ClusterRuleToggle represents a record from rule_cluster_toggle.
+// DisabledUntil is set for rules disabled only for limited time.
 type ClusterRuleToggle struct {
-	ClusterID  types.ClusterName
-	RuleID     types.RuleID
-	Disabled   RuleToggle
-	DisabledAt sql.NullTime
-	EnabledAt  sql.NullTime
-	UpdatedAt  sql.NullTime
+	ClusterID     types.ClusterName
+	RuleID        types.RuleID
+	Disabled      RuleToggle
+	DisabledAt    sql.NullTime
+	EnabledAt     sql.NullTime
+	UpdatedAt     sql.NullTime
+	DisabledUntil sql.NullTime
 }
 
-// ToggleRuleForCluster toggles rule for specified cluster
-func (storage DBStorage) ToggleRuleForCluster(
-	clusterID types.ClusterName, ruleID types.RuleID, ruleToggle RuleToggle,
-) error {
+// expired checks whether time-limited disabling of the rule is over,
+// so the rule has to be treated as enabled again
+func (toggle *ClusterRuleToggle) expired(now time.Time) bool {
+	return toggle.Disabled == RuleToggleDisable &&
+		toggle.DisabledUntil.Valid && !toggle.DisabledUntil.Time.After(now)
+}
 
-	var query string
-	var enabledAt, disabledAt, updatedAt sql.NullTime
+// upsertRuleToggleQuery inserts or updates a record in cluster_rule_toggle
+const upsertRuleToggleQuery = `
+	INSERT INTO cluster_rule_toggle(
+		cluster_id, rule_id, disabled, disabled_at, enabled_at, updated_at, disabled_until
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (cluster_id, rule_id) DO UPDATE SET
+		disabled = $3,
+		disabled_at = $4,
+		enabled_at = $5,
+		updated_at = $6,
+		disabled_until = $7
+`
 
-	now := time.Now()
-	updatedAt = sql.NullTime{Time: now, Valid: true}
+// ruleToggleTimestamps returns disabled_at and enabled_at values for the
+// given toggle state and time of update
+func ruleToggleTimestamps(
+	ruleToggle RuleToggle, now time.Time,
+) (disabledAt, enabledAt sql.NullTime, err error) {
+	updatedAt := sql.NullTime{Time: now, Valid: true}
 
 	switch ruleToggle {
 	case RuleToggleDisable:
@@ -62,41 +81,264 @@ func (storage DBStorage) ToggleRuleForCluster(
 	case RuleToggleEnable:
 		enabledAt = updatedAt
 	default:
-		return fmt.Errorf("Unexpected rule toggle value")
-	}
-
-	query = `
-		INSERT INTO cluster_rule_toggle(
-			cluster_id, rule_id, disabled, disabled_at, enabled_at, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (cluster_id, rule_id) DO UPDATE SET
-			disabled = $3,
-			disabled_at = $4,
-			enabled_at = $5,
-			updated_at = $6
-	`
+		err = fmt.Errorf("Unexpected rule toggle value")
+	}
 
-	_, err := storage.connection.Exec(
-		query,
-		clusterID,
-		ruleID,
-		ruleToggle,
-		disabledAt,
-		enabledAt,
-		now,
+	return
+}
+
+// ToggleRuleForCluster toggles rule for specified cluster. Time limit of
+// previous disabling of the rule is cleared.
+func (storage DBStorage) ToggleRuleForCluster(
+	clusterID types.ClusterName, ruleID types.RuleID, ruleToggle RuleToggle,
+) error {
+	return storage.toggleRuleForCluster(clusterID, ruleID, ruleToggle, sql.NullTime{})
+}
+
+// DisableRuleForClusterUntil disables rule for specified cluster until the
+// given time, the rule is treated as enabled again after that
+func (storage DBStorage) DisableRuleForClusterUntil(
+	clusterID types.ClusterName, ruleID types.RuleID, until time.Time,
+) error {
+	return storage.toggleRuleForCluster(
+		clusterID, ruleID, RuleToggleDisable, sql.NullTime{Time: until.UTC(), Valid: true},
 	)
+}
+
+// toggleRuleForCluster contains shared functionality for toggling rule
+// with and without time limit
+func (storage DBStorage) toggleRuleForCluster(
+	clusterID types.ClusterName, ruleID types.RuleID, ruleToggle RuleToggle, disabledUntil sql.NullTime,
+) error {
+	now := time.Now()
+
+	disabledAt, enabledAt, err := ruleToggleTimestamps(ruleToggle, now)
 	if err != nil {
-		log.Error().Err(err).Msg("Error during execution SQL exec for cluster rule toggle")
 		return err
 	}
 
-	return nil
+	tx, err := storage.connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = storage.upsertRuleToggle(
+		tx, clusterOrgForAudit(clusterID), clusterID, ruleID, ruleToggle, disabledAt, enabledAt, now, disabledUntil,
+	)
+
+	finishTransaction(tx, err)
+
+	return err
+}
+
+// upsertRuleToggle writes the rule toggle for the cluster in the transaction
+// and records the change into the audit log
+func (storage DBStorage) upsertRuleToggle(
+	tx *sql.Tx,
+	org auditOrg,
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	ruleToggle RuleToggle,
+	disabledAt, enabledAt sql.NullTime,
+	updatedAt time.Time,
+	disabledUntil sql.NullTime,
+) error {
+	readToggle := func(tx *sql.Tx) (interface{}, error) {
+		toggle, err := readClusterRuleToggle(tx, clusterID, ruleID)
+		if err != nil {
+			return optionalAuditItem(nil, err)
+		}
+
+		value := ruleToggleAuditValue{Disabled: toggle.Disabled == RuleToggleDisable}
+		if toggle.DisabledUntil.Valid {
+			value.DisabledUntil = &toggle.DisabledUntil.Time
+		}
+
+		return value, nil
+	}
+
+	return storage.auditChange(
+		tx, org, AuditActionRuleToggle, fmt.Sprintf("%v/%v", clusterID, ruleID), readToggle,
+		func(tx *sql.Tx) error {
+			_, err := tx.Exec(
+				upsertRuleToggleQuery,
+				clusterID,
+				ruleID,
+				ruleToggle,
+				disabledAt,
+				enabledAt,
+				updatedAt,
+				disabledUntil,
+			)
+			if err != nil {
+				log.Error().Err(err).Msg("Error during execution SQL exec for cluster rule toggle")
+			}
+
+			return err
+		},
+	)
+}
+
+// ToggleRuleForOrgClusters toggles rule for the selected clusters of the
+// organization in one transaction. If any of the selected clusters doesn't
+// belong to the organization, nothing is changed and ItemNotFoundError is
+// returned. Nothing is toggled when no clusters are given, use
+// ToggleRuleForAllOrgClusters to toggle the rule for all clusters. Toggled
+// clusters are returned.
+func (storage DBStorage) ToggleRuleForOrgClusters(
+	orgID types.OrgID,
+	clusterIDs []types.ClusterName,
+	ruleID types.RuleID,
+	ruleToggle RuleToggle,
+) ([]types.ClusterName, error) {
+	return storage.toggleRuleForOrgClusters(
+		orgID, ruleID, ruleToggle,
+		func(orgClusters []types.ClusterName) ([]types.ClusterName, error) {
+			return selectOrgClusters(orgClusters, clusterIDs)
+		},
+	)
 }
 
-// GetFromClusterRuleToggle gets a rule from cluster_rule_toggle
+// ToggleRuleForAllOrgClusters toggles rule for all clusters of the
+// organization in one transaction. Toggled clusters are returned.
+func (storage DBStorage) ToggleRuleForAllOrgClusters(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	ruleToggle RuleToggle,
+) ([]types.ClusterName, error) {
+	return storage.toggleRuleForOrgClusters(
+		orgID, ruleID, ruleToggle,
+		func(orgClusters []types.ClusterName) ([]types.ClusterName, error) {
+			return orgClusters, nil
+		},
+	)
+}
+
+// toggleRuleForOrgClusters toggles rule for clusters chosen by selectClusters
+// from all clusters of the organization in one transaction
+func (storage DBStorage) toggleRuleForOrgClusters(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	ruleToggle RuleToggle,
+	selectClusters func(orgClusters []types.ClusterName) ([]types.ClusterName, error),
+) ([]types.ClusterName, error) {
+	now := time.Now()
+
+	disabledAt, enabledAt, err := ruleToggleTimestamps(ruleToggle, now)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := storage.connection.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var toggledClusters []types.ClusterName
+
+	err = func(tx *sql.Tx) error {
+		orgClusters, err := readClustersForOrg(tx, orgID)
+		if err != nil {
+			return err
+		}
+
+		toggledClusters, err = selectClusters(orgClusters)
+		if err != nil {
+			return err
+		}
+
+		for _, clusterID := range toggledClusters {
+			err = storage.upsertRuleToggle(
+				tx, orgForAudit(orgID), clusterID, ruleID, ruleToggle, disabledAt, enabledAt, now, sql.NullTime{},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}(tx)
+
+	finishTransaction(tx, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toggledClusters, nil
+}
+
+// readClustersForOrg reads names of all clusters of the organization
+func readClustersForOrg(tx *sql.Tx, orgID types.OrgID) ([]types.ClusterName, error) {
+	rows, err := tx.Query("SELECT cluster FROM report WHERE org_id = $1 ORDER BY cluster", orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	clusters := make([]types.ClusterName, 0)
+	for rows.Next() {
+		var clusterName types.ClusterName
+
+		if err := rows.Scan(&clusterName); err != nil {
+			log.Error().Err(err).Msg("readClustersForOrg")
+			return nil, err
+		}
+
+		clusters = append(clusters, clusterName)
+	}
+
+	return clusters, rows.Err()
+}
+
+// selectOrgClusters returns requested clusters without duplicates,
+// ItemNotFoundError is returned for clusters of other organizations
+func selectOrgClusters(
+	orgClusters, requested []types.ClusterName,
+) ([]types.ClusterName, error) {
+	known := make(map[types.ClusterName]bool, len(orgClusters))
+	for _, clusterName := range orgClusters {
+		known[clusterName] = true
+	}
+
+	selected := make([]types.ClusterName, 0, len(requested))
+	seen := make(map[types.ClusterName]bool, len(requested))
+
+	for _, clusterName := range requested {
+		if !known[clusterName] {
+			return nil, &types.ItemNotFoundError{ItemID: clusterName}
+		}
+
+		if !seen[clusterName] {
+			seen[clusterName] = true
+			selected = append(selected, clusterName)
+		}
+	}
+
+	return selected, nil
+}
+
+// GetFromClusterRuleToggle gets a rule from cluster_rule_toggle. Rule whose
+// time-limited disabling has expired is returned as enabled.
 func (storage DBStorage) GetFromClusterRuleToggle(
 	clusterID types.ClusterName, ruleID types.RuleID,
+) (*ClusterRuleToggle, error) {
+	disabledRule, err := readClusterRuleToggle(storage.connection, clusterID, ruleID)
+	if err != nil {
+		return disabledRule, err
+	}
+
+	if disabledRule.expired(time.Now()) {
+		disabledRule.Disabled = RuleToggleEnable
+	}
+
+	return disabledRule, nil
+}
+
+// readClusterRuleToggle reads a rule from cluster_rule_toggle inside or
+// outside of transaction, expiration of the time limit is not checked
+func readClusterRuleToggle(
+	db queryRower, clusterID types.ClusterName, ruleID types.RuleID,
 ) (*ClusterRuleToggle, error) {
 	var disabledRule ClusterRuleToggle
 
@@ -110,7 +352,8 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 		disabled,
 		disabled_at,
 		enabled_at,
-		updated_at
+		updated_at,
+		disabled_until
 	FROM
 		cluster_rule_toggle
 	WHERE
@@ -121,7 +364,7 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 	LIMIT 1
 	`
 
-	err := storage.connection.QueryRow(
+	err := db.QueryRow(
 		query,
 		clusterID,
 		ruleID,
@@ -132,6 +375,7 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 		&disabledRule.DisabledAt,
 		&disabledRule.EnabledAt,
 		&disabledRule.UpdatedAt,
+		&disabledRule.DisabledUntil,
 	)
 	if err == sql.ErrNoRows {
 		return nil, &types.ItemNotFoundError{ItemID: ruleID}
@@ -140,21 +384,64 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 	return &disabledRule, err
 }
 
-// GetTogglesForRules gets enable/disable toggle for rules
+// GetTogglesForRules gets enable/disable toggle for rules. Rules whose
+// time-limited disabling has expired are treated as enabled.
 func (storage DBStorage) GetTogglesForRules(
 	clusterID types.ClusterName, rulesReport []types.RuleOnReport,
 ) (map[types.RuleID]bool, error) {
+	toggles := make(map[types.RuleID]bool)
+
+	ruleToggles, err := storage.readTogglesForRules(clusterID, rulesReport)
+	if err != nil {
+		return toggles, err
+	}
+
+	now := time.Now()
+	for i := range ruleToggles {
+		toggle := &ruleToggles[i]
+		toggles[toggle.RuleID] = toggle.Disabled == RuleToggleDisable && !toggle.expired(now)
+	}
+
+	return toggles, nil
+}
+
+// GetDisabledUntilForRules gets time limits of rules that are currently
+// disabled only for limited time
+func (storage DBStorage) GetDisabledUntilForRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport,
+) (map[types.RuleID]time.Time, error) {
+	disabledUntil := make(map[types.RuleID]time.Time)
+
+	ruleToggles, err := storage.readTogglesForRules(clusterID, rulesReport)
+	if err != nil {
+		return disabledUntil, err
+	}
+
+	now := time.Now()
+	for i := range ruleToggles {
+		toggle := &ruleToggles[i]
+		if toggle.Disabled == RuleToggleDisable && toggle.DisabledUntil.Valid && !toggle.expired(now) {
+			disabledUntil[toggle.RuleID] = toggle.DisabledUntil.Time
+		}
+	}
+
+	return disabledUntil, nil
+}
+
+// readTogglesForRules reads toggles of the rules for the cluster
+func (storage DBStorage) readTogglesForRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport,
+) ([]ClusterRuleToggle, error) {
 	ruleIDs := make([]string, 0)
 	for _, rule := range rulesReport {
 		ruleIDs = append(ruleIDs, string(rule.Module))
 	}
 
-	toggles := make(map[types.RuleID]bool)
-
 	query := `
 	SELECT
 		rule_id,
-		disabled
+		disabled,
+		disabled_until
 	FROM
 		cluster_rule_toggle
 	WHERE
@@ -166,24 +453,22 @@ func (storage DBStorage) GetTogglesForRules(
 
 	rows, err := storage.connection.Query(query, clusterID)
 	if err != nil {
-		return toggles, err
+		return nil, err
 	}
 	defer closeRows(rows)
 
-	for rows.Next() {
-		var (
-			ruleID   types.RuleID
-			disabled bool
-		)
+	toggles := make([]ClusterRuleToggle, 0)
 
-		err = rows.Scan(&ruleID, &disabled)
+	for rows.Next() {
+		toggle := ClusterRuleToggle{ClusterID: clusterID}
 
+		err = rows.Scan(&toggle.RuleID, &toggle.Disabled, &toggle.DisabledUntil)
 		if err != nil {
 			log.Error().Err(err).Msg("GetFromClusterRulesToggle")
 			return nil, err
 		}
 
-		toggles[ruleID] = disabled
+		toggles = append(toggles, toggle)
 	}
 
 	return toggles, nil
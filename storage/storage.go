@@ -31,15 +31,16 @@ import (
 	sql_driver "database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/Shopify/sarama"
 	"github.com/lib/pq"
 	_ "github.com/lib/pq" // PostgreSQL database driver
 	"github.com/mattn/go-sqlite3"
 	_ "github.com/mattn/go-sqlite3" // SQLite database driver
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/migration"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -53,6 +54,10 @@ type Storage interface {
 	ListOfClustersForOrg(
 		orgID types.OrgID, timeLimit time.Time) ([]types.ClusterName, error,
 	)
+	ListOfOrgsPage(filter ClusterListFilter, pagination ListPagination) ([]types.OrgID, int, error)
+	ListOfClustersForOrgPage(
+		orgID types.OrgID, filter ClusterListFilter, pagination ListPagination,
+	) ([]types.ClusterName, int, error)
 	ReadReportForCluster(
 		orgID types.OrgID, clusterName types.ClusterName) ([]types.RuleOnReport, types.Timestamp, error,
 	)
@@ -73,6 +78,18 @@ type Storage interface {
 		collectedAtTime time.Time,
 		kafkaOffset types.KafkaOffset,
 	) error
+	WriteReportsForClusters(reports []ReportForCluster) ([]WrittenReport, error)
+	ReadReportHistoryForCluster(
+		orgID types.OrgID, clusterName types.ClusterName, since, until time.Time,
+	) ([]ReportHistoryItem, error)
+	ReadRuleHitEventsForCluster(
+		orgID types.OrgID, clusterName types.ClusterName, since, until time.Time,
+	) ([]RuleHitEvent, error)
+	ReadRuleHitEventsForOrg(orgID types.OrgID, since, until time.Time) ([]RuleHitEvent, error)
+	ReadRuleHitsForOrg(orgID types.OrgID) ([]OrgRuleHits, error)
+	ReadClustersHitByRule(
+		orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+	) ([]ClusterHitByRule, error)
 	ReportsCount() (int, error)
 	VoteOnRule(
 		clusterID types.ClusterName,
@@ -106,6 +123,17 @@ type Storage interface {
 		ruleID types.RuleID,
 		ruleToggle RuleToggle,
 	) error
+	ToggleRuleForOrgClusters(
+		orgID types.OrgID,
+		clusterIDs []types.ClusterName,
+		ruleID types.RuleID,
+		ruleToggle RuleToggle,
+	) ([]types.ClusterName, error)
+	ToggleRuleForAllOrgClusters(
+		orgID types.OrgID,
+		ruleID types.RuleID,
+		ruleToggle RuleToggle,
+	) ([]types.ClusterName, error)
 	GetFromClusterRuleToggle(
 		types.ClusterName,
 		types.RuleID,
@@ -114,12 +142,34 @@ type Storage interface {
 		types.ClusterName,
 		[]types.RuleOnReport,
 	) (map[types.RuleID]bool, error)
+	DisableRuleForClusterUntil(
+		clusterID types.ClusterName,
+		ruleID types.RuleID,
+		until time.Time,
+	) error
+	GetDisabledUntilForRules(
+		types.ClusterName,
+		[]types.RuleOnReport,
+	) (map[types.RuleID]time.Time, error)
 	DeleteFromRuleClusterToggle(
 		clusterID types.ClusterName,
 		ruleID types.RuleID,
 	) error
+	AckRuleForOrg(
+		orgID types.OrgID,
+		ruleID types.RuleID,
+		userID types.UserID,
+		justification string,
+	) error
+	GetRuleAckForOrg(orgID types.OrgID, ruleID types.RuleID) (*RuleAck, error)
+	ReadRuleAcksForOrg(orgID types.OrgID) ([]RuleAck, error)
+	DeleteRuleAckForOrg(orgID types.OrgID, ruleID types.RuleID) error
 	GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error)
-	WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error
+	WriteConsumerError(msg *broker.Message, consumerErr error) error
+	ReadConsumerErrors(filter ConsumerErrorFilter) ([]ConsumerError, error)
+	MarkConsumerErrorReplayed(topic string, partition int32, offset int64, replayErr error) error
+	ReadConsumerError(topic string, partition int32, offset int64) (ConsumerError, error)
+	DeleteConsumerErrors(filter ConsumerErrorFilter) (int64, error)
 	GetUserFeedbackOnRules(
 		clusterID types.ClusterName,
 		rulesReport []types.RuleOnReport,
@@ -131,6 +181,15 @@ type Storage interface {
 		userID types.UserID,
 	) (map[types.RuleID]UserFeedbackOnRule, error)
 	DoesClusterExist(clusterID types.ClusterName) (bool, error)
+	WithAuditInfo(info AuditInfo) Storage
+	ReadAuditLog(filter AuditLogFilter) ([]AuditLogEntry, error)
+	GetRuleFeedbackStats(since, until time.Time, messagesLimit int) ([]RuleFeedbackStats, error)
+	ApplyRetentionPolicy(reportsBefore, consumerErrorsBefore time.Time, dryRun bool) (RetentionResult, error)
+	EraseOrgData(orgID types.OrgID) (ErasureResult, error)
+	EraseClusterData(clusterName types.ClusterName) (ErasureResult, error)
+	WriteClusterMetadata(metadata ClusterMetadata) error
+	ReadClusterMetadata(orgID types.OrgID, clusterName types.ClusterName) (ClusterMetadata, error)
+	ReadOrgAllowlist() ([]types.OrgID, error)
 }
 
 // DBStorage is an implementation of Storage interface that use selected SQL like database
@@ -140,8 +199,11 @@ type Storage interface {
 type DBStorage struct {
 	connection   *sql.DB
 	dbDriverType types.DBDriver
-	// clusterLastCheckedDict is a dictionary of timestamps when the clusters were last checked.
-	clustersLastChecked map[types.ClusterName]time.Time
+	// clustersLastChecked is a cache of timestamps when the clusters were last checked.
+	clustersLastChecked *clustersLastCheckedCache
+	// auditInfo identifies the caller whose changes are recorded into the
+	// audit log, nil means that the changes are not audited.
+	auditInfo *AuditInfo
 }
 
 // New function creates and initializes a new instance of Storage interface
@@ -162,18 +224,60 @@ func New(configuration Configuration) (*DBStorage, error) {
 		return nil, err
 	}
 
-	return NewFromConnection(connection, driverType), nil
+	return newFromConnection(connection, driverType, configuration.ClustersLastCheckedCacheSize), nil
 }
 
 // NewFromConnection function creates and initializes a new instance of Storage interface from prepared connection
 func NewFromConnection(connection *sql.DB, dbDriverType types.DBDriver) *DBStorage {
+	return newFromConnection(connection, dbDriverType, DefaultClustersLastCheckedCacheSize)
+}
+
+// newFromConnection function creates and initializes a new instance of
+// Storage interface with cache of given size for last checked timestamps
+func newFromConnection(connection *sql.DB, dbDriverType types.DBDriver, cacheSize int) *DBStorage {
 	return &DBStorage{
 		connection:          connection,
 		dbDriverType:        dbDriverType,
-		clustersLastChecked: map[types.ClusterName]time.Time{},
+		clustersLastChecked: newClustersLastCheckedCache(cacheSize),
 	}
 }
 
+// getClusterLastChecked returns timestamp of the latest report written for
+// the cluster, false is returned when no report is known for the cluster.
+// Clusters evicted from the cache are looked up in the database.
+func (storage DBStorage) getClusterLastChecked(clusterName types.ClusterName) (time.Time, bool) {
+	if lastChecked, exists := storage.clustersLastChecked.get(clusterName); exists {
+		return lastChecked, true
+	}
+
+	var lastChecked time.Time
+	err := storage.connection.QueryRow(
+		"SELECT last_checked_at FROM report WHERE cluster = $1;", clusterName,
+	).Scan(&lastChecked)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to read last checked timestamp for cluster %s", clusterName)
+		return time.Time{}, false
+	}
+
+	storage.clustersLastChecked.set(clusterName, lastChecked)
+	return lastChecked, true
+}
+
+// setClusterLastChecked remembers timestamp of the latest report written for
+// the cluster
+func (storage DBStorage) setClusterLastChecked(clusterName types.ClusterName, lastChecked time.Time) {
+	storage.clustersLastChecked.set(clusterName, lastChecked)
+}
+
+// forgetClustersLastChecked evicts the clusters whose reports were deleted
+// from the cache of last checked timestamps
+func (storage DBStorage) forgetClustersLastChecked(clusterNames ...types.ClusterName) {
+	storage.clustersLastChecked.remove(clusterNames...)
+}
+
 // initAndGetDriver initializes driver(with logs if logSQLQueries is true),
 // checks if it's supported and returns driver type, driver name, dataSource and error
 func initAndGetDriver(configuration Configuration) (driverType types.DBDriver, driverName string, dataSource string, err error) {
@@ -222,8 +326,18 @@ func (storage DBStorage) MigrateToLatest() error {
 // Init performs all database initialization
 // tasks necessary for further service operation.
 func (storage DBStorage) Init() error {
-	// Read clusterName:LastChecked dictionary from DB.
-	rows, err := storage.connection.Query("SELECT cluster, last_checked_at FROM report;")
+	// Preload timestamps of the most recently checked clusters into the cache,
+	// the remaining ones are read from DB on demand. The oldest ones go first
+	// so the most recent ones end up as the most recently used in the cache.
+	rows, err := storage.connection.Query(`
+		SELECT cluster, last_checked_at FROM (
+			SELECT cluster, last_checked_at FROM report
+			ORDER BY last_checked_at DESC
+			LIMIT $1
+		) AS recent
+		ORDER BY last_checked_at ASC;`,
+		storage.clustersLastChecked.size,
+	)
 	if err != nil {
 		return err
 	}
@@ -241,7 +355,7 @@ func (storage DBStorage) Init() error {
 			return err
 		}
 
-		storage.clustersLastChecked[clusterName] = lastChecked
+		storage.setClusterLastChecked(clusterName, lastChecked)
 	}
 
 	// Not using defer to close the rows here to:
@@ -278,6 +392,26 @@ func closeRows(rows *sql.Rows) {
 	_ = rows.Close()
 }
 
+// likeEscaper escapes wildcards of LIKE patterns, patterns have to be used
+// with ESCAPE '\' clause
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike returns the value escaped to be matched literally in LIKE pattern
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// untilCondition returns SQL condition limiting the column to times before
+// until together with the query arguments, nothing is added when until is zero
+func untilCondition(column string, until time.Time, args []interface{}) (string, []interface{}) {
+	if until.IsZero() {
+		return "", args
+	}
+
+	args = append(args, until)
+	return fmt.Sprintf(" AND %s < $%d", column, len(args)), args
+}
+
 // ListOfOrgs reads list of all organizations that have at least one cluster report
 func (storage DBStorage) ListOfOrgs() ([]types.OrgID, error) {
 	orgs := make([]types.OrgID, 0)
@@ -600,20 +734,15 @@ func (storage DBStorage) getReportUpsertQuery() string {
 	`
 }
 
-func (storage DBStorage) getRuleHitUpsertQuery() string {
+// getRuleHitUpsertClauses returns insert and conflict clauses of the
+// multi-row UPSERT query for writing rule hits into the database
+func (storage DBStorage) getRuleHitUpsertClauses() (string, string) {
 	if storage.dbDriverType == types.DBDriverSQLite3 {
-		return `
-			INSERT OR REPLACE INTO rule_hit(org_id, cluster_id, rule_fqdn, error_key, template_data)
-			VALUES ($1, $2, $3, $4, $5)
-		`
+		return "INSERT OR REPLACE INTO rule_hit(org_id, cluster_id, rule_fqdn, error_key, template_data)", ""
 	}
 
-	return `
-		INSERT INTO rule_hit(org_id, cluster_id, rule_fqdn, error_key, template_data)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (org_id, cluster_id, rule_fqdn, error_key)
-		DO UPDATE SET template_data = $4
-	`
+	return "INSERT INTO rule_hit(org_id, cluster_id, rule_fqdn, error_key, template_data)",
+		"ON CONFLICT (org_id, cluster_id, rule_fqdn, error_key) DO UPDATE SET template_data = EXCLUDED.template_data"
 }
 
 func (storage DBStorage) updateReport(
@@ -624,38 +753,105 @@ func (storage DBStorage) updateReport(
 	rules []types.ReportItem,
 	lastCheckedTime time.Time,
 	kafkaOffset types.KafkaOffset,
-) error {
+) ([]RuleHitEvent, error) {
 	// Get the UPSERT query for writing a report into the database.
 	reportUpsertQuery := storage.getReportUpsertQuery()
 
-	// Get the UPSERT query for writing a rule into the database.
-	ruleUpsertQuery := storage.getRuleHitUpsertQuery()
+	// Get the UPSERT clauses for writing rules into the database.
+	ruleInsertClause, ruleConflictClause := storage.getRuleHitUpsertClauses()
+
+	// The same rule hit can't be stored twice, the last one wins.
+	rules = uniqueRuleHits(rules)
+
+	// Compare the previous rule hits with the new ones before they are deleted.
+	newHits, err := storage.writeRuleHitEvents(tx, orgID, clusterName, rules, lastCheckedTime)
+	if err != nil {
+		return nil, err
+	}
 
 	deleteQuery := "DELETE FROM rule_hit WHERE org_id = $1 AND cluster_id = $2;"
-	_, err := tx.Exec(deleteQuery, orgID, clusterName)
+	_, err = tx.Exec(deleteQuery, orgID, clusterName)
 	if err != nil {
 		log.Err(err).Msgf("Unable to remove previous cluster reports (org: %v, cluster: %v)", orgID, clusterName)
-		return err
+		return nil, err
 	}
 
 	// Perform the report upsert.
 	reportedAtTime := time.Now()
 
+	ruleHits := make([][]interface{}, 0, len(rules))
 	for _, rule := range rules {
-		_, err = tx.Exec(ruleUpsertQuery, orgID, clusterName, rule.Module, rule.ErrorKey, string(rule.TemplateData))
-		if err != nil {
-			log.Err(err).Msgf("Unable to upsert the cluster report (org: %v, cluster: %v)", orgID, clusterName)
-			return err
-		}
+		ruleHits = append(ruleHits, []interface{}{
+			orgID, clusterName, rule.Module, rule.ErrorKey, string(rule.TemplateData),
+		})
+	}
+
+	err = insertRows(tx, ruleInsertClause, ruleConflictClause, ruleHits)
+	if err != nil {
+		log.Err(err).Msgf("Unable to upsert the cluster report (org: %v, cluster: %v)", orgID, clusterName)
+		return nil, err
 	}
 
 	_, err = tx.Exec(reportUpsertQuery, orgID, clusterName, report, reportedAtTime, lastCheckedTime, kafkaOffset)
 	if err != nil {
 		log.Err(err).Msgf("Unable to upsert the cluster report (org: %v, cluster: %v)", orgID, clusterName)
-		return err
+		return nil, err
 	}
 
-	return nil
+	// Keep the report in history as well, the rows above are overwritten by the next report.
+	err = storage.writeReportHistory(
+		tx, orgID, clusterName, report, rules, reportedAtTime, lastCheckedTime, kafkaOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHits, nil
+}
+
+// uniqueRuleHits removes duplicate rule hits from the report, the last one
+// of the same rule and error key wins and keeps the position of the first one
+func uniqueRuleHits(rules []types.ReportItem) []types.ReportItem {
+	unique := make([]types.ReportItem, 0, len(rules))
+	index := make(map[ruleHitKey]int)
+
+	for _, rule := range rules {
+		key := ruleHitKey{ruleFQDN: rule.Module, errorKey: rule.ErrorKey}
+		if i, found := index[key]; found {
+			unique[i] = rule
+			continue
+		}
+
+		index[key] = len(unique)
+		unique = append(unique, rule)
+	}
+
+	return unique
+}
+
+// hasMoreRecentReport checks if there is a report for the cluster more recent
+// than the given time already in the database, a warning is printed if so
+func (storage DBStorage) hasMoreRecentReport(
+	tx *sql.Tx, orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime time.Time,
+) (bool, error) {
+	rows, err := tx.Query(
+		"SELECT last_checked_at FROM report WHERE org_id = $1 AND cluster = $2 AND last_checked_at > $3;",
+		orgID, clusterName, lastCheckedTime)
+	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to look up the most recent report in the database")
+		return false, err
+	}
+
+	defer closeRows(rows)
+
+	if rows.Next() {
+		log.Warn().Msgf("Database already contains report for organization %d and cluster name %s more recent than %v",
+			orgID, clusterName, lastCheckedTime)
+		return true, nil
+	}
+
+	return false, nil
 }
 
 // WriteReportForCluster writes result (health status) for selected cluster for given organization
@@ -667,16 +863,16 @@ func (storage DBStorage) WriteReportForCluster(
 	lastCheckedTime time.Time,
 	kafkaOffset types.KafkaOffset,
 ) error {
+	if storage.dbDriverType != types.DBDriverSQLite3 && storage.dbDriverType != types.DBDriverPostgres {
+		return fmt.Errorf("writing report with DB %v is not supported", storage.dbDriverType)
+	}
+
 	// Skip writing the report if it isn't newer than a report
 	// that is already in the database for the same cluster.
-	if oldLastChecked, exists := storage.clustersLastChecked[clusterName]; exists && !lastCheckedTime.After(oldLastChecked) {
+	if oldLastChecked, exists := storage.getClusterLastChecked(clusterName); exists && !lastCheckedTime.After(oldLastChecked) {
 		return types.ErrOldReport
 	}
 
-	if storage.dbDriverType != types.DBDriverSQLite3 && storage.dbDriverType != types.DBDriverPostgres {
-		return fmt.Errorf("writing report with DB %v is not supported", storage.dbDriverType)
-	}
-
 	// Begin a new transaction.
 	tx, err := storage.connection.Begin()
 	if err != nil {
@@ -684,32 +880,18 @@ func (storage DBStorage) WriteReportForCluster(
 	}
 
 	err = func(tx *sql.Tx) error {
-
-		// Check if there is a more recent report for the cluster already in the database.
-		rows, err := tx.Query(
-			"SELECT last_checked_at FROM report WHERE org_id = $1 AND cluster = $2 AND last_checked_at > $3;",
-			orgID, clusterName, lastCheckedTime)
-		err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
-		if err != nil {
-			log.Error().Err(err).Msg("Unable to look up the most recent report in the database")
+		// If there is a more recent report, discard this one (don't update it).
+		moreRecent, err := storage.hasMoreRecentReport(tx, orgID, clusterName, lastCheckedTime)
+		if err != nil || moreRecent {
 			return err
 		}
 
-		defer closeRows(rows)
-
-		// If there is one, print a warning and discard the report (don't update it).
-		if rows.Next() {
-			log.Warn().Msgf("Database already contains report for organization %d and cluster name %s more recent than %v",
-				orgID, clusterName, lastCheckedTime)
-			return nil
-		}
-
-		err = storage.updateReport(tx, orgID, clusterName, report, rules, lastCheckedTime, kafkaOffset)
+		_, err = storage.updateReport(tx, orgID, clusterName, report, rules, lastCheckedTime, kafkaOffset)
 		if err != nil {
 			return err
 		}
 
-		storage.clustersLastChecked[clusterName] = lastCheckedTime
+		storage.setClusterLastChecked(clusterName, lastCheckedTime)
 		metrics.WrittenReports.Inc()
 
 		return nil
@@ -720,6 +902,12 @@ func (storage DBStorage) WriteReportForCluster(
 	return err
 }
 
+// queryRower is implemented by both sql.DB and sql.Tx, so the same query
+// can be used inside and outside of transactions
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // finishTransaction finishes the transaction depending on err. err == nil -> commit, err != nil -> rollback
 func finishTransaction(tx *sql.Tx, err error) {
 	if err != nil {
@@ -746,14 +934,49 @@ func (storage DBStorage) ReportsCount() (int, error) {
 
 // DeleteReportsForOrg deletes all reports related to the specified organization from the storage.
 func (storage DBStorage) DeleteReportsForOrg(orgID types.OrgID) error {
-	_, err := storage.connection.Exec("DELETE FROM report WHERE org_id = $1;", orgID)
-	return err
+	return storage.deleteReports(
+		orgForAudit(orgID), AuditActionDeleteOrgReports, fmt.Sprint(orgID), "org_id = $1", orgID,
+	)
 }
 
 // DeleteReportsForCluster deletes all reports related to the specified cluster from the storage.
 func (storage DBStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
-	_, err := storage.connection.Exec("DELETE FROM report WHERE cluster = $1;", clusterName)
-	return err
+	return storage.deleteReports(
+		clusterOrgForAudit(clusterName), AuditActionDeleteClusterReports, string(clusterName), "cluster = $1", clusterName,
+	)
+}
+
+// deleteReports deletes reports selected by the condition with the only
+// argument arg and records deleted reports into the audit log
+func (storage DBStorage) deleteReports(
+	org auditOrg, action AuditAction, target string, condition string, arg interface{},
+) error {
+	readReports := func(tx *sql.Tx) (interface{}, error) {
+		return readReportsForAudit(tx, condition, arg)
+	}
+
+	var deleted []reportAuditValue
+
+	err := storage.changeWithAudit(org, action, target, readReports, func(tx *sql.Tx) error {
+		var err error
+
+		deleted, err = readReportsForAudit(tx, condition, arg)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM report WHERE "+condition+";", arg)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, report := range deleted {
+		storage.forgetClustersLastChecked(report.ClusterName)
+	}
+
+	return nil
 }
 
 // GetConnection returns db connection(useful for testing)
@@ -762,10 +985,22 @@ func (storage DBStorage) GetConnection() *sql.DB {
 }
 
 // WriteConsumerError writes a report about a consumer error into the storage.
-func (storage DBStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
+// Error of the message consumed from the same position again, for example
+// when the same file is read by the file source again, replaces the stored one
+// including the result of its replay.
+func (storage DBStorage) WriteConsumerError(msg *broker.Message, consumerErr error) error {
 	_, err := storage.connection.Exec(`
 		INSERT INTO consumer_error (topic, partition, topic_offset, key, produced_at, consumed_at, message, error)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (topic, partition, topic_offset) DO UPDATE SET
+			key = EXCLUDED.key,
+			produced_at = EXCLUDED.produced_at,
+			consumed_at = EXCLUDED.consumed_at,
+			message = EXCLUDED.message,
+			error = EXCLUDED.error,
+			replay_status = '',
+			replayed_at = NULL,
+			replay_error = ''`,
 		msg.Topic, msg.Partition, msg.Offset, msg.Key, msg.Timestamp, time.Now().UTC(), msg.Value, consumerErr.Error())
 
 	return err
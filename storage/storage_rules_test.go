@@ -457,12 +457,16 @@ func TestDBStorageVoteOnRuleDBCloseError(t *testing.T) {
 	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpects(t)
 	defer ira_helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
 
+	expects.ExpectBegin()
+
 	expects.ExpectPrepare("INSERT").
 		WillBeClosed().
 		WillReturnCloseError(fmt.Errorf(errStr)).
 		ExpectExec().
 		WillReturnResult(driver.ResultNoRows)
 
+	expects.ExpectCommit()
+
 	err := mockStorage.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteNone, "")
 	helpers.FailOnError(t, err)
 
@@ -574,3 +578,203 @@ func TestDBStorageDisableFeedbackErrorDBError(t *testing.T) {
 	_, err := mockStorage.GetUserFeedbackOnRuleDisable(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
 	assert.EqualError(t, err, "sql: database is closed")
 }
+
+func mustWriteReportsForTwoClusters(t *testing.T, mockStorage storage.Storage) {
+	for _, clusterName := range []types.ClusterName{firstClusterName, secondClusterName} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, clusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+}
+
+func TestDBStorage_ToggleRuleForAllOrgClusters(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportsForTwoClusters(t, mockStorage)
+
+	toggled, err := mockStorage.ToggleRuleForAllOrgClusters(
+		testdata.OrgID, testdata.Rule1ID, storage.RuleToggleDisable,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{firstClusterName, secondClusterName}, toggled)
+
+	for _, clusterName := range toggled {
+		toggle, err := mockStorage.GetFromClusterRuleToggle(clusterName, testdata.Rule1ID)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+	}
+}
+
+func TestDBStorage_ToggleRuleForOrgClusters_SelectedClusters(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportsForTwoClusters(t, mockStorage)
+
+	toggled, err := mockStorage.ToggleRuleForOrgClusters(
+		testdata.OrgID,
+		[]types.ClusterName{secondClusterName, secondClusterName},
+		testdata.Rule1ID,
+		storage.RuleToggleDisable,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{secondClusterName}, toggled)
+
+	_, err = mockStorage.GetFromClusterRuleToggle(firstClusterName, testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+
+	toggled, err = mockStorage.ToggleRuleForOrgClusters(
+		testdata.OrgID, []types.ClusterName{secondClusterName}, testdata.Rule1ID, storage.RuleToggleEnable,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{secondClusterName}, toggled)
+
+	toggle, err := mockStorage.GetFromClusterRuleToggle(secondClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleEnable, toggle.Disabled)
+}
+
+func TestDBStorage_ToggleRuleForOrgClusters_NoClusters(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportsForTwoClusters(t, mockStorage)
+
+	// empty list doesn't mean all clusters
+	toggled, err := mockStorage.ToggleRuleForOrgClusters(
+		testdata.OrgID, []types.ClusterName{}, testdata.Rule1ID, storage.RuleToggleDisable,
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, toggled)
+
+	_, err = mockStorage.GetFromClusterRuleToggle(firstClusterName, testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+}
+
+func TestDBStorage_ToggleRuleForAllOrgClusters_NoClusters(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	toggled, err := mockStorage.ToggleRuleForAllOrgClusters(
+		testdata.OrgID, testdata.Rule1ID, storage.RuleToggleDisable,
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, toggled)
+}
+
+// TestDBStorage_ToggleRuleForOrgClusters_UnknownCluster checks that nothing
+// is toggled when one of the clusters doesn't belong to the organization
+func TestDBStorage_ToggleRuleForOrgClusters_UnknownCluster(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportsForTwoClusters(t, mockStorage)
+
+	_, err := mockStorage.ToggleRuleForOrgClusters(
+		testdata.OrgID,
+		[]types.ClusterName{firstClusterName, testdata.ClusterName},
+		testdata.Rule1ID,
+		storage.RuleToggleDisable,
+	)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.ClusterName}, err)
+
+	_, err = mockStorage.GetFromClusterRuleToggle(firstClusterName, testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+}
+
+func TestDBStorage_ToggleRuleForOrgClusters_UnexpectedRuleToggleValue(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, err := mockStorage.ToggleRuleForOrgClusters(
+		testdata.OrgID, nil, testdata.Rule1ID, -999,
+	)
+	assert.EqualError(t, err, "Unexpected rule toggle value")
+}
+
+func TestDBStorage_ToggleRuleForOrgClusters_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ToggleRuleForOrgClusters(
+		testdata.OrgID, nil, testdata.Rule1ID, storage.RuleToggleDisable,
+	)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_DisableRuleForClusterUntil(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(testdata.ClusterName, testdata.Rule1ID, until))
+
+	toggle, err := mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+	assert.True(t, until.Equal(toggle.DisabledUntil.Time))
+
+	toggles, err := mockStorage.GetTogglesForRules(testdata.ClusterName, testdata.RuleOnReportResponses)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.RuleID]bool{testdata.Rule1ID: true}, toggles)
+
+	disabledUntil, err := mockStorage.GetDisabledUntilForRules(testdata.ClusterName, testdata.RuleOnReportResponses)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disabledUntil, 1)
+	assert.True(t, until.Equal(disabledUntil[testdata.Rule1ID]))
+
+	// disabling the rule permanently clears the time limit
+	helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(testdata.ClusterName, testdata.Rule1ID, storage.RuleToggleDisable))
+
+	disabledUntil, err = mockStorage.GetDisabledUntilForRules(testdata.ClusterName, testdata.RuleOnReportResponses)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disabledUntil)
+}
+
+// TestDBStorage_DisableRuleForClusterUntil_Expired checks that rules are
+// treated as enabled once the time limit of disabling is over
+func TestDBStorage_DisableRuleForClusterUntil_Expired(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(
+		testdata.ClusterName, testdata.Rule1ID, time.Now().Add(-time.Minute),
+	))
+
+	toggle, err := mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleEnable, toggle.Disabled)
+
+	toggles, err := mockStorage.GetTogglesForRules(testdata.ClusterName, testdata.RuleOnReportResponses)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.RuleID]bool{testdata.Rule1ID: false}, toggles)
+
+	disabledUntil, err := mockStorage.GetDisabledUntilForRules(testdata.ClusterName, testdata.RuleOnReportResponses)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disabledUntil)
+
+	ruleHits, err := mockStorage.ReadRuleHitsForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, ruleHits, 3)
+}
+
+func TestDBStorage_DisableRuleForClusterUntil_NotExpiredOrgRuleHits(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(
+		testdata.ClusterName, testdata.Rule1ID, time.Now().Add(time.Hour),
+	))
+
+	ruleHits, err := mockStorage.ReadRuleHitsForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, ruleHits, 2)
+}
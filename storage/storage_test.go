@@ -29,11 +29,11 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
-	"github.com/Shopify/sarama"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 
@@ -325,17 +325,30 @@ func TestDBStorageWriteReportForClusterFakePostgresOK(t *testing.T) {
 		WillReturnRows(expects.NewRows([]string{"last_checked_at"})).
 		RowsWillBeClosed()
 
+	expects.ExpectQuery("SELECT rule_fqdn, error_key, template_data FROM rule_hit").
+		WillReturnRows(expects.NewRows([]string{"rule_fqdn", "error_key", "template_data"})).
+		RowsWillBeClosed()
+
+	// all rule hits are new for the cluster
+	expects.ExpectExec("INSERT INTO rule_hit_event").
+		WillReturnResult(driver.ResultNoRows)
+
 	expects.ExpectExec("DELETE FROM rule_hit").
 		WillReturnResult(driver.ResultNoRows)
 
-	for i := 0; i < len(testdata.Report3RulesParsed); i++ {
-		expects.ExpectExec("INSERT INTO rule_hit").
-			WillReturnResult(driver.ResultNoRows)
-	}
+	// all rule hits are inserted by one statement
+	expects.ExpectExec("INSERT INTO rule_hit").
+		WillReturnResult(driver.ResultNoRows)
 
 	expects.ExpectExec("INSERT INTO report").
 		WillReturnResult(driver.ResultNoRows)
 
+	expects.ExpectExec("INSERT INTO report_history").
+		WillReturnResult(driver.ResultNoRows)
+
+	expects.ExpectExec("INSERT INTO rule_hit_history").
+		WillReturnResult(driver.ResultNoRows)
+
 	expects.ExpectCommit()
 
 	err := mockStorage.WriteReportForCluster(
@@ -656,7 +669,7 @@ func TestDBStorageWriteConsumerError(t *testing.T) {
 	testProducedAt := time.Now().Add(-time.Hour).UTC()
 	testError := fmt.Errorf("Consumer error")
 
-	err := mockStorage.WriteConsumerError(&sarama.ConsumerMessage{
+	err := mockStorage.WriteConsumerError(&broker.Message{
 		Topic:     testTopic,
 		Partition: testPartition,
 		Offset:    testOffset,
@@ -821,6 +834,22 @@ func createReportTableWithBadClusterField(t *testing.T, mockStorage storage.Stor
 
 	_, err = connection.Exec(query)
 	helpers.FailOnError(t, err)
+
+	query = `
+		CREATE TABLE rule_hit_event (
+			org_id          INTEGER NOT NULL,
+			cluster_id      VARCHAR NOT NULL,
+			rule_fqdn       VARCHAR NOT NULL,
+			error_key       VARCHAR NOT NULL,
+			event_type      VARCHAR NOT NULL,
+			template_data   VARCHAR NOT NULL,
+			last_checked_at TIMESTAMP NOT NULL,
+			PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key, last_checked_at)
+		)
+	`
+
+	_, err = connection.Exec(query)
+	helpers.FailOnError(t, err)
 }
 
 // TestConstructInClausule checks the helper function constructInClausule
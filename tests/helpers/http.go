@@ -95,3 +95,14 @@ func AssertAPIRequest(
 
 	helpers.AssertAPIRequest(t, testServer, serverConfig.APIPrefix, request, expectedResponse)
 }
+
+// AssertAPIRequestWithServer sends api request to the provided server, that
+// can be set up in a way AssertAPIRequest doesn't do, and checks api response
+func AssertAPIRequestWithServer(
+	t testing.TB,
+	testServer *server.HTTPServer,
+	request *helpers.APIRequest,
+	expectedResponse *helpers.APIResponse,
+) {
+	helpers.AssertAPIRequest(t, testServer, testServer.Config.APIPrefix, request, expectedResponse)
+}
@@ -36,13 +36,12 @@ type MockKafkaConsumer struct {
 // Serve simulates sending messages
 func (mockKafkaConsumer *MockKafkaConsumer) Serve() {
 	for i, message := range mockKafkaConsumer.messages {
-		mockKafkaConsumer.KafkaConsumer.HandleMessage(&sarama.ConsumerMessage{
-			Timestamp:      time.Now(),
-			BlockTimestamp: time.Now(),
-			Value:          []byte(message),
-			Topic:          mockKafkaConsumer.topic,
-			Partition:      0,
-			Offset:         int64(i),
+		mockKafkaConsumer.KafkaConsumer.HandleMessage(&broker.Message{
+			Timestamp: time.Now(),
+			Value:     []byte(message),
+			Topic:     mockKafkaConsumer.topic,
+			Partition: 0,
+			Offset:    int64(i),
 		})
 	}
 
@@ -127,6 +127,12 @@ func mustGetSqliteStorage(tb testing.TB, datasource string, init bool) storage.S
 	db, err := sql.Open(sqlite3, datasource)
 	helpers.FailOnError(tb, err)
 
+	// every new connection to in-memory database opens a new empty database,
+	// so concurrent users (e.g. consumer workers) have to share one connection
+	if datasource == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
 	_, err = db.Exec("PRAGMA foreign_keys = ON;")
 	helpers.FailOnError(tb, err)
 
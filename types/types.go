@@ -56,8 +56,6 @@ type (
 	RuleOnReport = types.RuleOnReport
 	// ReportRules is a helper struct for easy JSON unmarshalling of string encoded report
 	ReportRules = types.ReportRules
-	// ReportResponse represents the response of /report endpoint
-	ReportResponse = types.ReportResponse
 	// ReportResponseMeta contains metadata about the report
 	ReportResponseMeta = types.ReportResponseMeta
 	// DisabledRuleResponse represents a single disabled rule displaying only identifying information
@@ -103,9 +101,28 @@ type FeedbackRequest struct {
 	Message string `json:"message"`
 }
 
+// RuleAckRequest contains justification of rule acknowledgement
+type RuleAckRequest struct {
+	Justification string `json:"justification"`
+}
+
 // ReportItem represents a single (hit) rule of the string encoded report
 type ReportItem = types.ReportItem
 
+// RuleOnReportResponse represents a single (hit) rule in the response of
+// /report endpoint. RuleOnReport is shared with other services, so the time
+// limit of rule disabling, if there's any, is added here.
+type RuleOnReportResponse struct {
+	RuleOnReport
+	DisabledUntil Timestamp `json:"disabled_until,omitempty"`
+}
+
+// ReportResponse represents the response of /report endpoint
+type ReportResponse struct {
+	Meta   ReportResponseMeta     `json:"meta"`
+	Report []RuleOnReportResponse `json:"reports"`
+}
+
 // ClusterReports is a data structure containing list of clusters, list of
 // errors and dictionary with results per cluster.
 type ClusterReports struct {